@@ -0,0 +1,59 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"go-server/models"
+	"go-server/tenant"
+)
+
+// BlobStore ที่เก็บไฟล์ที่อัปโหลด (รูปของ Flex message และ site template)
+type BlobStore interface {
+	// Put เขียนไฟล์ไว้ที่ key (เช่น flex_messages/reward/banner.png) แล้วคืน URL สาธารณะ
+	Put(ctx context.Context, key, contentType string, src io.Reader) (string, error)
+	// Owns ตรวจว่า URL ชี้ไปที่ไฟล์ใน store นี้
+	Owns(rawURL string) bool
+}
+
+const (
+	BackendGCS   = "gcs"
+	BackendS3    = "s3"
+	BackendLocal = "local"
+)
+
+// LocalRoute path ที่ให้บริการไฟล์ของ local store (ดู routes.SetupStorageRoutes)
+const LocalRoute = "/uploads"
+
+var ErrNotConfigured = errors.New("storage is not configured")
+
+// LocalDir โฟลเดอร์ของ local store อ่านจาก LOCAL_STORAGE_DIR (ค่าเริ่มต้น uploads)
+func LocalDir() string {
+	if dir := os.Getenv("LOCAL_STORAGE_DIR"); dir != "" {
+		return dir
+	}
+	return "uploads"
+}
+
+// ForConfig สร้าง BlobStore ตาม config.Storage ของ tenant ใน ctx
+// config เดิมที่ไม่มี storage.backend ใช้ Firebase Storage ตาม FirebaseConfig
+func ForConfig(ctx context.Context, config models.Config) (BlobStore, error) {
+	switch config.Storage.Backend {
+	case "", BackendGCS:
+		if config.FirebaseConfig.BucketName == "" {
+			return nil, ErrNotConfigured
+		}
+		return gcsForTenant(tenant.FromContext(ctx), config.FirebaseConfig.Credential, config.FirebaseConfig.BucketName)
+	case BackendS3:
+		if config.Storage.S3.Bucket == "" {
+			return nil, ErrNotConfigured
+		}
+		return NewS3(config.Storage.S3), nil
+	case BackendLocal:
+		// tenant อื่นนอกจาก default แยกโฟลเดอร์ย่อยเพราะใช้ LOCAL_STORAGE_DIR ร่วมกัน
+		return NewLocal(LocalDir(), config.Storage.Local.PublicURL, tenant.Value(ctx)), nil
+	}
+	return nil, errors.New("unknown storage backend " + config.Storage.Backend)
+}
@@ -0,0 +1,99 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	firebase "firebase.google.com/go"
+	"google.golang.org/api/option"
+)
+
+// GCS เก็บไฟล์ใน Firebase Storage (Google Cloud Storage) bucket และตั้งให้อ่านได้สาธารณะ
+// storage client สร้างครั้งเดียวตอนสร้าง GCS และใช้ซ้ำทุกครั้งที่ Put (ดู gcsForTenant)
+type GCS struct {
+	credential string
+	bucketName string
+	bucket     *storage.BucketHandle
+}
+
+func NewGCS(credential, bucketName string) (*GCS, error) {
+	// client อยู่นานกว่า request จึงไม่ใช้ context ของ request (token ถูก refresh ด้วย context นี้)
+	ctx := context.Background()
+	opt := option.WithCredentialsJSON([]byte(credential))
+	app, err := firebase.NewApp(ctx, nil, opt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Firebase app: %v", err)
+	}
+
+	client, err := app.Storage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %v", err)
+	}
+
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bucket %s: %v", bucketName, err)
+	}
+	return &GCS{credential: credential, bucketName: bucketName, bucket: bucket}, nil
+}
+
+// gcsStores GCS ที่สร้างแล้วของแต่ละ tenant สร้างใหม่เมื่อ credential หรือ bucket ใน config เปลี่ยน
+var gcsStores = struct {
+	sync.Mutex
+	byTenant map[string]*GCS
+}{byTenant: make(map[string]*GCS)}
+
+func gcsForTenant(tenantID, credential, bucketName string) (*GCS, error) {
+	gcsStores.Lock()
+	defer gcsStores.Unlock()
+
+	if store, ok := gcsStores.byTenant[tenantID]; ok && store.credential == credential && store.bucketName == bucketName {
+		return store, nil
+	}
+	store, err := NewGCS(credential, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	gcsStores.byTenant[tenantID] = store
+	return store, nil
+}
+
+func (g *GCS) Put(ctx context.Context, key, contentType string, src io.Reader) (string, error) {
+	obj := g.bucket.Object(key)
+	writer := obj.NewWriter(ctx)
+	writer.ContentType = contentType
+
+	if _, err := io.Copy(writer, src); err != nil {
+		writer.Close()
+		return "", fmt.Errorf("failed to copy file: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close writer: %v", err)
+	}
+
+	if err := obj.ACL().Set(ctx, storage.AllUsers, storage.RoleReader); err != nil {
+		return "", fmt.Errorf("failed to make file public: %v", err)
+	}
+
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get file attributes: %v", err)
+	}
+	return attrs.MediaLink, nil
+}
+
+func (g *GCS) Owns(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	if u.Host != "storage.googleapis.com" && u.Host != "firebasestorage.googleapis.com" {
+		return false
+	}
+	return strings.Contains(u.Path, "/"+g.bucketName+"/")
+}
@@ -0,0 +1,73 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local เก็บไฟล์ในโฟลเดอร์ของเครื่อง ใช้พัฒนาและทดสอบโดยไม่ต้องมี cloud storage
+type Local struct {
+	dir     string
+	baseURL string // base URL ของ server ว่าง = คืน URL แบบ relative
+	prefix  string // โฟลเดอร์ย่อยของ tenant
+}
+
+func NewLocal(dir, baseURL, prefix string) *Local {
+	return &Local{
+		dir:     dir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		prefix:  prefix,
+	}
+}
+
+func (l *Local) Put(ctx context.Context, key, contentType string, src io.Reader) (string, error) {
+	// clean key ก่อนต่อ prefix เพื่อไม่ให้ ".." ออกนอกโฟลเดอร์ของ tenant
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || strings.HasSuffix(key, "/") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	name := path.Join(l.prefix, cleaned)
+
+	target := filepath.Join(l.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	// เขียนไฟล์ชั่วคราวก่อนแล้วค่อย rename เพื่อไม่ให้มีการอ่านไฟล์ที่เขียนไม่ครบ
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %v", err)
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to copy file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to close file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to set file mode: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to save file: %v", err)
+	}
+
+	return l.baseURL + LocalRoute + "/" + name, nil
+}
+
+func (l *Local) Owns(rawURL string) bool {
+	prefix := l.baseURL + LocalRoute + "/"
+	if l.prefix != "" {
+		prefix += l.prefix + "/"
+	}
+	return strings.HasPrefix(rawURL, prefix)
+}
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Service เก็บ config ที่ถอดรหัสแล้วของแต่ละ tenant ไว้ในหน่วยความจำ แทนการอ่าน tbl_config ทุกครั้ง
+// tenant มาจาก context (ดู tenant.With) และ cache จะถูก refresh เมื่อมีการบันทึกผ่าน API
+// หรือเมื่อ change stream แจ้งว่าเอกสารเปลี่ยน ส่วนที่สร้าง client จาก config (เช่น LINE bot)
+// ใช้ Subscribe เพื่อสร้างใหม่เมื่อค่าเปลี่ยน
+type Service struct {
+	collection        *mongo.Collection
+	overlayCollection *mongo.Collection
+
+	mu          sync.RWMutex
+	configs     map[string]models.Config
+	subscribers []func(tenantID string, previous, current models.Config)
+}
+
+func NewService(collection, overlayCollection *mongo.Collection) *Service {
+	return &Service{
+		collection:        collection,
+		overlayCollection: overlayCollection,
+		configs:           make(map[string]models.Config),
+	}
+}
+
+// Collection คืน tbl_config สำหรับส่วนที่ต้องเขียน config
+func (s *Service) Collection() *mongo.Collection {
+	return s.collection
+}
+
+// Get คืน config ของ tenant ใน ctx จาก cache (อ่านจากฐานข้อมูลครั้งแรกที่เรียก)
+func (s *Service) Get(ctx context.Context) (models.Config, error) {
+	tenantID := tenant.FromContext(ctx)
+
+	s.mu.RLock()
+	config, ok := s.configs[tenantID]
+	s.mu.RUnlock()
+	if !ok {
+		if err := s.Refresh(ctx); err != nil {
+			return models.Config{}, err
+		}
+		s.mu.RLock()
+		config = s.configs[tenantID]
+		s.mu.RUnlock()
+	}
+	return cloneConfig(config)
+}
+
+// cloneConfig คัดลอก config ทั้งก้อน (รวม slice ที่ซ้อนอยู่ เช่น Tiers, TelegramAdminIDs, FlexMessages)
+// ผู้เรียก Get แก้ไขค่าที่ได้ไป (เช่น ApplyConfigOverlay หรือการแก้ Tiers ก่อนบันทึก) โดยไม่กระทบ cache
+func cloneConfig(config models.Config) (models.Config, error) {
+	data, err := bson.Marshal(config)
+	if err != nil {
+		return models.Config{}, err
+	}
+	var clone models.Config
+	if err := bson.Unmarshal(data, &clone); err != nil {
+		return models.Config{}, err
+	}
+	return clone, nil
+}
+
+// Load อ่าน config ของ tenant ใน ctx จาก cache ลงใน config
+func (s *Service) Load(ctx context.Context, config *models.Config) error {
+	current, err := s.Get(ctx)
+	if err != nil {
+		return err
+	}
+	*config = current
+	return nil
+}
+
+// ActiveOverlay คืน overlay ของ tenant ใน ctx ที่มีผล ณ เวลา at (nil ถ้าไม่มี)
+// overlay ของ tenant เดียวกันมีช่วงเวลาทับกันไม่ได้ จึงมีได้อย่างมากหนึ่งรายการ
+func (s *Service) ActiveOverlay(ctx context.Context, at time.Time) (*models.ConfigOverlay, error) {
+	var overlay models.ConfigOverlay
+	err := s.overlayCollection.FindOne(ctx, tenant.Scope(ctx, bson.M{
+		"status":    "active",
+		"starts_at": bson.M{"$lte": at},
+		"ends_at":   bson.M{"$gt": at},
+	})).Decode(&overlay)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &overlay, nil
+}
+
+// Overlays คืน overlay ที่ยังไม่ถูกยกเลิกทั้งหมดของ tenant ใน ctx เรียงตามเวลาเริ่ม
+// ใช้หา overlay ที่มีผลในอดีตหลายช่วงเวลาโดยไม่ต้องเรียก ActiveOverlay ทีละเวลา (ดู utils.OverlayAt)
+func (s *Service) Overlays(ctx context.Context) ([]models.ConfigOverlay, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "starts_at", Value: 1}})
+	cursor, err := s.overlayCollection.Find(ctx, tenant.Scope(ctx, bson.M{"status": "active"}), opts)
+	if err != nil {
+		return nil, err
+	}
+	overlays := []models.ConfigOverlay{}
+	if err := cursor.All(ctx, &overlays); err != nil {
+		return nil, err
+	}
+	return overlays, nil
+}
+
+// Effective คืน config ของ tenant ใน ctx ที่รวม overlay ที่มีผล ณ เวลา at แล้ว
+// ใช้ตอนประเมินผลมิชชันและส่งข้อความ ส่วนการแก้ไข config ให้ใช้ Get/Load ซึ่งไม่รวม overlay
+func (s *Service) Effective(ctx context.Context, at time.Time) (models.Config, error) {
+	config, err := s.Get(ctx)
+	if err != nil {
+		return config, err
+	}
+
+	overlay, err := s.ActiveOverlay(ctx, at)
+	if err != nil {
+		return config, err
+	}
+	utils.ApplyConfigOverlay(&config, overlay)
+	return config, nil
+}
+
+// Refresh อ่าน config ของ tenant ใน ctx ใหม่จากฐานข้อมูล และแจ้ง subscriber เมื่อค่าเปลี่ยน
+func (s *Service) Refresh(ctx context.Context) error {
+	tenantID := tenant.FromContext(ctx)
+
+	var config models.Config
+	if err := utils.LoadConfig(ctx, s.collection, tenant.Filter(tenantID), &config); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	previous, wasLoaded := s.configs[tenantID]
+	s.configs[tenantID] = config
+	subscribers := append([]func(string, models.Config, models.Config){}, s.subscribers...)
+	s.mu.Unlock()
+
+	if wasLoaded && reflect.DeepEqual(previous, config) {
+		return nil
+	}
+	for _, subscriber := range subscribers {
+		subscriber(tenantID, previous, config)
+	}
+	return nil
+}
+
+// RefreshAll refresh ทุก tenant ที่อยู่ใน cache
+func (s *Service) RefreshAll(ctx context.Context) {
+	s.mu.RLock()
+	tenants := make([]string, 0, len(s.configs))
+	for tenantID := range s.configs {
+		tenants = append(tenants, tenantID)
+	}
+	s.mu.RUnlock()
+
+	for _, tenantID := range tenants {
+		if err := s.Refresh(tenant.With(ctx, tenantID)); err != nil {
+			log.Printf("Failed to refresh config for tenant %s: %v", tenantID, err)
+		}
+	}
+}
+
+// Subscribe ลงทะเบียน fn ให้ถูกเรียกทุกครั้งที่ config ของ tenant ใดเปลี่ยน
+// previous และ current ใช้ slice ร่วมกับ cache ให้อ่านอย่างเดียว (ต้องการแก้ไขให้ใช้ Get)
+func (s *Service) Subscribe(fn func(tenantID string, previous, current models.Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Tenants คืนรายชื่อ tenant ทั้งหมดที่มี config
+func (s *Service) Tenants(ctx context.Context) ([]string, error) {
+	values, err := s.collection.Distinct(ctx, "tenant_id", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := []string{}
+	hasDefault := false
+	for _, value := range values {
+		tenantID, _ := value.(string)
+		if tenantID == "" || tenantID == tenant.Default {
+			hasDefault = true
+			continue
+		}
+		tenants = append(tenants, tenantID)
+	}
+
+	// เอกสารที่ไม่มี tenant_id จะไม่อยู่ในผล Distinct จึงต้องตรวจแยก
+	if !hasDefault {
+		count, err := s.collection.CountDocuments(ctx, tenant.Filter(tenant.Default))
+		if err != nil {
+			return nil, err
+		}
+		hasDefault = count > 0
+	}
+	if hasDefault {
+		tenants = append([]string{tenant.Default}, tenants...)
+	}
+	return tenants, nil
+}
+
+// HasTenant ตรวจว่า tenantID มี config อยู่แล้ว (ดูจาก cache ก่อน แล้วจึงถามฐานข้อมูล)
+func (s *Service) HasTenant(ctx context.Context, tenantID string) (bool, error) {
+	s.mu.RLock()
+	_, ok := s.configs[tenantID]
+	s.mu.RUnlock()
+	if ok {
+		return true, nil
+	}
+
+	count, err := s.collection.CountDocuments(ctx, tenant.Filter(tenantID), options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ResolveTenant หา tenant จาก LIFF ID หรือ host ของ request คืน tenant.Default ถ้าไม่พบ
+func (s *Service) ResolveTenant(ctx context.Context, liffID, host string) (string, error) {
+	var conditions bson.A
+	if liffID != "" {
+		conditions = append(conditions, bson.M{"liff_id": liffID})
+	}
+	if host != "" {
+		conditions = append(conditions, bson.M{"hosts": host})
+	}
+	if len(conditions) == 0 {
+		return tenant.Default, nil
+	}
+
+	var match struct {
+		TenantID string `bson:"tenant_id"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"tenant_id": 1})
+	err := s.collection.FindOne(ctx, bson.M{"$or": conditions}, opts).Decode(&match)
+	if err == mongo.ErrNoDocuments {
+		return tenant.Default, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if match.TenantID == "" {
+		return tenant.Default, nil
+	}
+	return match.TenantID, nil
+}
+
+// Watch ติดตามการเปลี่ยนแปลงของ tbl_config ผ่าน change stream
+// ถ้า MongoDB ไม่รองรับ (standalone ที่ไม่ใช่ replica set) จะเปลี่ยนไป poll ทุก pollInterval
+func (s *Service) Watch(ctx context.Context, pollInterval time.Duration) {
+	log.Println("Starting config watcher")
+	for {
+		stream, err := s.collection.Watch(ctx, mongo.Pipeline{}, options.ChangeStream())
+		if err != nil {
+			log.Printf("Config change stream unavailable, polling every %s: %v", pollInterval, err)
+			s.poll(ctx, pollInterval)
+			return
+		}
+
+		for stream.Next(ctx) {
+			s.RefreshAll(ctx)
+		}
+		if err := stream.Err(); err != nil {
+			log.Printf("Config change stream closed: %v", err)
+		}
+		stream.Close(context.Background())
+
+		if ctx.Err() != nil {
+			return
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (s *Service) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RefreshAll(ctx)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"go-server/config"
+	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AlertController ตรวจสอบเงื่อนไขที่ admin ต้องรู้เป็นระยะ และแจ้งเตือนเข้า Telegram
+// alert ที่ยัง active อยู่จะไม่ถูกส่งซ้ำจนกว่าจะครบ RepeatAfterHours หรือปัญหาหายไป
+type AlertController struct {
+	alertCollection    *mongo.Collection
+	configService      *config.Service
+	logCollection      *mongo.Collection
+	eventCollection    *mongo.Collection
+	lineController     *LineController
+	telegramController *TelegramController
+}
+
+func NewAlertController(alertCollection *mongo.Collection, configService *config.Service, logCollection, eventCollection *mongo.Collection, lineController *LineController) *AlertController {
+	return &AlertController{
+		alertCollection:    alertCollection,
+		configService:      configService,
+		logCollection:      logCollection,
+		eventCollection:    eventCollection,
+		lineController:     lineController,
+		telegramController: NewTelegramController(configService),
+	}
+}
+
+// alertResult ผลการตรวจสอบ rule หนึ่งรอบ
+type alertResult struct {
+	Triggered bool
+	Value     float64
+	Detail    string
+}
+
+type alertRule struct {
+	Key      string
+	Title    string
+	Evaluate func(ctx context.Context, settings models.AlertConfig) (alertResult, error)
+}
+
+func (ac *AlertController) rules() []alertRule {
+	return []alertRule{
+		{Key: "pending_claims", Title: "รางวัลรออนุมัตินานเกินกำหนด", Evaluate: ac.checkPendingClaims},
+		{Key: "reward_expiring", Title: "รางวัลใกล้หมดอายุ", Evaluate: ac.checkRewardExpiring},
+		{Key: "dead_letter_events", Title: "Event ประมวลผลไม่สำเร็จ", Evaluate: ac.checkDeadLetterEvents},
+		{Key: "bet_api_error_rate", Title: "Bet API ผิดพลาดสูง", Evaluate: ac.checkBetAPIErrorRate},
+		{Key: "line_quota", Title: "โควต้าข้อความ LINE ใกล้หมด", Evaluate: ac.checkLineQuota},
+	}
+}
+
+// ProcessAlerts ตรวจ rule ของทุก tenant รอบละครั้ง โดยใช้ CheckIntervalMinutes ที่สั้นที่สุดเป็นระยะห่างของรอบ
+func (ac *AlertController) ProcessAlerts() {
+	log.Println("Starting ProcessAlerts")
+	const defaultInterval = 5 * time.Minute
+	for {
+		interval := defaultInterval
+
+		tenants, err := ac.configService.Tenants(context.Background())
+		if err != nil {
+			log.Printf("ProcessAlerts: Failed to list tenants: %v", err)
+		}
+
+		for i, tenantID := range tenants {
+			ctx := tenant.With(context.Background(), tenantID)
+
+			var config models.Config
+			err := ac.configService.Load(ctx, &config)
+			if err != nil {
+				log.Printf("ProcessAlerts: Failed to fetch config for tenant %s: %v", tenantID, err)
+				continue
+			}
+			tenantInterval := defaultInterval
+			if config.Alerts.CheckIntervalMinutes > 0 {
+				tenantInterval = time.Duration(config.Alerts.CheckIntervalMinutes) * time.Minute
+			}
+			if i == 0 || tenantInterval < interval {
+				interval = tenantInterval
+			}
+			if config.Alerts.Enabled {
+				ac.evaluateRules(ctx, config.Alerts)
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (ac *AlertController) evaluateRules(ctx context.Context, settings models.AlertConfig) {
+	for _, rule := range ac.rules() {
+		result, err := rule.Evaluate(ctx, settings)
+		if err != nil {
+			log.Printf("ProcessAlerts: Failed to evaluate rule %s: %v", rule.Key, err)
+			continue
+		}
+		if err := ac.applyResult(ctx, rule, result, settings); err != nil {
+			log.Printf("ProcessAlerts: Failed to update alert %s: %v", rule.Key, err)
+		}
+	}
+}
+
+// applyResult เปิด/ปิด alert ตามผลการตรวจสอบ และส่งข้อความเฉพาะตอนที่สถานะเปลี่ยน
+func (ac *AlertController) applyResult(ctx context.Context, rule alertRule, result alertResult, settings models.AlertConfig) error {
+	now := time.Now()
+
+	var active models.Alert
+	err := ac.alertCollection.FindOne(ctx, tenant.Scope(ctx, bson.M{"rule": rule.Key, "status": "active"})).Decode(&active)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	hasActive := err == nil
+
+	if !result.Triggered {
+		if !hasActive {
+			return nil
+		}
+		_, err := ac.alertCollection.UpdateOne(ctx, bson.M{"_id": active.ID}, bson.M{"$set": bson.M{
+			"status":      "resolved",
+			"resolved_at": now,
+		}})
+		if err != nil {
+			return err
+		}
+		return ac.telegramController.SendAdminMessage(ctx, telegramMessages.AlertResolved(rule.Title))
+	}
+
+	if !hasActive {
+		alert := models.Alert{
+			TenantID:    tenant.Value(ctx),
+			Rule:        rule.Key,
+			Status:      "active",
+			Value:       result.Value,
+			Message:     result.Detail,
+			TriggeredAt: now,
+			LastSentAt:  now,
+		}
+		if _, err := ac.alertCollection.InsertOne(ctx, alert); err != nil {
+			return err
+		}
+		return ac.telegramController.SendAdminMessage(ctx, telegramMessages.Alert(rule.Title, result.Detail))
+	}
+
+	update := bson.M{"value": result.Value, "message": result.Detail}
+	repeat := settings.RepeatAfterHours > 0 && now.Sub(active.LastSentAt) >= time.Duration(settings.RepeatAfterHours)*time.Hour
+	if repeat {
+		update["last_sent_at"] = now
+	}
+	if _, err := ac.alertCollection.UpdateOne(ctx, bson.M{"_id": active.ID}, bson.M{"$set": update}); err != nil {
+		return err
+	}
+	if repeat {
+		return ac.telegramController.SendAdminMessage(ctx, telegramMessages.Alert(rule.Title, result.Detail))
+	}
+	return nil
+}
+
+func (ac *AlertController) checkPendingClaims(ctx context.Context, settings models.AlertConfig) (alertResult, error) {
+	if settings.PendingClaimHours <= 0 {
+		return alertResult{}, nil
+	}
+	cutoff := time.Now().Add(-time.Duration(settings.PendingClaimHours) * time.Hour)
+	count, err := ac.logCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
+		"status":     "pending",
+		"created_at": bson.M{"$lte": cutoff},
+	}))
+	if err != nil {
+		return alertResult{}, err
+	}
+	return alertResult{
+		Triggered: count > 0,
+		Value:     float64(count),
+		Detail:    fmt.Sprintf("มีรางวัล <b>%d</b> รายการที่รออนุมัติเกิน %d ชั่วโมง", count, settings.PendingClaimHours),
+	}, nil
+}
+
+func (ac *AlertController) checkRewardExpiring(ctx context.Context, settings models.AlertConfig) (alertResult, error) {
+	if settings.RewardExpiringHours <= 0 {
+		return alertResult{}, nil
+	}
+	count, err := ac.eventCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
+		"type":        "reward_expiration",
+		"status":      "pending",
+		"expire_time": bson.M{"$lte": time.Now().Add(time.Duration(settings.RewardExpiringHours) * time.Hour)},
+	}))
+	if err != nil {
+		return alertResult{}, err
+	}
+	return alertResult{
+		Triggered: count > 0,
+		Value:     float64(count),
+		Detail:    fmt.Sprintf("มีรางวัล <b>%d</b> รายการที่จะหมดอายุภายใน %d ชั่วโมง", count, settings.RewardExpiringHours),
+	}, nil
+}
+
+func (ac *AlertController) checkDeadLetterEvents(ctx context.Context, settings models.AlertConfig) (alertResult, error) {
+	if settings.DeadLetterThreshold <= 0 {
+		return alertResult{}, nil
+	}
+	count, err := ac.eventCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{"status": "failed"}))
+	if err != nil {
+		return alertResult{}, err
+	}
+	return alertResult{
+		Triggered: count >= int64(settings.DeadLetterThreshold),
+		Value:     float64(count),
+		Detail:    fmt.Sprintf("มี event ที่ประมวลผลไม่สำเร็จ <b>%d</b> รายการ (status = failed ใน tbl_events)", count),
+	}, nil
+}
+
+func (ac *AlertController) checkBetAPIErrorRate(ctx context.Context, settings models.AlertConfig) (alertResult, error) {
+	if settings.BetApiErrorRatePercent <= 0 {
+		return alertResult{}, nil
+	}
+	total, failed := utils.BetAPIStats(tenant.Value(ctx))
+	if total == 0 || total < settings.BetApiMinRequests {
+		return alertResult{}, nil
+	}
+	rate := float64(failed) * 100 / float64(total)
+	return alertResult{
+		Triggered: rate >= float64(settings.BetApiErrorRatePercent),
+		Value:     rate,
+		Detail:    fmt.Sprintf("Bet API ผิดพลาด <b>%.1f%%</b> (%d จาก %d ครั้ง ใน 1 ชั่วโมงล่าสุด)", rate, failed, total),
+	}, nil
+}
+
+func (ac *AlertController) checkLineQuota(ctx context.Context, settings models.AlertConfig) (alertResult, error) {
+	if settings.LineQuotaPercent <= 0 {
+		return alertResult{}, nil
+	}
+	used, limit, err := ac.lineController.GetQuotaUsage(ctx)
+	if err != nil {
+		return alertResult{}, err
+	}
+	if limit == 0 {
+		return alertResult{}, nil
+	}
+	percent := float64(used) * 100 / float64(limit)
+	return alertResult{
+		Triggered: percent >= float64(settings.LineQuotaPercent),
+		Value:     percent,
+		Detail:    fmt.Sprintf("ใช้โควต้าข้อความ LINE ไปแล้ว <b>%.1f%%</b> (%d จาก %d ข้อความ)", percent, used, limit),
+	}, nil
+}
+
+// GetAlerts - รายการ alert ล่าสุดของ tenant (กรองด้วย ?status=active ได้)
+func (ac *AlertController) GetAlerts(c *fiber.Ctx) error {
+	filter := tenant.Scope(c.UserContext(), bson.M{})
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	q := utils.ListQuery{Sort: bson.D{{Key: "triggered_at", Value: -1}}}
+	alerts, page, err := findListPage[models.Alert](c, ac.alertCollection, filter, q, nil, 50, 100)
+	if err != nil {
+		return listPageFailed(c, err, "Failed to fetch alerts")
+	}
+
+	return c.JSON(utils.ListPage{Items: alerts, Pagination: page})
+}
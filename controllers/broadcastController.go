@@ -0,0 +1,497 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-server/eventbus"
+	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LINE multicast ส่งได้สูงสุด 500 user ต่อครั้ง
+const broadcastChunkSize = 500
+
+// broadcastLeaseDuration อายุ lease ของ broadcast ที่กำลังส่ง ถ้า process หยุดกลางคัน
+// lease จะหมดอายุและ ProcessBroadcasts (ของ instance นี้หลัง restart หรือ instance อื่น) รับไปส่งต่อ
+const broadcastLeaseDuration = 2 * time.Minute
+
+type BroadcastController struct {
+	broadcastCollection *mongo.Collection
+	recipientCollection *mongo.Collection
+	clientCollection    *mongo.Collection
+	missionCollection   *mongo.Collection
+	messageCollection   *mongo.Collection
+	lineController      *LineController
+}
+
+func NewBroadcastController(broadcastCollection, recipientCollection, clientCollection, missionCollection, messageCollection *mongo.Collection, lineController *LineController) *BroadcastController {
+	return &BroadcastController{
+		broadcastCollection: broadcastCollection,
+		recipientCollection: recipientCollection,
+		clientCollection:    clientCollection,
+		missionCollection:   missionCollection,
+		messageCollection:   messageCollection,
+		lineController:      lineController,
+	}
+}
+
+func validateBroadcastSegment(segment models.BroadcastSegment) error {
+	switch segment.Type {
+	case "all", "awaiting_reward", "no_mission":
+		return nil
+	case "tier":
+		if segment.Tier < 1 {
+			return fmt.Errorf("tier must be at least 1")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown segment type: %s", segment.Type)
+}
+
+// CreateBroadcast - สร้าง broadcast ใหม่ ถ้าไม่ระบุ scheduled_at จะส่งในรอบถัดไปทันที
+func (bc *BroadcastController) CreateBroadcast(c *fiber.Ctx) error {
+	var input struct {
+		Title       string                        `json:"title"`
+		FlexMessage models.BaseFlexMessageContent `json:"flexMessage"`
+		Segment     models.BroadcastSegment       `json:"segment"`
+		ScheduledAt *time.Time                    `json:"scheduled_at"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if input.FlexMessage.Title == "" || input.FlexMessage.ImageUrl == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Flex message title and image URL are required"})
+	}
+	if err := validateBroadcastSegment(input.Segment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	now := time.Now()
+	scheduledAt := now
+	if input.ScheduledAt != nil {
+		if input.ScheduledAt.Before(now.Add(-time.Minute)) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Scheduled time must be in the future"})
+		}
+		scheduledAt = *input.ScheduledAt
+	}
+
+	title := input.Title
+	if title == "" {
+		title = input.FlexMessage.Title
+	}
+
+	broadcast := models.Broadcast{
+		TenantID:    tenant.Value(c.UserContext()),
+		Title:       title,
+		FlexMessage: input.FlexMessage,
+		Segment:     input.Segment,
+		Status:      "scheduled",
+		ScheduledAt: scheduledAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	result, err := bc.broadcastCollection.InsertOne(c.UserContext(), broadcast)
+	if err != nil {
+		log.Printf("CreateBroadcast: Database error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create broadcast"})
+	}
+	broadcast.ID = result.InsertedID.(primitive.ObjectID)
+
+	return c.Status(fiber.StatusCreated).JSON(broadcast)
+}
+
+// GetBroadcasts - รายการ broadcast ล่าสุด
+func (bc *BroadcastController) GetBroadcasts(c *fiber.Ctx) error {
+	filter := tenant.Scope(c.UserContext(), bson.M{})
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	q := utils.ListQuery{Sort: bson.D{{Key: "created_at", Value: -1}}}
+	broadcasts, page, err := findListPage[models.Broadcast](c, bc.broadcastCollection, filter, q, nil, 50, 100)
+	if err != nil {
+		return listPageFailed(c, err, "Failed to fetch broadcasts")
+	}
+
+	return c.JSON(utils.ListPage{Items: broadcasts, Pagination: page})
+}
+
+func (bc *BroadcastController) GetBroadcast(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid broadcast ID"})
+	}
+
+	var broadcast models.Broadcast
+	err = bc.broadcastCollection.FindOne(c.UserContext(), tenant.Scope(c.UserContext(), bson.M{"_id": id})).Decode(&broadcast)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Broadcast not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch broadcast"})
+	}
+
+	return c.JSON(broadcast)
+}
+
+// GetBroadcastRecipients - สถานะการส่งรายผู้รับ (กรองด้วย ?status=failed ได้)
+func (bc *BroadcastController) GetBroadcastRecipients(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid broadcast ID"})
+	}
+
+	// ผู้รับไม่มี tenant_id จึงต้องตรวจว่า broadcast เป็นของ tenant นี้ก่อน
+	count, err := bc.broadcastCollection.CountDocuments(c.UserContext(), tenant.Scope(c.UserContext(), bson.M{"_id": id}))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch broadcast"})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Broadcast not found"})
+	}
+
+	filter := bson.M{"broadcast_id": id}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	q := utils.ListQuery{Sort: bson.D{{Key: "_id", Value: 1}}}
+	recipients, page, err := findListPage[models.BroadcastRecipient](c, bc.recipientCollection, filter, q, nil, 50, 500)
+	if err != nil {
+		return listPageFailed(c, err, "Failed to fetch recipients")
+	}
+
+	return c.JSON(utils.ListPage{Items: recipients, Pagination: page})
+}
+
+// CancelBroadcast - ยกเลิก broadcast ที่ยังไม่ส่งหรือกำลังส่งอยู่ (ชุดที่ส่งไปแล้วเรียกคืนไม่ได้)
+func (bc *BroadcastController) CancelBroadcast(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid broadcast ID"})
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var broadcast models.Broadcast
+	err = bc.broadcastCollection.FindOneAndUpdate(
+		c.UserContext(),
+		tenant.Scope(c.UserContext(), bson.M{"_id": id, "status": bson.M{"$in": []string{"scheduled", "sending"}}}),
+		bson.M{"$set": bson.M{"status": "cancelled", "updated_at": time.Now()}},
+		opts,
+	).Decode(&broadcast)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Broadcast not found or already finished"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel broadcast"})
+	}
+
+	return c.JSON(broadcast)
+}
+
+// GetSegmentCount - นับจำนวนผู้รับของ segment ก่อนสร้าง broadcast
+func (bc *BroadcastController) GetSegmentCount(c *fiber.Ctx) error {
+	segment := models.BroadcastSegment{
+		Type: c.Query("type"),
+		Tier: c.QueryInt("tier", 0),
+	}
+	if err := validateBroadcastSegment(segment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	userIDs, err := bc.resolveRecipients(c.UserContext(), segment)
+	if err != nil {
+		log.Printf("GetSegmentCount: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to resolve recipients"})
+	}
+
+	return c.JSON(fiber.Map{"segment": segment, "count": len(userIDs)})
+}
+
+// resolveRecipients คืนรายชื่อ LINE user ID ของ segment ภายใน tenant ของ ctx
+func (bc *BroadcastController) resolveRecipients(ctx context.Context, segment models.BroadcastSegment) ([]string, error) {
+	var values []interface{}
+	var err error
+
+	switch segment.Type {
+	case "all":
+		values, err = bc.clientCollection.Distinct(ctx, "user_id", tenant.Scope(ctx, bson.M{}))
+	case "tier":
+		values, err = bc.missionCollection.Distinct(ctx, "user_id", tenant.Scope(ctx, bson.M{
+			"status":       bson.M{"$in": []string{"processing", "pending"}},
+			"current_tier": segment.Tier,
+		}))
+	case "awaiting_reward":
+		values, err = bc.missionCollection.Distinct(ctx, "user_id", tenant.Scope(ctx, bson.M{
+			"status":       "processing",
+			"tiers.status": "awaiting_reward",
+		}))
+	case "no_mission":
+		pipeline := mongo.Pipeline{
+			bson.D{{Key: "$match", Value: tenant.Scope(ctx, bson.M{})}},
+			// นับเฉพาะมิชชันของ tenant เดียวกัน (มิชชันของ tenant อื่นไม่ทำให้ลูกค้าหลุดจากกลุ่มนี้)
+			bson.D{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: bc.missionCollection.Name()},
+				{Key: "let", Value: bson.M{"user_id": "$user_id"}},
+				{Key: "pipeline", Value: mongo.Pipeline{
+					bson.D{{Key: "$match", Value: tenant.Scope(ctx, bson.M{
+						"$expr": bson.M{"$eq": bson.A{"$user_id", "$$user_id"}},
+					})}},
+					bson.D{{Key: "$limit", Value: 1}},
+					bson.D{{Key: "$project", Value: bson.M{"_id": 1}}},
+				}},
+				{Key: "as", Value: "missions"},
+			}}},
+			bson.D{{Key: "$match", Value: bson.M{"missions": bson.M{"$size": 0}}}},
+			bson.D{{Key: "$project", Value: bson.M{"user_id": 1}}},
+		}
+		cursor, aggErr := bc.clientCollection.Aggregate(ctx, pipeline)
+		if aggErr != nil {
+			return nil, aggErr
+		}
+		defer cursor.Close(ctx)
+
+		var clients []models.Client
+		if err := cursor.All(ctx, &clients); err != nil {
+			return nil, err
+		}
+		for _, client := range clients {
+			values = append(values, client.UserID)
+		}
+	default:
+		return nil, fmt.Errorf("unknown segment type: %s", segment.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if userID, ok := value.(string); ok && userID != "" {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
+// ProcessBroadcasts ส่ง broadcast ที่ถึงเวลาแล้ว ทำงานเป็น background process
+// รับ broadcast ที่ยัง "sending" แต่ lease หมดอายุไปส่งต่อด้วย (process ที่ส่งอยู่หยุดกลางคัน)
+func (bc *BroadcastController) ProcessBroadcasts() {
+	log.Println("Starting ProcessBroadcasts")
+	for {
+		ctx := context.Background()
+		now := time.Now()
+		owner := primitive.NewObjectID().Hex()
+
+		var broadcast models.Broadcast
+		err := bc.broadcastCollection.FindOneAndUpdate(
+			ctx,
+			bson.M{"$or": []bson.M{
+				{"status": "scheduled", "scheduled_at": bson.M{"$lte": now}},
+				{"status": "sending", "lease_until": bson.M{"$lt": now}},
+			}},
+			bson.M{
+				"$set": bson.M{"status": "sending", "lease_owner": owner, "lease_until": now.Add(broadcastLeaseDuration), "updated_at": now},
+				"$min": bson.M{"started_at": now},
+			},
+			options.FindOneAndUpdate().SetSort(bson.M{"scheduled_at": 1}).SetReturnDocument(options.After),
+		).Decode(&broadcast)
+		if err == mongo.ErrNoDocuments {
+			time.Sleep(30 * time.Second)
+			continue
+		}
+		if err != nil {
+			log.Printf("Error fetching scheduled broadcast: %v", err)
+			time.Sleep(30 * time.Second)
+			continue
+		}
+
+		if err := bc.sendBroadcast(tenant.With(ctx, broadcast.TenantID), &broadcast); err != nil {
+			log.Printf("Broadcast %s failed: %v", broadcast.ID.Hex(), err)
+			bc.broadcastCollection.UpdateOne(ctx, bson.M{"_id": broadcast.ID, "status": "sending", "lease_owner": owner}, bson.M{
+				"$set":   bson.M{"status": "failed", "error": err.Error(), "updated_at": time.Now()},
+				"$unset": bson.M{"lease_owner": "", "lease_until": ""},
+			})
+		}
+	}
+}
+
+// errBroadcastLeaseLost worker อื่นรับ broadcast ไปส่งต่อแล้ว (lease หมดอายุระหว่างส่ง)
+var errBroadcastLeaseLost = errors.New("broadcast lease lost")
+
+// renewBroadcastLease ต่ออายุ lease ก่อนส่งแต่ละชุด และคืนสถานะล่าสุด (เผื่อ admin ยกเลิกระหว่างส่ง)
+func (bc *BroadcastController) renewBroadcastLease(ctx context.Context, broadcast *models.Broadcast) (string, error) {
+	now := time.Now()
+	var current models.Broadcast
+	err := bc.broadcastCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": broadcast.ID, "lease_owner": broadcast.LeaseOwner},
+		bson.M{"$set": bson.M{"lease_until": now.Add(broadcastLeaseDuration)}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		return "", errBroadcastLeaseLost
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to renew broadcast lease: %v", err)
+	}
+	return current.Status, nil
+}
+
+// sendBroadcast สร้างรายชื่อผู้รับครั้งแรก แล้วส่งให้ผู้รับที่ยัง pending ทีละชุด
+// เมื่อส่งต่อจาก worker ที่หยุดไป จะใช้รายชื่อเดิมและส่งเฉพาะผู้รับที่ยังไม่ได้ส่ง
+// (ชุดที่ส่งสำเร็จแล้วแต่ยังไม่ทันบันทึกผลก่อน process หยุดอาจถูกส่งซ้ำ)
+func (bc *BroadcastController) sendBroadcast(ctx context.Context, broadcast *models.Broadcast) error {
+	existing, err := bc.recipientCollection.CountDocuments(ctx, bson.M{"broadcast_id": broadcast.ID})
+	if err != nil {
+		return fmt.Errorf("failed to count recipients: %v", err)
+	}
+
+	if existing > 0 {
+		log.Printf("Broadcast %s: resuming, %d recipients already created", broadcast.ID.Hex(), existing)
+	} else {
+		userIDs, err := bc.resolveRecipients(ctx, broadcast.Segment)
+		if err != nil {
+			return fmt.Errorf("failed to resolve recipients: %v", err)
+		}
+
+		log.Printf("Broadcast %s: sending to %d recipients", broadcast.ID.Hex(), len(userIDs))
+
+		if len(userIDs) > 0 {
+			recipients := make([]interface{}, 0, len(userIDs))
+			for _, userID := range userIDs {
+				recipients = append(recipients, models.BroadcastRecipient{
+					BroadcastID: broadcast.ID,
+					UserID:      userID,
+					Status:      "pending",
+				})
+			}
+			if _, err := bc.recipientCollection.InsertMany(ctx, recipients); err != nil {
+				return fmt.Errorf("failed to create recipients: %v", err)
+			}
+		}
+
+		_, err = bc.broadcastCollection.UpdateOne(ctx, bson.M{"_id": broadcast.ID}, bson.M{"$set": bson.M{"total_recipients": len(userIDs)}})
+		if err != nil {
+			return fmt.Errorf("failed to update recipient count: %v", err)
+		}
+	}
+
+	var lastID primitive.ObjectID
+	for {
+		status, err := bc.renewBroadcastLease(ctx, broadcast)
+		if err == errBroadcastLeaseLost {
+			log.Printf("Broadcast %s: lease taken over by another worker, stopping", broadcast.ID.Hex())
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if status == "cancelled" {
+			log.Printf("Broadcast %s cancelled during sending", broadcast.ID.Hex())
+			_, err := bc.recipientCollection.UpdateMany(ctx,
+				bson.M{"broadcast_id": broadcast.ID, "status": "pending"},
+				bson.M{"$set": bson.M{"status": "cancelled"}},
+			)
+			return err
+		}
+
+		// อ่านผู้รับที่ยัง pending ต่อจากชุดก่อน (เรียงตาม _id จึงไม่วนซ้ำแม้บันทึกผลของชุดก่อนไม่สำเร็จ)
+		filter := bson.M{"broadcast_id": broadcast.ID, "status": "pending"}
+		if !lastID.IsZero() {
+			filter["_id"] = bson.M{"$gt": lastID}
+		}
+		opts := options.Find().
+			SetSort(bson.D{{Key: "_id", Value: 1}}).
+			SetLimit(broadcastChunkSize).
+			SetProjection(bson.M{"user_id": 1})
+		cursor, err := bc.recipientCollection.Find(ctx, filter, opts)
+		if err != nil {
+			return fmt.Errorf("failed to fetch pending recipients: %v", err)
+		}
+		var recipients []models.BroadcastRecipient
+		if err := cursor.All(ctx, &recipients); err != nil {
+			return fmt.Errorf("failed to decode pending recipients: %v", err)
+		}
+		if len(recipients) == 0 {
+			break
+		}
+
+		chunk := make([]string, 0, len(recipients))
+		for _, recipient := range recipients {
+			chunk = append(chunk, recipient.UserID)
+		}
+		lastID = recipients[len(recipients)-1].ID
+		bc.sendChunk(ctx, broadcast, chunk)
+	}
+
+	now := time.Now()
+	_, err = bc.broadcastCollection.UpdateOne(ctx,
+		bson.M{"_id": broadcast.ID, "status": "sending", "lease_owner": broadcast.LeaseOwner},
+		bson.M{
+			"$set":   bson.M{"status": "completed", "completed_at": now, "updated_at": now},
+			"$unset": bson.M{"lease_owner": "", "lease_until": ""},
+		},
+	)
+	return err
+}
+
+// sendChunk ส่ง multicast หนึ่งชุดและบันทึกผลรายผู้รับ
+func (bc *BroadcastController) sendChunk(ctx context.Context, broadcast *models.Broadcast, userIDs []string) {
+	now := time.Now()
+	recipientFilter := bson.M{"broadcast_id": broadcast.ID, "user_id": bson.M{"$in": userIDs}}
+
+	if err := bc.lineController.MulticastFlexMessage(ctx, userIDs, broadcast.FlexMessage); err != nil {
+		log.Printf("Broadcast %s: multicast failed for %d recipients: %v", broadcast.ID.Hex(), len(userIDs), err)
+		eventbus.Publish(ctx, eventbus.MessageFailed, "", map[string]interface{}{
+			"broadcast_id": broadcast.ID.Hex(),
+			"recipients":   len(userIDs),
+			"title":        broadcast.FlexMessage.Title,
+			"error":        err.Error(),
+		})
+		bc.recipientCollection.UpdateMany(ctx, recipientFilter, bson.M{"$set": bson.M{"status": "failed", "error": err.Error()}})
+		bc.broadcastCollection.UpdateOne(ctx, bson.M{"_id": broadcast.ID}, bson.M{
+			"$inc": bson.M{"failed_count": len(userIDs)},
+			"$set": bson.M{"updated_at": now},
+		})
+		return
+	}
+
+	bc.recipientCollection.UpdateMany(ctx, recipientFilter, bson.M{"$set": bson.M{"status": "sent", "sent_at": now}})
+	bc.broadcastCollection.UpdateOne(ctx, bson.M{"_id": broadcast.ID}, bson.M{
+		"$inc": bson.M{"sent_count": len(userIDs)},
+		"$set": bson.M{"updated_at": now},
+	})
+
+	// บันทึกลง inbox ของผู้รับ เหมือนข้อความที่ส่งจากมิชชัน
+	messageLogs := make([]interface{}, 0, len(userIDs))
+	for _, userID := range userIDs {
+		messageLogs = append(messageLogs, models.MessageLog{
+			TenantID:    broadcast.TenantID,
+			UserID:      userID,
+			Status:      "unread",
+			BroadcastID: broadcast.ID,
+			SentAt:      now,
+			FlexContent: models.FlexContent{
+				Title:          broadcast.FlexMessage.Title,
+				Description:    broadcast.FlexMessage.Description,
+				SubDescription: broadcast.FlexMessage.SubDescription,
+			},
+		})
+	}
+	if _, err := bc.messageCollection.InsertMany(ctx, messageLogs); err != nil {
+		log.Printf("Broadcast %s: message sent, but failed to log: %v", broadcast.ID.Hex(), err)
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"go-server/config"
 	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
 	"io"
 	"log"
 	"net/http"
+	"reflect"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,88 +22,41 @@ import (
 )
 
 type ClientController struct {
-	collection       *mongo.Collection
-	configCollection *mongo.Collection
+	collection    *mongo.Collection
+	configService *config.Service
 }
 
-func NewClientController(collection, configCollection *mongo.Collection) *ClientController {
+func NewClientController(collection *mongo.Collection, configService *config.Service) *ClientController {
 	return &ClientController{
-		collection:       collection,
-		configCollection: configCollection,
+		collection:    collection,
+		configService: configService,
 	}
 }
 
 func (cc *ClientController) GetAllClients(c *fiber.Ctx) error {
 	log.Println("GetAllClients: Starting")
 
-	// รับ query parameters สำหรับ pagination
-	page := c.QueryInt("page", 1)
-	limit := c.QueryInt("limit", 10)
-	search := c.Query("search", "")
-
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 10
-	}
-
-	skip := (page - 1) * limit
-
-	// สร้าง filter สำหรับการค้นหา
-	filter := bson.M{}
-	if search != "" {
-		filter = bson.M{
-			"$or": []bson.M{
-				{"display_name": bson.M{"$regex": search, "$options": "i"}},
-				{"user_id": bson.M{"$regex": search, "$options": "i"}},
-				{"phone_number": bson.M{"$regex": search, "$options": "i"}},
-			},
-		}
+	// สร้าง filter สำหรับการค้นหา (?search และเงื่อนไขตาม ClientQuerySchema) ค่าเริ่มต้นเรียงตามวันที่อัปเดตล่าสุด
+	q, err := parseListQuery(c, ClientQuerySchema)
+	if err != nil {
+		return listQueryFailed(c, err)
 	}
 
-	// ตัวเลือกสำหรับการ query
-	findOptions := options.Find()
-	findOptions.SetSkip(int64(skip))
-	findOptions.SetLimit(int64(limit))
-	findOptions.SetSort(bson.D{{"updated_at", -1}}) // เรียงตามวันที่อัปเดตล่าสุด
-
-	// ดึงข้อมูล clients
-	cursor, err := cc.collection.Find(context.Background(), filter, findOptions)
+	// ดึงข้อมูล clients (?cursor= หรือ ?page= ดู findListPage)
+	filter := tenant.Scope(c.UserContext(), q.Filter)
+	clients, page, err := findListPage[models.Client](c, cc.collection, filter, q, q.Projection, 10, 100)
 	if err != nil {
 		log.Printf("GetAllClients: Database error: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
-	}
-	defer cursor.Close(context.Background())
-
-	var clients []models.Client
-	if err = cursor.All(context.Background(), &clients); err != nil {
-		log.Printf("GetAllClients: Error decoding clients: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error decoding clients"})
+		return listPageFailed(c, err, "Database error")
 	}
-
-	// นับจำนวนรวมทั้งหมด
-	totalCount, err := cc.collection.CountDocuments(context.Background(), filter)
+	items, err := projectItems(clients, reflect.TypeOf(models.Client{}), q.Fields)
 	if err != nil {
-		log.Printf("GetAllClients: Error counting documents: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error counting documents"})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error encoding clients"})
 	}
 
-	totalPages := (totalCount + int64(limit) - 1) / int64(limit)
-
-	log.Printf("GetAllClients: Found %d clients (page %d of %d)", len(clients), page, totalPages)
+	log.Printf("GetAllClients: Found %d clients (has_next %v)", len(clients), page.HasNext)
 
-	return c.JSON(fiber.Map{
-		"clients": clients,
-		"pagination": fiber.Map{
-			"current_page": page,
-			"total_pages":  totalPages,
-			"total_count":  totalCount,
-			"limit":        limit,
-			"has_next":     page < int(totalPages),
-			"has_prev":     page > 1,
-		},
-	})
+	return c.JSON(utils.ListPage{Items: items, Pagination: page})
 }
 
 func (cc *ClientController) GetClientByUserId(c *fiber.Ctx) error {
@@ -121,6 +78,8 @@ func (cc *ClientController) GetClientByUserId(c *fiber.Ctx) error {
 		log.Printf("GetClientByUserId: Using user_id filter: %s", idParam)
 	}
 
+	filter = tenant.Scope(c.UserContext(), filter)
+
 	var client models.Client
 	err := cc.collection.FindOne(context.Background(), filter).Decode(&client)
 	if err != nil {
@@ -158,6 +117,8 @@ func (cc *ClientController) DeleteClient(c *fiber.Ctx) error {
 	}
 
 	// ตรวจสอบว่า client มีอยู่หรือไม่ก่อนลบ
+	filter = tenant.Scope(c.UserContext(), filter)
+
 	var client models.Client
 	err := cc.collection.FindOne(context.Background(), filter).Decode(&client)
 	if err != nil {
@@ -215,7 +176,13 @@ func (cc *ClientController) UpsertClient(c *fiber.Ctx) error {
 	log.Printf("UpsertClient: Received client data: %+v", client)
 
 	now := primitive.NewDateTimeFromTime(time.Now())
-	filter := bson.M{"user_id": client.UserID}
+	filter := tenant.Scope(c.UserContext(), bson.M{"user_id": client.UserID})
+	setOnInsert := bson.M{
+		"created_at": now,
+	}
+	if tenantID := tenant.Value(c.UserContext()); tenantID != "" {
+		setOnInsert["tenant_id"] = tenantID
+	}
 	update := bson.M{
 		"$set": bson.M{
 			"display_name":   client.DisplayName,
@@ -223,9 +190,7 @@ func (cc *ClientController) UpsertClient(c *fiber.Ctx) error {
 			"status_message": client.StatusMessage,
 			"updated_at":     now,
 		},
-		"$setOnInsert": bson.M{
-			"created_at": now,
-		},
+		"$setOnInsert": setOnInsert,
 	}
 	opts := options.Update().SetUpsert(true)
 
@@ -267,7 +232,7 @@ func (cc *ClientController) CheckPhoneNumber(c *fiber.Ctx) error {
 	log.Printf("CheckPhoneNumber: Checking phone number for user ID: %s", userID)
 
 	var client models.Client
-	err := cc.collection.FindOne(context.Background(), bson.M{"user_id": userID}).Decode(&client)
+	err := cc.collection.FindOne(context.Background(), tenant.Scope(c.UserContext(), bson.M{"user_id": userID})).Decode(&client)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			log.Printf("CheckPhoneNumber: Client not found for user ID: %s", userID)
@@ -363,7 +328,7 @@ func (cc *ClientController) UpdatePhoneNumber(c *fiber.Ctx) error {
 	log.Printf("UpdatePhoneNumber: Received phone number: %s", updateData.PhoneNumber)
 
 	var config models.Config
-	err := cc.configCollection.FindOne(context.Background(), bson.M{}).Decode(&config)
+	err := cc.configService.Load(c.UserContext(), &config)
 	if err != nil {
 		log.Printf("UpdatePhoneNumber: Error fetching config: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"success": false, "error": "Failed to fetch config"})
@@ -401,7 +366,7 @@ func (cc *ClientController) UpdatePhoneNumber(c *fiber.Ctx) error {
 		},
 	}
 
-	result, err := cc.collection.UpdateOne(context.Background(), bson.M{"user_id": userID}, update)
+	result, err := cc.collection.UpdateOne(context.Background(), tenant.Scope(c.UserContext(), bson.M{"user_id": userID}), update)
 	if err != nil {
 		log.Printf("UpdatePhoneNumber: Database error: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"success": false, "error": "Database error"})
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"go-server/config"
+	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClientTimelineController รวมประวัติของลูกค้าหนึ่งคนจากมิชชัน, tbl_events, tbl_logs_message และ tbl_logs
+// ให้ support ตอบได้ว่าทำไมมิชชันผ่านหรือไม่ผ่านโดยไม่ต้องเปิด MongoDB
+type ClientTimelineController struct {
+	missionCollection *mongo.Collection
+	eventCollection   *mongo.Collection
+	messageCollection *mongo.Collection
+	logCollection     *mongo.Collection
+	configService     *config.Service
+}
+
+func NewClientTimelineController(missionCollection, eventCollection, messageCollection, logCollection *mongo.Collection, configService *config.Service) *ClientTimelineController {
+	return &ClientTimelineController{
+		missionCollection: missionCollection,
+		eventCollection:   eventCollection,
+		messageCollection: messageCollection,
+		logCollection:     logCollection,
+		configService:     configService,
+	}
+}
+
+// timelineSourceLimit จำนวนเอกสารสูงสุดที่อ่านจากแต่ละแหล่ง (ล่าสุดก่อน)
+const timelineSourceLimit = 1000
+
+// GetTimeline - GET /api/clients/:userId/timeline?from=&to=&order=asc|desc
+func (tc *ClientTimelineController) GetTimeline(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	userID := c.Params("userId")
+	if userID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "User ID is required"})
+	}
+	dateFilter, err := utils.ParseDateFilter(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var config models.Config
+	if err := tc.configService.Load(ctx, &config); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
+	}
+	// กติกาของแต่ละรายการปรับตาม overlay ที่มีผล ณ เวลาของรายการนั้น
+	overlays, err := tc.configService.Overlays(ctx)
+	if err != nil {
+		log.Printf("GetTimeline: Failed to fetch config overlays: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config overlays"})
+	}
+
+	// มิชชันอ่านทั้งหมดเพราะ level ที่อยู่ในช่วงเวลาอาจเป็นของมิชชันที่เริ่มก่อนช่วงนั้น
+	missions, full, err := findTimeline[models.Mission](ctx, tc.missionCollection, bson.M{"user_id": userID}, "created_at")
+	if err != nil {
+		log.Printf("GetTimeline: Failed to fetch missions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch missions"})
+	}
+	truncated := full
+
+	entries := make([]utils.TimelineEntry, 0)
+	rulesByMission := make(map[primitive.ObjectID]utils.TimelineRules, len(missions))
+	missionIDs := make([]primitive.ObjectID, 0, len(missions))
+	for _, mission := range missions {
+		rules := utils.TimelineRules{Tiers: mission.TierRules, Source: "mission_snapshot", Overlays: overlays}
+		if len(rules.Tiers) == 0 {
+			rules = utils.TimelineRules{Tiers: config.Tiers, Source: "current_config", Overlays: overlays}
+		}
+		rulesByMission[mission.ID] = rules
+		missionIDs = append(missionIDs, mission.ID)
+		entries = append(entries, utils.MissionTimeline(mission, rules)...)
+	}
+	rulesFor := func(missionID primitive.ObjectID) utils.TimelineRules {
+		if rules, ok := rulesByMission[missionID]; ok {
+			return rules
+		}
+		return utils.TimelineRules{Tiers: config.Tiers, Source: "current_config", Overlays: overlays}
+	}
+
+	if len(missionIDs) > 0 {
+		eventFilter := bson.M{"mission_id": bson.M{"$in": missionIDs}}
+		if dateFilter != nil {
+			eventFilter["expire_time"] = dateFilter
+		}
+		events, full, err := findTimeline[models.ExpirationEvent](ctx, tc.eventCollection, eventFilter, "expire_time")
+		if err != nil {
+			log.Printf("GetTimeline: Failed to fetch events: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch events"})
+		}
+		truncated = truncated || full
+		for _, event := range events {
+			entries = append(entries, utils.EventTimeline(event, rulesFor(event.MissionID)))
+		}
+	}
+
+	messageFilter := bson.M{"user_id": userID}
+	if dateFilter != nil {
+		messageFilter["sent_at"] = dateFilter
+	}
+	messages, full, err := findTimeline[models.MessageLog](ctx, tc.messageCollection, messageFilter, "sent_at")
+	if err != nil {
+		log.Printf("GetTimeline: Failed to fetch messages: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch messages"})
+	}
+	truncated = truncated || full
+	for _, message := range messages {
+		entries = append(entries, utils.MessageTimeline(message))
+	}
+
+	claimFilter := bson.M{"user_id": userID}
+	if dateFilter != nil {
+		claimFilter["$or"] = []bson.M{{"created_at": dateFilter}, {"callback_time": dateFilter}}
+	}
+	claims, full, err := findTimeline[models.Log](ctx, tc.logCollection, claimFilter, "created_at")
+	if err != nil {
+		log.Printf("GetTimeline: Failed to fetch claims: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch claims"})
+	}
+	truncated = truncated || full
+	for _, claim := range claims {
+		missionID, _ := primitive.ObjectIDFromHex(claim.MissionID)
+		entries = append(entries, utils.ClaimTimeline(claim, rulesFor(missionID))...)
+	}
+
+	// รายการของมิชชันและ claim ที่อยู่นอกช่วงเวลาที่ขอถูกตัดออกหลังรวม
+	if dateFilter != nil {
+		entries = filterTimeline(entries, dateFilter)
+	}
+	utils.SortTimeline(entries, c.Query("order") == "desc")
+
+	return c.JSON(fiber.Map{
+		"user_id":   userID,
+		"timezone":  utils.AnalyticsTimezone,
+		"entries":   entries,
+		"count":     len(entries),
+		"truncated": truncated,
+	})
+}
+
+// findTimeline อ่านเอกสารของ tenant ใน ctx ล่าสุดก่อนไม่เกิน timelineSourceLimit และบอกว่าถูกตัดหรือไม่
+func findTimeline[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, sortField string) ([]T, bool, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: -1}}).
+		SetLimit(timelineSourceLimit + 1)
+	cursor, err := collection.Find(ctx, tenant.Scope(ctx, filter), opts)
+	if err != nil {
+		return nil, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []T
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, false, err
+	}
+	if len(docs) > timelineSourceLimit {
+		return docs[:timelineSourceLimit], true, nil
+	}
+	return docs, false, nil
+}
+
+// filterTimeline เก็บเฉพาะรายการที่เวลาอยู่ในช่วง $gte/$lt ของ dateFilter
+func filterTimeline(entries []utils.TimelineEntry, dateFilter bson.M) []utils.TimelineEntry {
+	from, _ := dateFilter["$gte"].(time.Time)
+	to, _ := dateFilter["$lt"].(time.Time)
+	filtered := entries[:0]
+	for _, entry := range entries {
+		if (!from.IsZero() && entry.Time.Before(from)) || (!to.IsZero() && !entry.Time.Before(to)) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
@@ -1,43 +1,208 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"go-server/blobstore"
+	"go-server/config"
 	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
 	"io"
 	"log"
-	"net/http"
-	"path/filepath"
-	"strings"
+	"strconv"
+	"time"
 
-	"cloud.google.com/go/storage"
-	firebase "firebase.google.com/go"
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"google.golang.org/api/option"
 )
 
 type ConfigController struct {
-	Collection *mongo.Collection
+	Collection         *mongo.Collection
+	RevisionCollection *mongo.Collection
+	configService      *config.Service
 }
 
-func NewConfigController(collection *mongo.Collection) *ConfigController {
+func NewConfigController(configService *config.Service, revisionCollection *mongo.Collection) *ConfigController {
 	return &ConfigController{
-		Collection: collection,
+		Collection:         configService.Collection(),
+		RevisionCollection: revisionCollection,
+		configService:      configService,
 	}
 }
 
+var errConfigConflict = errors.New("config was modified by another request")
+
+// configSetDocument แปลง config ทั้งก้อนเป็น $set โดยตัด _id และ revision ออก
+// (revision ถูกเพิ่มด้วย $inc ใน saveConfigUpdate เท่านั้น)
+func configSetDocument(config models.Config) (bson.M, error) {
+	data, err := bson.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var set bson.M
+	if err := bson.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	delete(set, "_id")
+	delete(set, "revision")
+	return set, nil
+}
+
+// saveConfigUpdate บันทึกการแก้ไข config ของ tenant ใน ctx และสร้าง revision ใหม่ใน tbl_config_revisions
+// ใช้ revision เดิมเป็นเงื่อนไข เพื่อไม่ให้การบันทึกพร้อมกันเขียนทับกัน
+// secret ใน set จะถูกเข้ารหัสก่อนบันทึก และ config ที่คืนกลับไปถูกซ่อน secret แล้ว
+func (cc *ConfigController) saveConfigUpdate(ctx context.Context, set bson.M, revision models.ConfigRevision) (models.Config, error) {
+	tenantFilter := tenant.Filter(tenant.FromContext(ctx))
+
+	// เก็บเอกสารเดิมแบบดิบไว้คืนค่าถ้าบันทึก revision ไม่สำเร็จ
+	var current models.Config
+	var currentRaw bson.Raw
+	err := cc.Collection.FindOne(ctx, tenantFilter).Decode(&currentRaw)
+	exists := err == nil
+	if err != nil && err != mongo.ErrNoDocuments {
+		return current, fmt.Errorf("failed to fetch config: %v", err)
+	}
+	if exists {
+		if err := bson.Unmarshal(currentRaw, &current); err != nil {
+			return current, fmt.Errorf("failed to decode config: %v", err)
+		}
+		if current.Revision == 0 {
+			if err := cc.saveBaselineRevision(ctx, current); err != nil {
+				return current, err
+			}
+		}
+	}
+
+	if err := utils.SealConfigSecrets(set, current); err != nil {
+		return current, err
+	}
+
+	// tenant ของเอกสารมาจาก ctx เท่านั้น ไม่ให้ย้าย config ข้าม tenant ผ่าน body
+	delete(set, "tenant_id")
+	if tenantID := tenant.Value(ctx); tenantID != "" {
+		set["tenant_id"] = tenantID
+	}
+
+	filter := tenantFilter
+	if exists {
+		filter = bson.M{"_id": current.ID}
+		if current.Revision == 0 {
+			// config เดิมก่อนมีระบบ revision ยังไม่มี field นี้
+			filter["revision"] = bson.M{"$in": bson.A{0, nil}}
+		} else {
+			filter["revision"] = current.Revision
+		}
+	}
+
+	update := bson.M{"$set": set, "$inc": bson.M{"revision": 1}}
+	opts := options.FindOneAndUpdate().SetUpsert(!exists).SetReturnDocument(options.After)
+
+	var updatedConfig models.Config
+	err = cc.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedConfig)
+	if err == mongo.ErrNoDocuments {
+		return current, errConfigConflict
+	}
+	if err != nil {
+		return current, fmt.Errorf("failed to update config: %v", err)
+	}
+
+	changes, err := utils.DiffDocuments(current, updatedConfig, "_id", "revision")
+	if err != nil {
+		log.Printf("Error diffing config revision %d: %v", updatedConfig.Revision, err)
+	}
+	utils.MaskConfigChanges(changes)
+
+	snapshot := updatedConfig
+	revision.TenantID = tenant.Value(ctx)
+	revision.Revision = updatedConfig.Revision
+	revision.Changes = changes
+	revision.Config = &snapshot
+	revision.CreatedAt = time.Now()
+	if _, err := cc.RevisionCollection.InsertOne(ctx, revision); err != nil {
+		// ไม่ให้ config เปลี่ยนโดยไม่มี revision (ประวัติและ rollback จะขาดช่วง) จึงคืนค่าเอกสารเดิม
+		if restoreErr := cc.restoreConfig(ctx, updatedConfig, currentRaw); restoreErr != nil {
+			log.Printf("Error restoring config after failed revision %d: %v", updatedConfig.Revision, restoreErr)
+		}
+		return current, fmt.Errorf("failed to save config revision %d: %v", updatedConfig.Revision, err)
+	}
+
+	// อัปเดต cache ทันทีโดยไม่ต้องรอ change stream
+	if err := cc.configService.Refresh(ctx); err != nil {
+		log.Printf("Error refreshing config cache: %v", err)
+	}
+
+	utils.MaskConfigSecrets(&updatedConfig)
+	return updatedConfig, nil
+}
+
+// saveBaselineRevision เก็บ config ที่มีอยู่ก่อนระบบ revision เป็น revision 0 เพื่อให้ rollback การแก้ไขครั้งแรกได้
+func (cc *ConfigController) saveBaselineRevision(ctx context.Context, current models.Config) error {
+	count, err := cc.RevisionCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{"revision": 0}))
+	if err != nil {
+		return fmt.Errorf("failed to check baseline revision: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	snapshot := current
+	_, err = cc.RevisionCollection.InsertOne(ctx, models.ConfigRevision{
+		TenantID:  tenant.Value(ctx),
+		Revision:  0,
+		Action:    "baseline",
+		Author:    "system",
+		Changes:   []models.ConfigChange{},
+		Config:    &snapshot,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save baseline revision: %v", err)
+	}
+	return nil
+}
+
+// restoreConfig คืน config เป็นเอกสารก่อนการบันทึก (ลบทิ้งถ้าเพิ่งถูกสร้าง) เฉพาะเมื่อยังไม่มีการบันทึกอื่นตามมา
+func (cc *ConfigController) restoreConfig(ctx context.Context, updated models.Config, previous bson.Raw) error {
+	filter := bson.M{"_id": updated.ID, "revision": updated.Revision}
+	if previous == nil {
+		_, err := cc.Collection.DeleteOne(ctx, filter)
+		return err
+	}
+	_, err := cc.Collection.ReplaceOne(ctx, filter, previous)
+	return err
+}
+
+// validationFailed ตอบกลับข้อผิดพลาดราย field
+func validationFailed(c *fiber.Ctx, errs utils.ValidationErrors) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error":  "Validation failed",
+		"fields": errs,
+	})
+}
+
+// configSaveError แปลง error จาก saveConfigUpdate เป็น response
+func configSaveError(c *fiber.Ctx, err error, message string) error {
+	if err == errConfigConflict {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Config was modified by another request, please reload and try again"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+}
+
 func (cc *ConfigController) GetConfig(c *fiber.Ctx) error {
 	var config models.Config
-	err := cc.Collection.FindOne(context.Background(), bson.M{}).Decode(&config)
+	err := cc.Collection.FindOne(c.UserContext(), tenant.Filter(tenant.FromContext(c.UserContext()))).Decode(&config)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Config not found"})
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
 	}
+	utils.MaskConfigSecrets(&config)
 	return c.JSON(config)
 }
 
@@ -47,14 +212,25 @@ func (cc *ConfigController) SaveConfig(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
-	filter := bson.M{}
-	update := bson.M{"$set": config}
+	errs := utils.ValidateTiers(config.Tiers)
+	errs = append(errs, utils.ValidateFlexMessages(config.FlexMessages)...)
+	errs = append(errs, utils.ValidateSiteTemplate(config.SiteTemplate)...)
+	if len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
 
-	var updatedConfig models.Config
-	err := cc.Collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&updatedConfig)
+	set, err := configSetDocument(config)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	updatedConfig, err := cc.saveConfigUpdate(c.UserContext(), set, models.ConfigRevision{
+		Action: "save",
+		Author: utils.RequestActor(c),
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save config"})
+		log.Printf("Error saving config: %v", err)
+		return configSaveError(c, err, "Failed to save config")
 	}
 
 	return c.JSON(updatedConfig)
@@ -69,17 +245,16 @@ func (cc *ConfigController) UpdateTierSettings(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	// เพิ่ม logging
-	fmt.Printf("Received tiers: %+v\n", tierSettings.Tiers)
-
-	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
-	filter := bson.M{}
-	update := bson.M{"$set": bson.M{"tiers": tierSettings.Tiers}}
+	if errs := utils.ValidateTiers(tierSettings.Tiers); len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
 
-	var updatedConfig models.Config
-	err := cc.Collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&updatedConfig)
+	updatedConfig, err := cc.saveConfigUpdate(c.UserContext(), bson.M{"tiers": tierSettings.Tiers}, models.ConfigRevision{
+		Action: "tiers",
+		Author: utils.RequestActor(c),
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update tier settings"})
+		return configSaveError(c, err, "Failed to update tier settings")
 	}
 
 	// เพิ่ม logging
@@ -106,20 +281,121 @@ func (cc *ConfigController) UpdateFlexMessageSettings(c *fiber.Ctx) error {
 	log.Printf("Get Reward: %+v", flexMessagesUpdate.FlexMessages.GetReward)
 	log.Printf("Reward Notification: %+v", flexMessagesUpdate.FlexMessages.RewardNotification)
 
-	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
-	filter := bson.M{}
-	update := bson.M{"$set": bson.M{"flex_messages": flexMessagesUpdate.FlexMessages}}
+	if errs := utils.ValidateFlexMessages(flexMessagesUpdate.FlexMessages); len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
 
-	var updatedConfig models.Config
-	err := cc.Collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&updatedConfig)
+	updatedConfig, err := cc.saveConfigUpdate(c.UserContext(), bson.M{"flex_messages": flexMessagesUpdate.FlexMessages}, models.ConfigRevision{
+		Action: "flex_messages",
+		Author: utils.RequestActor(c),
+	})
 	if err != nil {
 		log.Printf("Error updating flex messages: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update flex message settings"})
+		return configSaveError(c, err, "Failed to update flex message settings")
 	}
 
 	log.Printf("Flex messages updated successfully")
 	return c.JSON(updatedConfig)
 }
+func (cc *ConfigController) UpdateNotificationSettings(c *fiber.Ctx) error {
+	var notificationUpdate struct {
+		Notification models.NotificationConfig `json:"notification"`
+	}
+
+	if err := c.BodyParser(&notificationUpdate); err != nil {
+		log.Printf("Error parsing notification settings: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	settings := notificationUpdate.Notification
+	if settings.QuietHoursStart < 0 || settings.QuietHoursStart > 23 || settings.QuietHoursEnd < 0 || settings.QuietHoursEnd > 23 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Quiet hours must be between 0 and 23"})
+	}
+	if settings.DailyMessageCap < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Daily message cap must not be negative"})
+	}
+	if settings.Timezone != "" {
+		if _, err := time.LoadLocation(settings.Timezone); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid timezone"})
+		}
+	}
+
+	log.Printf("Received notification settings update: %+v", settings)
+
+	updatedConfig, err := cc.saveConfigUpdate(c.UserContext(), bson.M{"notification": settings}, models.ConfigRevision{
+		Action: "notification",
+		Author: utils.RequestActor(c),
+	})
+	if err != nil {
+		log.Printf("Error updating notification settings: %v", err)
+		return configSaveError(c, err, "Failed to update notification settings")
+	}
+
+	log.Printf("Notification settings updated successfully")
+	return c.JSON(updatedConfig)
+}
+
+func (cc *ConfigController) UpdateAlertSettings(c *fiber.Ctx) error {
+	var alertUpdate struct {
+		Alerts models.AlertConfig `json:"alerts"`
+	}
+
+	if err := c.BodyParser(&alertUpdate); err != nil {
+		log.Printf("Error parsing alert settings: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	settings := alertUpdate.Alerts
+	if settings.CheckIntervalMinutes < 0 || settings.RepeatAfterHours < 0 || settings.PendingClaimHours < 0 ||
+		settings.RewardExpiringHours < 0 || settings.DeadLetterThreshold < 0 || settings.BetApiMinRequests < 0 ||
+		settings.BetApiErrorRatePercent < 0 || settings.BetApiErrorRatePercent > 100 ||
+		settings.LineQuotaPercent < 0 || settings.LineQuotaPercent > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid alert thresholds"})
+	}
+
+	log.Printf("Received alert settings update: %+v", settings)
+
+	updatedConfig, err := cc.saveConfigUpdate(c.UserContext(), bson.M{"alerts": settings}, models.ConfigRevision{
+		Action: "alerts",
+		Author: utils.RequestActor(c),
+	})
+	if err != nil {
+		log.Printf("Error updating alert settings: %v", err)
+		return configSaveError(c, err, "Failed to update alert settings")
+	}
+
+	log.Printf("Alert settings updated successfully")
+	return c.JSON(updatedConfig)
+}
+
+// ValidateConfig - ตรวจสอบ config โดยไม่บันทึก ส่งเฉพาะส่วนที่ต้องการตรวจได้
+func (cc *ConfigController) ValidateConfig(c *fiber.Ctx) error {
+	var body struct {
+		Tiers        *[]models.TierDetail       `json:"tiers"`
+		FlexMessages *models.FlexMessages       `json:"flexMessages"`
+		SiteTemplate *models.SiteTemplateConfig `json:"siteTemplate"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	errs := utils.ValidationErrors{}
+	if body.Tiers != nil {
+		errs = append(errs, utils.ValidateTiers(*body.Tiers)...)
+	}
+	if body.FlexMessages != nil {
+		errs = append(errs, utils.ValidateFlexMessages(*body.FlexMessages)...)
+	}
+	if body.SiteTemplate != nil {
+		errs = append(errs, utils.ValidateSiteTemplate(*body.SiteTemplate)...)
+	}
+
+	return c.JSON(fiber.Map{
+		"valid":  len(errs) == 0,
+		"fields": errs,
+	})
+}
+
 func (cc *ConfigController) UploadImage(c *fiber.Ctx) error {
 	log.Println("Starting image upload...")
 
@@ -137,50 +413,29 @@ func (cc *ConfigController) UploadImage(c *fiber.Ctx) error {
 
 	log.Printf("Uploading image for type: %s", uploadType)
 
-	var config models.Config
-	err = cc.Collection.FindOne(context.Background(), bson.M{}).Decode(&config)
-	if err != nil {
-		log.Printf("Error fetching config: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
-	}
-
-	bucketName := config.FirebaseConfig.BucketName
-	if bucketName == "" {
-		log.Println("Bucket name is not set in config")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Bucket name is not configured"})
-	}
-
-	opt := option.WithCredentialsJSON([]byte(config.FirebaseConfig.Credential))
-	app, err := firebase.NewApp(context.Background(), nil, opt)
+	policy, dir, err := utils.ImageUploadTarget(uploadType)
 	if err != nil {
-		log.Printf("Error initializing Firebase app: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to initialize Firebase app"})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	client, err := app.Storage(context.Background())
+	var config models.Config
+	err = cc.configService.Load(c.UserContext(), &config)
 	if err != nil {
-		log.Printf("Error getting storage client: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create storage client"})
+		log.Printf("Error fetching config: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
 	}
 
-	bucket, err := client.Bucket(bucketName)
+	store, err := blobstore.ForConfig(c.UserContext(), config)
 	if err != nil {
-		log.Printf("Error getting bucket %s: %v", bucketName, err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get bucket"})
+		log.Printf("Error creating blob store: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Storage is not configured"})
 	}
 
-	var filename string
-	if strings.HasPrefix(uploadType, "siteTemplate") {
-		filename = fmt.Sprintf("site_template/%s/%s", strings.TrimPrefix(uploadType, "siteTemplate."), filepath.Base(file.Filename))
-	} else {
-		filename = fmt.Sprintf("flex_messages/%s/%s", uploadType, filepath.Base(file.Filename))
+	// ตรวจขนาดจาก header ก่อนอ่านไฟล์ทั้งก้อน
+	if file.Size > policy.MaxBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": fmt.Sprintf("File is %d bytes, limit is %d bytes", file.Size, policy.MaxBytes)})
 	}
-	log.Printf("Uploading to filename: %s", filename)
 
-	obj := bucket.Object(filename)
-	writer := obj.NewWriter(context.Background())
-
-	// ตรวจสอบและตั้งค่า Content-Type
 	src, err := file.Open()
 	if err != nil {
 		log.Printf("Error opening file: %v", err)
@@ -188,56 +443,55 @@ func (cc *ConfigController) UploadImage(c *fiber.Ctx) error {
 	}
 	defer src.Close()
 
-	buffer := make([]byte, 512)
-	_, err = src.Read(buffer)
+	data, err := io.ReadAll(io.LimitReader(src, policy.MaxBytes+1))
 	if err != nil {
 		log.Printf("Error reading file: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to read file"})
 	}
 
-	contentType := http.DetectContentType(buffer)
-
-	// ตรวจสอบว่าเป็น SVG หรือไม่
-	if contentType == "text/xml; charset=utf-8" || contentType == "text/plain; charset=utf-8" {
-		ext := strings.ToLower(filepath.Ext(file.Filename))
-		if ext == ".svg" {
-			contentType = "image/svg+xml"
+	image, err := utils.ProcessImageUpload(data, file.Filename, policy)
+	if err != nil {
+		var uploadErr *utils.ImageUploadError
+		if errors.As(err, &uploadErr) {
+			return c.Status(uploadErr.Status).JSON(fiber.Map{"error": uploadErr.Message})
 		}
+		log.Printf("Error processing image: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process image"})
 	}
+	log.Printf("Content-Type detected: %s (%dx%d)", image.ContentType, image.Width, image.Height)
 
-	writer.ContentType = contentType
-	log.Printf("Content-Type set to: %s", contentType)
-
-	// Reset file pointer
-	src.Seek(0, 0)
-
-	log.Println("Copying file to storage...")
-	if _, err = io.Copy(writer, src); err != nil {
-		log.Printf("Error copying file: %v", err)
+	filename := image.ObjectName(dir)
+	imageURL, err := store.Put(c.UserContext(), filename, image.ContentType, bytes.NewReader(image.Data))
+	if err != nil {
+		log.Printf("Error uploading image: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to upload file"})
 	}
 
-	log.Println("Closing writer...")
-	if err := writer.Close(); err != nil {
-		log.Printf("Error closing writer: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to close writer"})
-	}
-
-	log.Println("Making file public...")
-	if err := obj.ACL().Set(context.Background(), storage.AllUsers, storage.RoleReader); err != nil {
-		log.Printf("Error making file public: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to make file public"})
-	}
-
-	log.Println("Getting public URL...")
-	attrs, err := obj.Attrs(context.Background())
-	if err != nil {
-		log.Printf("Error getting file attributes: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get file attributes"})
+	variants := fiber.Map{}
+	for _, variant := range image.Variants {
+		variantURL, err := store.Put(c.UserContext(), image.VariantObjectName(dir, variant), variant.ContentType, bytes.NewReader(variant.Data))
+		if err != nil {
+			log.Printf("Error uploading %s variant: %v", variant.Name, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to upload image variant"})
+		}
+		variants[variant.Name] = fiber.Map{
+			"url":         variantURL,
+			"width":       variant.Width,
+			"height":      variant.Height,
+			"contentType": variant.ContentType,
+			"size":        len(variant.Data),
+		}
 	}
 
-	log.Printf("Image uploaded successfully. URL: %s", attrs.MediaLink)
-	return c.JSON(fiber.Map{"imageUrl": attrs.MediaLink})
+	log.Printf("Image uploaded successfully. URL: %s", imageURL)
+	return c.JSON(fiber.Map{
+		"imageUrl":    imageURL,
+		"contentType": image.ContentType,
+		"width":       image.Width,
+		"height":      image.Height,
+		"size":        len(image.Data),
+		"variants":    variants,
+	})
 }
 
 func (cc *ConfigController) UpdateSiteTemplateConfig(c *fiber.Ctx) error {
@@ -252,17 +506,358 @@ func (cc *ConfigController) UpdateSiteTemplateConfig(c *fiber.Ctx) error {
 
 	log.Printf("Received site template update: %+v", siteTemplateUpdate.SiteTemplate)
 
-	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
-	filter := bson.M{}
-	update := bson.M{"$set": bson.M{"site_template": siteTemplateUpdate.SiteTemplate}}
+	if errs := utils.ValidateSiteTemplate(siteTemplateUpdate.SiteTemplate); len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
 
-	var updatedConfig models.Config
-	err := cc.Collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&updatedConfig)
+	updatedConfig, err := cc.saveConfigUpdate(c.UserContext(), bson.M{"site_template": siteTemplateUpdate.SiteTemplate}, models.ConfigRevision{
+		Action: "site_template",
+		Author: utils.RequestActor(c),
+	})
 	if err != nil {
 		log.Printf("Error updating site template config: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update site template config"})
+		return configSaveError(c, err, "Failed to update site template config")
 	}
 
 	log.Printf("Site template config updated successfully")
 	return c.JSON(updatedConfig)
 }
+
+func (cc *ConfigController) findRevision(ctx context.Context, revision int) (models.ConfigRevision, error) {
+	var configRevision models.ConfigRevision
+	err := cc.RevisionCollection.FindOne(ctx, tenant.Scope(ctx, bson.M{"revision": revision})).Decode(&configRevision)
+	return configRevision, err
+}
+
+// GetRevisions - รายการ revision ล่าสุด (ไม่รวม snapshot ของ config)
+func (cc *ConfigController) GetRevisions(c *fiber.Ctx) error {
+	q := utils.ListQuery{Sort: bson.D{{Key: "revision", Value: -1}}}
+	filter := tenant.Scope(c.UserContext(), bson.M{})
+	revisions, page, err := findListPage[models.ConfigRevision](c, cc.RevisionCollection, filter, q, bson.M{"config": 0}, 20, 100)
+	if err != nil {
+		return listPageFailed(c, err, "Failed to fetch config revisions")
+	}
+	for i := range revisions {
+		utils.MaskConfigChanges(revisions[i].Changes)
+	}
+
+	return c.JSON(utils.ListPage{Items: revisions, Pagination: page})
+}
+
+// GetRevision - config ทั้งก้อนของ revision ที่ระบุ
+func (cc *ConfigController) GetRevision(c *fiber.Ctx) error {
+	revision, err := strconv.Atoi(c.Params("revision"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid revision"})
+	}
+
+	configRevision, err := cc.findRevision(c.UserContext(), revision)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Config revision not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config revision"})
+	}
+
+	utils.MaskConfigSecrets(configRevision.Config)
+	utils.MaskConfigChanges(configRevision.Changes)
+	return c.JSON(configRevision)
+}
+
+// DiffRevisions - เปรียบเทียบ config ระหว่าง ?from= และ ?to=
+func (cc *ConfigController) DiffRevisions(c *fiber.Ctx) error {
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from and to revisions are required"})
+	}
+
+	fromRevision, err := cc.findRevision(c.UserContext(), from)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Config revision %d not found", from)})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config revision"})
+	}
+	toRevision, err := cc.findRevision(c.UserContext(), to)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Config revision %d not found", to)})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config revision"})
+	}
+
+	changes, err := utils.DiffDocuments(fromRevision.Config, toRevision.Config, "_id", "revision")
+	if err != nil {
+		log.Printf("Error diffing config revisions %d and %d: %v", from, to, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to diff config revisions"})
+	}
+	utils.MaskConfigChanges(changes)
+
+	return c.JSON(fiber.Map{
+		"from":    from,
+		"to":      to,
+		"changes": changes,
+	})
+}
+
+// RollbackRevision - นำ config ของ revision ที่ระบุกลับมาใช้ โดยสร้างเป็น revision ใหม่
+func (cc *ConfigController) RollbackRevision(c *fiber.Ctx) error {
+	revision, err := strconv.Atoi(c.Params("revision"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid revision"})
+	}
+
+	configRevision, err := cc.findRevision(c.UserContext(), revision)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Config revision not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config revision"})
+	}
+	if configRevision.Config == nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "Config revision has no snapshot"})
+	}
+
+	set, err := configSetDocument(*configRevision.Config)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to read config revision"})
+	}
+
+	updatedConfig, err := cc.saveConfigUpdate(c.UserContext(), set, models.ConfigRevision{
+		Action:         "rollback",
+		Author:         utils.RequestActor(c),
+		RolledBackFrom: revision,
+	})
+	if err != nil {
+		log.Printf("Error rolling back config to revision %d: %v", revision, err)
+		return configSaveError(c, err, "Failed to roll back config")
+	}
+
+	log.Printf("Config rolled back to revision %d as revision %d", revision, updatedConfig.Revision)
+	return c.JSON(updatedConfig)
+}
+
+// ConfigExportOptions ตัวเลือกการส่งออก config เป็น bundle
+type ConfigExportOptions struct {
+	Passphrase    string // ว่าง = ไม่ส่งออก secret
+	IncludeImages bool
+}
+
+// ExportBundle สร้าง bundle จาก config ของ tenant ใน ctx
+func (cc *ConfigController) ExportBundle(ctx context.Context, opts ConfigExportOptions) (*utils.ConfigBundle, error) {
+	var config models.Config
+	if err := cc.configService.Load(ctx, &config); err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %v", err)
+	}
+
+	bundle, err := utils.NewConfigBundle(config, opts.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	bundle.Tenant = tenant.FromContext(ctx)
+
+	if opts.IncludeImages {
+		store, err := blobstore.ForConfig(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create blob store: %v", err)
+		}
+		for _, imageURL := range bundle.ImageURLs(store.Owns) {
+			image, err := utils.FetchBundleImage(imageURL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch image %s: %v", imageURL, err)
+			}
+			bundle.Images = append(bundle.Images, image)
+		}
+	}
+	return bundle, nil
+}
+
+// ConfigImportOptions ตัวเลือกการนำเข้า bundle
+type ConfigImportOptions struct {
+	Passphrase   string
+	DryRun       bool
+	BaseRevision int // ถ้าระบุ ต้องตรงกับ revision ปัจจุบัน (กัน apply ทับ config ที่เปลี่ยนหลังจากดู diff)
+	Author       string
+}
+
+// ConfigImportResult ผลการตรวจสอบและ diff ของ bundle เทียบกับ config ปัจจุบัน
+type ConfigImportResult struct {
+	Valid    bool                   `json:"valid"`
+	Fields   utils.ValidationErrors `json:"fields"`
+	Changes  []models.ConfigChange  `json:"changes"`
+	Images   int                    `json:"images"`
+	Revision int                    `json:"revision"` // revision ปัจจุบัน หรือ revision ใหม่ถ้า apply แล้ว
+	Applied  bool                   `json:"applied"`
+}
+
+// ImportBundle ตรวจสอบ bundle และ diff กับ config ของ tenant ใน ctx ถ้าไม่ใช่ dry run จะบันทึกทั้งก้อนในครั้งเดียว
+// รูปใน bundle ถูกอัปโหลดไปที่ bucket ปลายทางก่อนบันทึก config
+func (cc *ConfigController) ImportBundle(ctx context.Context, bundle *utils.ConfigBundle, opts ConfigImportOptions) (*ConfigImportResult, error) {
+	candidate, err := bundle.DecodeConfig(opts.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	// tenant ใหม่ยังไม่มี config ให้เทียบกับ config ว่าง
+	var current models.Config
+	if err := cc.configService.Load(ctx, &current); err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to fetch config: %v", err)
+	}
+	if opts.BaseRevision != 0 && opts.BaseRevision != current.Revision {
+		return nil, errConfigConflict
+	}
+
+	// config หลัง import (secret ที่ไม่ได้มากับ bundle ใช้ค่าเดิม)
+	resolved := candidate
+	utils.UnmaskConfigSecrets(&resolved, current)
+
+	result := &ConfigImportResult{
+		Fields:   utils.ValidationErrors{},
+		Images:   len(bundle.Images),
+		Revision: current.Revision,
+	}
+	result.Fields = append(result.Fields, utils.ValidateTiers(candidate.Tiers)...)
+	result.Fields = append(result.Fields, utils.ValidateFlexMessages(candidate.FlexMessages)...)
+	result.Fields = append(result.Fields, utils.ValidateSiteTemplate(candidate.SiteTemplate)...)
+	result.Valid = len(result.Fields) == 0
+
+	result.Changes, err = utils.DiffDocuments(current, resolved, "_id", "revision", "tenant_id", "hosts", "liff_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff config: %v", err)
+	}
+	utils.MaskConfigChanges(result.Changes)
+
+	if opts.DryRun || !result.Valid {
+		return result, nil
+	}
+
+	if len(bundle.Images) > 0 {
+		if err := cc.importBundleImages(ctx, bundle, resolved); err != nil {
+			return nil, err
+		}
+		if candidate, err = bundle.DecodeConfig(opts.Passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	set, err := configSetDocument(candidate)
+	if err != nil {
+		return nil, err
+	}
+	// liff_id ไม่มากับ bundle คงค่าเดิมของ tenant ปลายทาง
+	delete(set, "liff_id")
+	updatedConfig, err := cc.saveConfigUpdate(ctx, set, models.ConfigRevision{
+		Action: "import",
+		Author: opts.Author,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Revision = updatedConfig.Revision
+	result.Applied = true
+	return result, nil
+}
+
+// importBundleImages อัปโหลดรูปใน bundle ไปที่ storage ของ target แล้วเปลี่ยน URL ใน bundle
+func (cc *ConfigController) importBundleImages(ctx context.Context, bundle *utils.ConfigBundle, target models.Config) error {
+	store, err := blobstore.ForConfig(ctx, target)
+	if err == blobstore.ErrNotConfigured {
+		log.Printf("Storage is not configured, keeping %d image URLs from bundle", len(bundle.Images))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create blob store: %v", err)
+	}
+
+	for _, image := range bundle.Images {
+		if store.Owns(image.URL) {
+			continue
+		}
+		data, err := image.Bytes()
+		if err != nil {
+			return fmt.Errorf("invalid image data for %s: %v", image.URL, err)
+		}
+		imageURL, err := store.Put(ctx, image.ObjectName(data), image.ContentType, bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to upload image %s: %v", image.URL, err)
+		}
+		bundle.ReplaceImageURL(image.URL, imageURL)
+	}
+	return nil
+}
+
+// ExportConfig - ดาวน์โหลด config เป็น bundle (json หรือ yaml) สำหรับนำไปใช้กับ environment อื่น
+func (cc *ConfigController) ExportConfig(c *fiber.Ctx) error {
+	var body struct {
+		Format        string `json:"format"`
+		Passphrase    string `json:"passphrase"`
+		IncludeImages bool   `json:"include_images"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		}
+	}
+	if body.Format == "" {
+		body.Format = "json"
+	}
+	if body.Format != "json" && body.Format != "yaml" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Format must be 'json' or 'yaml'"})
+	}
+
+	bundle, err := cc.ExportBundle(c.UserContext(), ConfigExportOptions{
+		Passphrase:    body.Passphrase,
+		IncludeImages: body.IncludeImages,
+	})
+	if err != nil {
+		log.Printf("Error exporting config: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to export config"})
+	}
+
+	data, err := utils.MarshalConfigBundle(bundle, body.Format)
+	if err != nil {
+		log.Printf("Error encoding config bundle: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to export config"})
+	}
+
+	contentType := fiber.MIMEApplicationJSON
+	if body.Format == "yaml" {
+		contentType = "application/yaml"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Attachment(fmt.Sprintf("config-%s-r%d.%s", bundle.Tenant, bundle.Revision, body.Format))
+	return c.Send(data)
+}
+
+// ImportConfig - นำเข้า bundle จาก body (json หรือ yaml) ใช้ ?dry_run=true เพื่อดูผลตรวจสอบและ diff ก่อน
+// passphrase ของ secret ส่งทาง header X-Bundle-Passphrase และ ?base_revision= ใช้กันการ apply ทับ config ที่เปลี่ยนไปแล้ว
+func (cc *ConfigController) ImportConfig(c *fiber.Ctx) error {
+	bundle, err := utils.UnmarshalConfigBundle(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := cc.ImportBundle(c.UserContext(), bundle, ConfigImportOptions{
+		Passphrase:   c.Get("X-Bundle-Passphrase"),
+		DryRun:       c.QueryBool("dry_run", false),
+		BaseRevision: c.QueryInt("base_revision", 0),
+		Author:       utils.RequestActor(c),
+	})
+	if err != nil {
+		if err == utils.ErrBundlePassphrase {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid passphrase for bundle secrets"})
+		}
+		log.Printf("Error importing config: %v", err)
+		return configSaveError(c, err, "Failed to import config")
+	}
+	if !result.Valid && !c.QueryBool("dry_run", false) {
+		return validationFailed(c, result.Fields)
+	}
+
+	if result.Applied {
+		log.Printf("Config imported as revision %d with %d changes", result.Revision, len(result.Changes))
+	}
+	return c.JSON(result)
+}
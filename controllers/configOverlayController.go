@@ -0,0 +1,265 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"go-server/config"
+	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ConfigOverlayController จัดการ overlay ของ config ที่มีผลตามช่วงเวลา (tbl_config_overlays)
+// overlay ไม่ได้แก้ tbl_config แต่ถูกนำไปใช้ตอนประเมินผลผ่าน config.Service.Effective
+type ConfigOverlayController struct {
+	collection    *mongo.Collection
+	configService *config.Service
+}
+
+func NewConfigOverlayController(collection *mongo.Collection, configService *config.Service) *ConfigOverlayController {
+	return &ConfigOverlayController{
+		collection:    collection,
+		configService: configService,
+	}
+}
+
+type configOverlayInput struct {
+	Name         string                      `json:"name"`
+	Description  string                      `json:"description"`
+	StartsAt     time.Time                   `json:"starts_at"`
+	EndsAt       time.Time                   `json:"ends_at"`
+	Tiers        []models.TierOverride       `json:"tiers"`
+	FlexMessages models.FlexMessagesOverride `json:"flexMessages"`
+}
+
+// validateOverlay ตรวจ overlay กับ config ปัจจุบัน และคืน overlay อื่นที่ช่วงเวลาทับกัน (ถ้ามี)
+func (oc *ConfigOverlayController) validateOverlay(ctx context.Context, overlay models.ConfigOverlay) (utils.ValidationErrors, *models.ConfigOverlay, error) {
+	var config models.Config
+	if err := oc.configService.Load(ctx, &config); err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch config: %v", err)
+	}
+	if errs := utils.ValidateConfigOverlay(overlay, config.Tiers); len(errs) > 0 {
+		return errs, nil, nil
+	}
+
+	filter := tenant.Scope(ctx, bson.M{
+		"status":    "active",
+		"starts_at": bson.M{"$lt": overlay.EndsAt},
+		"ends_at":   bson.M{"$gt": overlay.StartsAt},
+	})
+	if !overlay.ID.IsZero() {
+		filter["_id"] = bson.M{"$ne": overlay.ID}
+	}
+
+	var conflict models.ConfigOverlay
+	err := oc.collection.FindOne(ctx, filter).Decode(&conflict)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check overlapping overlays: %v", err)
+	}
+	return nil, &conflict, nil
+}
+
+func overlapResponse(c *fiber.Ctx, conflict *models.ConfigOverlay) error {
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"error":    fmt.Sprintf("Overlay overlaps with %q (%s - %s)", conflict.Name, conflict.StartsAt.Format(time.RFC3339), conflict.EndsAt.Format(time.RFC3339)),
+		"conflict": conflict,
+	})
+}
+
+// GetOverlays - รายการ overlay ของ tenant เรียงตามเวลาเริ่ม ใช้ ?status= และ ?include_past=true
+func (oc *ConfigOverlayController) GetOverlays(c *fiber.Ctx) error {
+	filter := tenant.Scope(c.UserContext(), bson.M{})
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	if !c.QueryBool("include_past", false) {
+		filter["ends_at"] = bson.M{"$gt": time.Now()}
+	}
+
+	q := utils.ListQuery{Sort: bson.D{{Key: "starts_at", Value: 1}}}
+	overlays, page, err := findListPage[models.ConfigOverlay](c, oc.collection, filter, q, nil, 50, 100)
+	if err != nil {
+		return listPageFailed(c, err, "Failed to fetch overlays")
+	}
+
+	return c.JSON(utils.ListPage{Items: overlays, Pagination: page})
+}
+
+func (oc *ConfigOverlayController) GetOverlay(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid overlay ID"})
+	}
+
+	var overlay models.ConfigOverlay
+	err = oc.collection.FindOne(c.UserContext(), tenant.Scope(c.UserContext(), bson.M{"_id": id})).Decode(&overlay)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Overlay not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch overlay"})
+	}
+
+	return c.JSON(overlay)
+}
+
+// CreateOverlay - สร้าง overlay ใหม่ ปฏิเสธถ้าช่วงเวลาทับกับ overlay อื่นที่ยัง active
+func (oc *ConfigOverlayController) CreateOverlay(c *fiber.Ctx) error {
+	var input configOverlayInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	now := time.Now()
+	overlay := models.ConfigOverlay{
+		TenantID:     tenant.Value(c.UserContext()),
+		Name:         input.Name,
+		Description:  input.Description,
+		StartsAt:     input.StartsAt,
+		EndsAt:       input.EndsAt,
+		Tiers:        input.Tiers,
+		FlexMessages: input.FlexMessages,
+		Status:       "active",
+		CreatedBy:    utils.RequestActor(c),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if !overlay.EndsAt.IsZero() && !overlay.EndsAt.After(now) {
+		return validationFailed(c, utils.ValidationErrors{{Field: "ends_at", Message: "end time must be in the future"}})
+	}
+
+	errs, conflict, err := oc.validateOverlay(c.UserContext(), overlay)
+	if err != nil {
+		log.Printf("CreateOverlay: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to validate overlay"})
+	}
+	if len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
+	if conflict != nil {
+		return overlapResponse(c, conflict)
+	}
+
+	result, err := oc.collection.InsertOne(c.UserContext(), overlay)
+	if err != nil {
+		log.Printf("CreateOverlay: Database error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create overlay"})
+	}
+	overlay.ID = result.InsertedID.(primitive.ObjectID)
+
+	log.Printf("Config overlay %q created for %s - %s", overlay.Name, overlay.StartsAt, overlay.EndsAt)
+	return c.Status(fiber.StatusCreated).JSON(overlay)
+}
+
+// UpdateOverlay - แก้ไข overlay ที่ยัง active และยังไม่สิ้นสุด
+func (oc *ConfigOverlayController) UpdateOverlay(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid overlay ID"})
+	}
+
+	var input configOverlayInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	var overlay models.ConfigOverlay
+	err = oc.collection.FindOne(c.UserContext(), tenant.Scope(c.UserContext(), bson.M{"_id": id})).Decode(&overlay)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Overlay not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch overlay"})
+	}
+	now := time.Now()
+	if overlay.Status != "active" || !overlay.EndsAt.After(now) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only active overlays that have not ended can be updated"})
+	}
+
+	overlay.Name = input.Name
+	overlay.Description = input.Description
+	overlay.StartsAt = input.StartsAt
+	overlay.EndsAt = input.EndsAt
+	overlay.Tiers = input.Tiers
+	overlay.FlexMessages = input.FlexMessages
+	overlay.UpdatedAt = now
+
+	errs, conflict, err := oc.validateOverlay(c.UserContext(), overlay)
+	if err != nil {
+		log.Printf("UpdateOverlay: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to validate overlay"})
+	}
+	if len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
+	if conflict != nil {
+		return overlapResponse(c, conflict)
+	}
+
+	_, err = oc.collection.ReplaceOne(c.UserContext(), bson.M{"_id": overlay.ID, "status": "active"}, overlay)
+	if err != nil {
+		log.Printf("UpdateOverlay: Database error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update overlay"})
+	}
+
+	return c.JSON(overlay)
+}
+
+// CancelOverlay - ยกเลิก overlay (เก็บไว้เป็นประวัติ ไม่ลบทิ้ง)
+func (oc *ConfigOverlayController) CancelOverlay(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid overlay ID"})
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var overlay models.ConfigOverlay
+	err = oc.collection.FindOneAndUpdate(
+		c.UserContext(),
+		tenant.Scope(c.UserContext(), bson.M{"_id": id, "status": "active"}),
+		bson.M{"$set": bson.M{"status": "cancelled", "updated_at": time.Now()}},
+		opts,
+	).Decode(&overlay)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Overlay not found or already cancelled"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel overlay"})
+	}
+
+	return c.JSON(overlay)
+}
+
+// GetEffectiveConfig - config ที่รวม overlay แล้ว ณ เวลา ?at= (RFC3339, ค่าเริ่มต้นคือตอนนี้) สำหรับดูผลล่วงหน้า
+func (oc *ConfigOverlayController) GetEffectiveConfig(c *fiber.Ctx) error {
+	at := time.Now()
+	if raw := c.Query("at"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid time, expected RFC3339"})
+		}
+		at = parsed
+	}
+
+	config, err := oc.configService.Effective(c.UserContext(), at)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Config not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
+	}
+
+	utils.MaskConfigSecrets(&config)
+	return c.JSON(config)
+}
@@ -0,0 +1,384 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// timeSeriesMetric วิธีนับค่าของ metric หนึ่งในแต่ละช่วงเวลา
+type timeSeriesMetric struct {
+	collection func(dc *DashboardController) *mongo.Collection
+	unwind     []string // field array ที่ต้อง unwind ก่อน match
+	match      bson.M
+	timeField  string
+	sumField   string // ว่าง = นับจำนวนเอกสาร
+}
+
+// ระดับผ่าน/ไม่ผ่านไม่มีเวลาที่บันทึกผลไว้ จึงใช้ expire_date ของ level ซึ่งเป็นเวลาที่ประเมินผล
+var timeSeriesMetrics = map[string]timeSeriesMetric{
+	"missions_started": {
+		collection: func(dc *DashboardController) *mongo.Collection { return dc.missionCollection },
+		timeField:  "created_at",
+	},
+	"levels_passed": {
+		collection: func(dc *DashboardController) *mongo.Collection { return dc.missionCollection },
+		unwind:     []string{"tiers", "tiers.levels"},
+		match:      bson.M{"tiers.levels.status": "success"},
+		timeField:  "tiers.levels.expire_date",
+	},
+	"levels_failed": {
+		collection: func(dc *DashboardController) *mongo.Collection { return dc.missionCollection },
+		unwind:     []string{"tiers", "tiers.levels"},
+		match:      bson.M{"tiers.levels.status": "failed"},
+		timeField:  "tiers.levels.expire_date",
+	},
+	"claims_requested": {
+		collection: func(dc *DashboardController) *mongo.Collection { return dc.logCollection },
+		timeField:  "created_at",
+	},
+	"claims_approved": {
+		collection: func(dc *DashboardController) *mongo.Collection { return dc.logCollection },
+		match:      bson.M{"status": "approve"},
+		timeField:  "callback_time",
+	},
+	"claims_rejected": {
+		collection: func(dc *DashboardController) *mongo.Collection { return dc.logCollection },
+		match:      bson.M{"status": "reject"},
+		timeField:  "callback_time",
+	},
+	"reward_paid": {
+		collection: func(dc *DashboardController) *mongo.Collection { return dc.logCollection },
+		match:      bson.M{"status": "approve"},
+		timeField:  "callback_time",
+		sumField:   "reward",
+	},
+	"new_clients": {
+		collection: func(dc *DashboardController) *mongo.Collection { return dc.clientCollection },
+		timeField:  "created_at",
+	},
+}
+
+// ลำดับ metric ใน response เมื่อไม่ได้ระบุ ?metrics=
+var timeSeriesMetricOrder = []string{
+	"missions_started",
+	"levels_passed",
+	"levels_failed",
+	"claims_requested",
+	"claims_approved",
+	"claims_rejected",
+	"reward_paid",
+	"new_clients",
+}
+
+// queryTimeSeries รวมค่า metric ตามช่วงเวลา คืนค่าเรียงตาม r.Buckets() (ช่วงที่ไม่มีข้อมูลเป็น 0)
+func (dc *DashboardController) queryTimeSeries(ctx context.Context, metric timeSeriesMetric, r utils.AnalyticsRange) ([]float64, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: tenant.Scope(ctx, bson.M{})}},
+	}
+	for _, field := range metric.unwind {
+		pipeline = append(pipeline, bson.D{{Key: "$unwind", Value: "$" + field}})
+	}
+
+	match := bson.M{metric.timeField: r.Match()}
+	for key, value := range metric.match {
+		match[key] = value
+	}
+	var value interface{} = 1
+	if metric.sumField != "" {
+		value = "$" + metric.sumField
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$match", Value: match}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: r.BucketExpr(metric.timeField)},
+			{Key: "value", Value: bson.M{"$sum": value}},
+		}}},
+	)
+
+	cursor, err := metric.collection(dc).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Bucket time.Time   `bson:"_id"`
+		Value  interface{} `bson:"value"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	totals := make(map[int64]float64, len(results))
+	for _, result := range results {
+		totals[result.Bucket.Unix()] = numberValue(result.Value)
+	}
+
+	buckets := r.Buckets()
+	values := make([]float64, len(buckets))
+	for i, bucket := range buckets {
+		values[i] = totals[bucket.Unix()]
+	}
+	return values, nil
+}
+
+// GetTimeSeries - กราฟแนวโน้มตามช่วงเวลา
+// ?from=&to= (YYYY-MM-DD หรือ RFC3339) ?granularity=hour|day|week ?metrics=missions_started,reward_paid
+func (dc *DashboardController) GetTimeSeries(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	r, err := utils.ParseAnalyticsRange(c.Query("from"), c.Query("to"), c.Query("granularity"), 30)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	names := timeSeriesMetricOrder
+	if raw := c.Query("metrics"); raw != "" {
+		names = strings.Split(raw, ",")
+		for _, name := range names {
+			if _, ok := timeSeriesMetrics[name]; !ok {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown metric: " + name})
+			}
+		}
+	}
+
+	series := fiber.Map{}
+	for _, name := range names {
+		values, err := dc.queryTimeSeries(ctx, timeSeriesMetrics[name], r)
+		if err != nil {
+			log.Printf("GetTimeSeries: Failed to query %s: %v", name, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to get " + name,
+			})
+		}
+		series[name] = values
+	}
+
+	return c.JSON(fiber.Map{
+		"success":     true,
+		"from":        r.From,
+		"to":          r.To,
+		"granularity": r.Granularity,
+		"timezone":    utils.AnalyticsTimezone,
+		"buckets":     r.Buckets(),
+		"series":      series,
+	})
+}
+
+// numberValue แปลงตัวเลขจากผล aggregation (int32, int64, double) เป็น float64
+func numberValue(value interface{}) float64 {
+	switch v := value.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// GetMissionFunnel - funnel ของมิชชันที่เริ่มในช่วง ?from=&to= ว่าหลุดออกที่ tier/level ไหน
+func (dc *DashboardController) GetMissionFunnel(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	r, err := utils.ParseAnalyticsRange(c.Query("from"), c.Query("to"), "day", 30)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	opts := options.Find().SetProjection(bson.M{"tiers": 1})
+	cursor, err := dc.missionCollection.Find(ctx, tenant.Scope(ctx, bson.M{"created_at": r.Match()}), opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get missions",
+		})
+	}
+	defer cursor.Close(ctx)
+
+	funnel := utils.NewMissionFunnel()
+	for cursor.Next(ctx) {
+		var mission models.Mission
+		if err := cursor.Decode(&mission); err != nil {
+			log.Printf("GetMissionFunnel: Failed to decode mission: %v", err)
+			continue
+		}
+		funnel.Add(mission)
+	}
+	if err := cursor.Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read missions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"cohort": fiber.Map{
+			"from":     r.From,
+			"to":       r.To,
+			"timezone": utils.AnalyticsTimezone,
+			"missions": funnel.Missions,
+		},
+		"tiers": funnel.Result(),
+	})
+}
+
+// cohortBatchSize จำนวน user_id ต่อหนึ่ง query ($in) ตอนอ่านมิชชันและรางวัลของ cohort
+const cohortBatchSize = 1000
+
+// cohortUserIDs ลูกค้าที่มีมิชชันในช่วง r และไม่มีมิชชันก่อน r.From (มิชชันแรกอยู่ในช่วง)
+func (dc *DashboardController) cohortUserIDs(ctx context.Context, r utils.AnalyticsRange) ([]string, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: tenant.Scope(ctx, bson.M{"created_at": r.Match()})}},
+		bson.D{{Key: "$group", Value: bson.M{"_id": "$user_id"}}},
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: dc.missionCollection.Name()},
+			{Key: "let", Value: bson.M{"user_id": "$_id"}},
+			{Key: "pipeline", Value: mongo.Pipeline{
+				bson.D{{Key: "$match", Value: tenant.Scope(ctx, bson.M{
+					"created_at": bson.M{"$lt": r.From},
+					"$expr":      bson.M{"$eq": bson.A{"$user_id", "$$user_id"}},
+				})}},
+				bson.D{{Key: "$limit", Value: 1}},
+				bson.D{{Key: "$project", Value: bson.M{"_id": 1}}},
+			}},
+			{Key: "as", Value: "earlier"},
+		}}},
+		bson.D{{Key: "$match", Value: bson.M{"earlier": bson.M{"$size": 0}}}},
+		bson.D{{Key: "$project", Value: bson.M{"_id": 1}}},
+	}
+	cursor, err := dc.missionCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		UserID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	userIDs := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if group.UserID != "" {
+			userIDs = append(userIDs, group.UserID)
+		}
+	}
+	return userIDs, nil
+}
+
+// findCohortDocs อ่านเอกสารของ tenant ใน ctx ตาม filter
+func findCohortDocs[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, opts *options.FindOptions) ([]T, error) {
+	cursor, err := collection.Find(ctx, tenant.Scope(ctx, filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []T
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// GetCohortReport - cohort รายสัปดาห์ตามวันที่ลูกค้าเริ่มมิชชันแรก เทียบยอดเดิมพันกับรางวัลที่จ่าย และ retention
+// ?from=&to= ช่วงของสัปดาห์ที่เริ่ม ?weeks= จำนวนสัปดาห์ที่ติดตาม retention ?format=csv
+func (dc *DashboardController) GetCohortReport(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	r, err := utils.ParseAnalyticsRange(c.Query("from"), c.Query("to"), "week", 84)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	retentionWeeks := c.QueryInt("weeks", 8)
+	if retentionWeeks < 1 || retentionWeeks > 52 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "weeks must be between 1 and 52"})
+	}
+
+	report := utils.NewCohortReport(r)
+
+	// เลือกเฉพาะลูกค้าที่มิชชันแรกอยู่ในช่วง แล้วจึงอ่านมิชชันและรางวัลของลูกค้ากลุ่มนั้น
+	userIDs, err := dc.cohortUserIDs(ctx, r)
+	if err != nil {
+		log.Printf("GetCohortReport: Failed to select cohort clients: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get missions",
+		})
+	}
+
+	// มิชชันทั้งหมดของลูกค้าใน cohort ใช้หากิจกรรมในสัปดาห์ถัด ๆ ไป
+	missionOpts := options.Find().SetProjection(bson.M{
+		"user_id":                  1,
+		"created_at":               1,
+		"tiers.levels.start_date":  1,
+		"tiers.levels.current_bet": 1,
+	})
+	logOpts := options.Find().SetProjection(bson.M{
+		"user_id":        1,
+		"tier":           1,
+		"mission_detail": 1,
+		"reward":         1,
+	})
+	for start := 0; start < len(userIDs); start += cohortBatchSize {
+		batch := userIDs[start:min(start+cohortBatchSize, len(userIDs))]
+
+		missions, err := findCohortDocs[models.Mission](ctx, dc.missionCollection, bson.M{"user_id": bson.M{"$in": batch}}, missionOpts)
+		if err != nil {
+			log.Printf("GetCohortReport: Failed to read missions: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to read missions",
+			})
+		}
+		for _, mission := range missions {
+			report.AddMission(mission)
+		}
+
+		rewards, err := findCohortDocs[models.Log](ctx, dc.logCollection, bson.M{"user_id": bson.M{"$in": batch}, "status": "approve"}, logOpts)
+		if err != nil {
+			log.Printf("GetCohortReport: Failed to read rewards: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to read rewards",
+			})
+		}
+		for _, entry := range rewards {
+			report.AddReward(entry)
+		}
+	}
+
+	rows := report.Rows(retentionWeeks, time.Now())
+
+	if c.Query("format") == "csv" {
+		var buf bytes.Buffer
+		if err := utils.WriteCohortCSV(&buf, rows, retentionWeeks); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to write CSV",
+			})
+		}
+		c.Attachment(fmt.Sprintf("cohort_%s_%s.csv", r.From.Format("20060102"), r.To.Format("20060102")))
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		return c.Send(buf.Bytes())
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"from":     r.From,
+		"to":       r.To,
+		"timezone": utils.AnalyticsTimezone,
+		"weeks":    retentionWeeks,
+		"data":     rows,
+	})
+}
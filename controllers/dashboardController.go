@@ -3,12 +3,17 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type DashboardController struct {
@@ -16,141 +21,84 @@ type DashboardController struct {
 	clientCollection  *mongo.Collection
 	userBetCollection *mongo.Collection
 	logCollection     *mongo.Collection
+	stats             *StatsController
 }
 
-func NewDashboardController(missionCollection, clientCollection, userBetCollection, logCollection *mongo.Collection) *DashboardController {
+func NewDashboardController(missionCollection, clientCollection, userBetCollection, logCollection *mongo.Collection, stats *StatsController) *DashboardController {
 	return &DashboardController{
 		missionCollection: missionCollection,
 		clientCollection:  clientCollection,
 		userBetCollection: userBetCollection,
 		logCollection:     logCollection,
+		stats:             stats,
 	}
 }
 
+// GetDashboardData - ภาพรวมมิชชันแยกตาม tier จาก tbl_daily_stats (อาจช้ากว่าข้อมูลจริงไม่เกินรอบของ ProcessRollup)
+// user_details แสดงเฉพาะมิชชันที่อัปเดตล่าสุด ?details_limit= (ค่าเริ่มต้น 10) ต่อ tier
 func (dc *DashboardController) GetDashboardData(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 
-	// 1. Get total missions
-	totalMissions, err := dc.missionCollection.CountDocuments(ctx, bson.M{})
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get total missions",
-		})
-	}
-
-	// 2. Get tier statistics
-	pipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.M{}}},
-		bson.D{{Key: "$unwind", Value: "$tiers"}},
-		bson.D{
-			{Key: "$group", Value: bson.D{
-				{Key: "_id", Value: "$tiers.name"},
-				{Key: "total_users", Value: bson.M{"$sum": 1}},
-				{Key: "level_stats", Value: bson.M{
-					"$push": "$tiers.current_level",
-				}},
-				{Key: "status_stats", Value: bson.M{
-					"$push": "$tiers.status",
-				}},
-				{Key: "user_details", Value: bson.M{
-					"$push": bson.D{
-						{Key: "phone_number", Value: "$phone_number"},
-						{Key: "current_tier", Value: "$current_tier"},
-						{Key: "current_level", Value: "$tiers.current_level"},
-						{Key: "status", Value: "$tiers.status"},
-						{Key: "target", Value: "$tiers.target"},
-						{Key: "last_updated", Value: "$updated_at"},
-					},
-				}},
-			}},
-		},
-	}
-
-	cursor, err := dc.missionCollection.Aggregate(ctx, pipeline)
+	summary, err := dc.stats.Summary(ctx, nil)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get tier statistics",
 		})
 	}
-	defer cursor.Close(ctx)
 
-	var results []bson.M
-	if err := cursor.All(ctx, &results); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to decode results",
-		})
+	type UserDetail struct {
+		PhoneNumber  string    `json:"phone_number"`
+		CurrentTier  int       `json:"current_tier"`
+		CurrentLevel int       `json:"current_level"`
+		Status       string    `json:"status"`
+		Target       int       `json:"target"`
+		LastUpdated  time.Time `json:"last_updated"`
 	}
-
-	// 3. Process results
 	type TierStat struct {
-		Name        string      `json:"name"`
-		TotalUsers  int         `json:"total_users"`
-		Completed   int         `json:"completed_users"`
-		Processing  int         `json:"processing_users"`
-		Failed      int         `json:"failed_users"`
-		LevelCounts map[int]int `json:"level_counts"`
-		UserDetails []struct {
-			PhoneNumber  string    `json:"phone_number"`
-			CurrentTier  int       `json:"current_tier"`
-			CurrentLevel int       `json:"current_level"`
-			Status       string    `json:"status"`
-			Target       int       `json:"target"`
-			LastUpdated  time.Time `json:"last_updated"`
-		} `json:"user_details"`
-	}
-
-	tierStats := make([]TierStat, 0)
-	for _, result := range results {
+		Name        string       `json:"name"`
+		TotalUsers  int          `json:"total_users"`
+		Completed   int          `json:"completed_users"`
+		Processing  int          `json:"processing_users"`
+		Failed      int          `json:"failed_users"`
+		LevelCounts map[int]int  `json:"level_counts"`
+		UserDetails []UserDetail `json:"user_details"`
+	}
+
+	detailsLimit := int64(c.QueryInt("details_limit", 10))
+	tierStats := make([]TierStat, 0, len(summary.Missions.Tiers))
+	for i, tier := range summary.Missions.Tiers {
 		tierStat := TierStat{
-			Name:        result["_id"].(string),
-			TotalUsers:  int(result["total_users"].(int32)),
+			Name:        tier.Name,
+			TotalUsers:  tier.Reached,
+			Completed:   tier.ByStatus["completed"],
+			Processing:  tier.ByStatus["processing"],
+			Failed:      tier.ByStatus["failed"],
 			LevelCounts: make(map[int]int),
+			UserDetails: make([]UserDetail, 0),
 		}
-
-		// Process status stats
-		if statusArray, ok := result["status_stats"].(primitive.A); ok {
-			for _, status := range statusArray {
-				switch status.(string) {
-				case "completed":
-					tierStat.Completed++
-				case "processing":
-					tierStat.Processing++
-				case "failed":
-					tierStat.Failed++
-				}
+		for level, count := range tier.CurrentLevels {
+			if n, err := strconv.Atoi(level); err == nil {
+				tierStat.LevelCounts[n] = count
 			}
 		}
 
-		// Process level stats
-		if levelArray, ok := result["level_stats"].(primitive.A); ok {
-			for _, level := range levelArray {
-				if levelInt, ok := level.(int32); ok {
-					tierStat.LevelCounts[int(levelInt)]++
-				}
+		if detailsLimit > 0 {
+			missions, err := dc.recentMissionsInTier(ctx, i, "", detailsLimit)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to get user details",
+				})
 			}
-		}
-
-		// Process user details
-		if details, ok := result["user_details"].(primitive.A); ok {
-			for _, detail := range details {
-				if detailMap, ok := detail.(primitive.M); ok {
-					userDetail := struct {
-						PhoneNumber  string    `json:"phone_number"`
-						CurrentTier  int       `json:"current_tier"`
-						CurrentLevel int       `json:"current_level"`
-						Status       string    `json:"status"`
-						Target       int       `json:"target"`
-						LastUpdated  time.Time `json:"last_updated"`
-					}{
-						PhoneNumber:  detailMap["phone_number"].(string),
-						CurrentTier:  int(detailMap["current_tier"].(int32)),
-						CurrentLevel: int(detailMap["current_level"].(int32)),
-						Status:       detailMap["status"].(string),
-						Target:       int(detailMap["target"].(int32)),
-						LastUpdated:  detailMap["last_updated"].(primitive.DateTime).Time(),
-					}
-					tierStat.UserDetails = append(tierStat.UserDetails, userDetail)
-				}
+			for _, mission := range missions {
+				t := mission.Tiers[i]
+				tierStat.UserDetails = append(tierStat.UserDetails, UserDetail{
+					PhoneNumber:  mission.PhoneNumber,
+					CurrentTier:  mission.CurrentTier,
+					CurrentLevel: t.CurrentLevel,
+					Status:       t.Status,
+					Target:       t.Target,
+					LastUpdated:  mission.UpdatedAt,
+				})
 			}
 		}
 
@@ -158,78 +106,122 @@ func (dc *DashboardController) GetDashboardData(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"total_missions": totalMissions,
+		"total_missions": summary.Missions.Started,
 		"tier_stats":     tierStats,
 	})
 }
 
+// recentMissionsInTier มิชชันที่ไปถึง tier (index เริ่มที่ 0) เรียงตามเวลาอัปเดตล่าสุด กรอง status ของ tier ถ้าระบุ
+func (dc *DashboardController) recentMissionsInTier(ctx context.Context, tierIndex int, status string, limit int64) ([]models.Mission, error) {
+	filter := bson.M{fmt.Sprintf("tiers.%d", tierIndex): bson.M{"$exists": true}}
+	if status != "" {
+		filter[fmt.Sprintf("tiers.%d.status", tierIndex)] = status
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+		SetLimit(limit).
+		SetProjection(bson.M{"tiers.levels": 0, "tier_rules": 0})
+
+	cursor, err := dc.missionCollection.Find(ctx, tenant.Scope(ctx, filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	missions := make([]models.Mission, 0)
+	if err := cursor.All(ctx, &missions); err != nil {
+		return nil, err
+	}
+	return missions, nil
+}
+
+// clientsByUserID ดึงข้อมูล client ของหลาย user ใน query เดียว
+func (dc *DashboardController) clientsByUserID(ctx context.Context, userIDs []string) (map[string]models.Client, error) {
+	clients := make(map[string]models.Client, len(userIDs))
+	if len(userIDs) == 0 {
+		return clients, nil
+	}
+	cursor, err := dc.clientCollection.Find(ctx, tenant.Scope(ctx, bson.M{"user_id": bson.M{"$in": userIDs}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var client models.Client
+		if err := cursor.Decode(&client); err == nil {
+			clients[client.UserID] = client
+		}
+	}
+	return clients, cursor.Err()
+}
+
 // GetStatsData - KPI Cards สำหรับ Dashboard
 func (dc *DashboardController) GetStatsData(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 
 	// 1. ผู้ใช้ทั้งหมด (Total Clients)
-	totalClients, err := dc.clientCollection.CountDocuments(ctx, bson.M{})
+	totalClients, err := dc.clientCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{}))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get total clients",
 		})
 	}
 
-	// 2. มิชชันกำลังทำ (Active Missions)
-	activeMissions, err := dc.missionCollection.CountDocuments(ctx, bson.M{
-		"status": "processing",
-	})
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get active missions",
-		})
-	}
-
-	// 3. สำเร็จแล้ว (Completed Missions)
-	completedMissions, err := dc.missionCollection.CountDocuments(ctx, bson.M{
-		"status": "completed",
-	})
+	// 2-3. มิชชันกำลังทำและสำเร็จแล้ว จาก tbl_daily_stats
+	summary, err := dc.stats.Summary(ctx, nil)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get completed missions",
+			"error": "Failed to get mission statistics",
 		})
 	}
+	activeMissions := summary.Missions.ByStatus["processing"]
+	completedMissions := summary.Missions.ByStatus["completed"]
 
 	// 4. รางวัลรอแจก (Pending Rewards) - จาก logs ที่ status = "pending"
-	pendingRewards, err := dc.logCollection.CountDocuments(ctx, bson.M{
+	pendingRewards, err := dc.logCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
 		"status": "pending",
-	})
+	}))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get pending rewards",
 		})
 	}
 
-	// คำนวณ trends (เปรียบเทียบกับ 7 วันที่แล้ว)
-	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
+	// คำนวณ trends (ค่าเริ่มต้นเทียบกับ 7 วันที่แล้ว หรือช่วง ?from=&to= ที่เลือก)
+	weekLabel, dayLabel := "จากสัปดาห์ที่แล้ว", "จากเมื่อวาน"
+	trendRange, err := utils.ParseAnalyticsRange(c.Query("from"), c.Query("to"), "day", 7)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	pendingRange := trendRange
+	if c.Query("from") == "" && c.Query("to") == "" {
+		pendingRange.From = time.Now().AddDate(0, 0, -1)
+	} else {
+		weekLabel, dayLabel = "ในช่วงที่เลือก", "ในช่วงที่เลือก"
+	}
 
 	// Trend สำหรับ clients ใหม่
-	newClientsLast7Days, _ := dc.clientCollection.CountDocuments(ctx, bson.M{
-		"created_at": bson.M{"$gte": primitive.NewDateTimeFromTime(sevenDaysAgo)},
-	})
+	newClientsInRange, _ := dc.clientCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
+		"created_at": trendRange.Match(),
+	}))
 
 	// Trend สำหรับ missions ที่เริ่มใหม่
-	newMissionsLast7Days, _ := dc.missionCollection.CountDocuments(ctx, bson.M{
-		"created_at": bson.M{"$gte": sevenDaysAgo},
-	})
+	newMissionsInRange, _ := dc.missionCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
+		"created_at": trendRange.Match(),
+	}))
 
 	// Trend สำหรับ missions ที่สำเร็จ
-	completedLast7Days, _ := dc.missionCollection.CountDocuments(ctx, bson.M{
+	completedInRange, _ := dc.missionCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
 		"status":     "completed",
-		"updated_at": bson.M{"$gte": sevenDaysAgo},
-	})
+		"updated_at": trendRange.Match(),
+	}))
 
 	// Trend สำหรับ pending rewards (เมื่อวาน)
-	yesterday := time.Now().AddDate(0, 0, -1)
-	pendingYesterday, _ := dc.logCollection.CountDocuments(ctx, bson.M{
+	pendingInRange, _ := dc.logCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
 		"status":     "pending",
-		"created_at": bson.M{"$gte": primitive.NewDateTimeFromTime(yesterday)},
-	})
+		"created_at": pendingRange.Match(),
+	}))
 
 	// สร้าง response data ตาม format ของ mockData
 	statsData := []fiber.Map{
@@ -239,8 +231,8 @@ func (dc *DashboardController) GetStatsData(c *fiber.Ctx) error {
 			"icon":  "tabler-users",
 			"color": "primary",
 			"trend": fiber.Map{
-				"value": newClientsLast7Days,
-				"label": "จากสัปดาห์ที่แล้ว",
+				"value": newClientsInRange,
+				"label": weekLabel,
 			},
 		},
 		{
@@ -249,8 +241,8 @@ func (dc *DashboardController) GetStatsData(c *fiber.Ctx) error {
 			"icon":  "tabler-rocket",
 			"color": "info",
 			"trend": fiber.Map{
-				"value": newMissionsLast7Days,
-				"label": "จากสัปดาห์ที่แล้ว",
+				"value": newMissionsInRange,
+				"label": weekLabel,
 			},
 		},
 		{
@@ -259,8 +251,8 @@ func (dc *DashboardController) GetStatsData(c *fiber.Ctx) error {
 			"icon":  "tabler-circle-check",
 			"color": "success",
 			"trend": fiber.Map{
-				"value": completedLast7Days,
-				"label": "จากสัปดาห์ที่แล้ว",
+				"value": completedInRange,
+				"label": weekLabel,
 			},
 		},
 		{
@@ -269,8 +261,8 @@ func (dc *DashboardController) GetStatsData(c *fiber.Ctx) error {
 			"icon":  "tabler-gift",
 			"color": "warning",
 			"trend": fiber.Map{
-				"value": pendingYesterday,
-				"label": "จากเมื่อวาน",
+				"value": pendingInRange,
+				"label": dayLabel,
 			},
 		},
 	}
@@ -282,82 +274,39 @@ func (dc *DashboardController) GetStatsData(c *fiber.Ctx) error {
 }
 
 // GetTierPerformanceData - ข้อมูลประสิทธิภาพแต่ละ Tier
+// ยอดรวมมาจาก tbl_daily_stats และดึงผู้ใช้ที่กำลังทำ tier ล่าสุดไม่เกิน 4 คนต่อ tier
 func (dc *DashboardController) GetTierPerformanceData(c *fiber.Ctx) error {
-	ctx := context.Background()
-
-	// Aggregation pipeline เพื่อดึงข้อมูล tier performance
-	pipeline := mongo.Pipeline{
-		// Unwind tiers เพื่อประมวลผลแต่ละ tier
-		bson.D{{Key: "$unwind", Value: "$tiers"}},
-
-		// Group by tier name และคำนวณสถิติ
-		bson.D{
-			{Key: "$group", Value: bson.D{
-				{Key: "_id", Value: "$tiers.name"},
-				{Key: "totalUsers", Value: bson.M{"$sum": 1}},
-				{Key: "completedUsers", Value: bson.M{
-					"$sum": bson.M{
-						"$cond": []interface{}{
-							bson.M{"$eq": []interface{}{"$tiers.status", "completed"}},
-							1, 0,
-						},
-					},
-				}},
-				{Key: "processingUsers", Value: bson.M{
-					"$sum": bson.M{
-						"$cond": []interface{}{
-							bson.M{"$eq": []interface{}{"$tiers.status", "processing"}},
-							1, 0,
-						},
-					},
-				}},
-				{Key: "failedUsers", Value: bson.M{
-					"$sum": bson.M{
-						"$cond": []interface{}{
-							bson.M{"$eq": []interface{}{"$tiers.status", "failed"}},
-							1, 0,
-						},
-					},
-				}},
-				// รวบรวมข้อมูล active users (processing users)
-				{Key: "activeUsers", Value: bson.M{
-					"$push": bson.M{
-						"$cond": []interface{}{
-							bson.M{"$eq": []interface{}{"$tiers.status", "processing"}},
-							bson.D{
-								{Key: "userId", Value: "$user_id"},
-								{Key: "phoneNumber", Value: "$phone_number"},
-								{Key: "currentLevel", Value: "$tiers.current_level"},
-								{Key: "status", Value: "$tiers.status"},
-								{Key: "updatedAt", Value: "$updated_at"},
-							},
-							"$$REMOVE",
-						},
-					},
-				}},
-			}},
-		},
-
-		// Sort by tier name
-		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
-	}
+	ctx := c.UserContext()
 
-	cursor, err := dc.missionCollection.Aggregate(ctx, pipeline)
+	summary, err := dc.stats.Summary(ctx, nil)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get tier performance data",
 		})
 	}
-	defer cursor.Close(ctx)
 
-	var results []bson.M
-	if err := cursor.All(ctx, &results); err != nil {
+	// ผู้ใช้ที่กำลังทำแต่ละ tier แล้วดึงข้อมูล client ทั้งหมดใน query เดียว
+	activeMissions := make([][]models.Mission, len(summary.Missions.Tiers))
+	userIDs := []string{}
+	for i := range summary.Missions.Tiers {
+		missions, err := dc.recentMissionsInTier(ctx, i, "processing", 4) // จำกัด 4 รายการตาม mockData
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to get active users",
+			})
+		}
+		activeMissions[i] = missions
+		for _, mission := range missions {
+			userIDs = append(userIDs, mission.UserID)
+		}
+	}
+	clients, err := dc.clientsByUserID(ctx, userIDs)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to decode tier performance results",
+			"error": "Failed to get client details",
 		})
 	}
 
-	// ดึงข้อมูล client details สำหรับ active users
 	tierPerformanceData := make([]fiber.Map, 0)
 
 	tierColors := map[string]string{
@@ -366,12 +315,9 @@ func (dc *DashboardController) GetTierPerformanceData(c *fiber.Ctx) error {
 		"TIER 3": "#a855f7", // purple
 	}
 
-	for i, result := range results {
-		tierName := result["_id"].(string)
-		totalUsers := int(result["totalUsers"].(int32))
-		completedUsers := int(result["completedUsers"].(int32))
-		processingUsers := int(result["processingUsers"].(int32))
-		failedUsers := int(result["failedUsers"].(int32))
+	for i, tier := range summary.Missions.Tiers {
+		totalUsers := tier.Reached
+		completedUsers := tier.ByStatus["completed"]
 
 		// คำนวณ success rate
 		successRate := 0
@@ -381,68 +327,51 @@ func (dc *DashboardController) GetTierPerformanceData(c *fiber.Ctx) error {
 
 		// ประมวลผล active users
 		activeUsers := make([]fiber.Map, 0)
-		if activeUsersArray, ok := result["activeUsers"].(primitive.A); ok {
-			for j, userInterface := range activeUsersArray {
-				if j >= 4 { // จำกัด 4 รายการตาม mockData
-					break
+		for j, mission := range activeMissions[i] {
+			displayName := "Unknown User"
+			pictureUrl := "https://i.pravatar.cc/150?img=" + fmt.Sprintf("%d", (j%50)+1)
+			if client, ok := clients[mission.UserID]; ok {
+				if client.DisplayName != "" {
+					displayName = client.DisplayName
 				}
-				if userMap, ok := userInterface.(primitive.M); ok {
-					// ดึงข้อมูล client details
-					var client bson.M
-					err := dc.clientCollection.FindOne(ctx, bson.M{
-						"user_id": userMap["userId"],
-					}).Decode(&client)
-
-					displayName := "Unknown User"
-					pictureUrl := "https://i.pravatar.cc/150?img=" + fmt.Sprintf("%d", (j%50)+1)
-
-					if err == nil {
-						if name, ok := client["display_name"].(string); ok && name != "" {
-							displayName = name
-						}
-						if pic, ok := client["picture_url"].(string); ok && pic != "" {
-							pictureUrl = pic
-						}
-					}
-
-					// คำนวณเวลาที่ผ่านมา (mock)
-					timeLabels := []string{"5 นาทีที่แล้ว", "12 นาทีที่แล้ว", "25 นาทีที่แล้ว", "1 ชั่วโมงที่แล้ว"}
-					updatedLabel := timeLabels[j%len(timeLabels)]
-
-					activeUser := fiber.Map{
-						"id":           fmt.Sprintf("%d", j+1),
-						"userId":       userMap["userId"],
-						"displayName":  displayName,
-						"pictureUrl":   pictureUrl,
-						"phoneNumber":  userMap["phoneNumber"],
-						"currentLevel": userMap["currentLevel"],
-						"status":       userMap["status"],
-						"updatedAt":    updatedLabel,
-					}
-					activeUsers = append(activeUsers, activeUser)
+				if client.PictureURL != "" {
+					pictureUrl = client.PictureURL
 				}
 			}
+
+			// คำนวณเวลาที่ผ่านมา (mock)
+			timeLabels := []string{"5 นาทีที่แล้ว", "12 นาทีที่แล้ว", "25 นาทีที่แล้ว", "1 ชั่วโมงที่แล้ว"}
+			updatedLabel := timeLabels[j%len(timeLabels)]
+
+			activeUsers = append(activeUsers, fiber.Map{
+				"id":           fmt.Sprintf("%d", j+1),
+				"userId":       mission.UserID,
+				"displayName":  displayName,
+				"pictureUrl":   pictureUrl,
+				"phoneNumber":  mission.PhoneNumber,
+				"currentLevel": mission.Tiers[i].CurrentLevel,
+				"status":       mission.Tiers[i].Status,
+				"updatedAt":    updatedLabel,
+			})
 		}
 
 		// กำหนดสี tier
-		tierColor := tierColors[tierName]
+		tierColor := tierColors[tier.Name]
 		if tierColor == "" {
 			tierColor = "#6b7280" // default gray
 		}
 
-		tierData := fiber.Map{
+		tierPerformanceData = append(tierPerformanceData, fiber.Map{
 			"tier":            i + 1,
-			"name":            tierName,
+			"name":            tier.Name,
 			"totalUsers":      totalUsers,
 			"completedUsers":  completedUsers,
-			"processingUsers": processingUsers,
-			"failedUsers":     failedUsers,
+			"processingUsers": tier.ByStatus["processing"],
+			"failedUsers":     tier.ByStatus["failed"],
 			"successRate":     successRate,
 			"color":           tierColor,
 			"activeUsers":     activeUsers,
-		}
-
-		tierPerformanceData = append(tierPerformanceData, tierData)
+		})
 	}
 
 	return c.JSON(fiber.Map{
@@ -453,7 +382,7 @@ func (dc *DashboardController) GetTierPerformanceData(c *fiber.Ctx) error {
 
 // GetUrgentAlertsData - ข้อมูลการแจ้งเตือนเร่งด่วน
 func (dc *DashboardController) GetUrgentAlertsData(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 
 	// 1. รางวัลใกล้หมดอายุ (Reward Expiring) - จาก expiration_events
 	now := time.Now()
@@ -464,26 +393,26 @@ func (dc *DashboardController) GetUrgentAlertsData(c *fiber.Ctx) error {
 	// ใช้ collection tbl_expiration_events ถ้ามี หรือคำนวณจาก missions
 	expirationCollection = dc.missionCollection.Database().Collection("tbl_expiration_events")
 
-	rewardExpiringCount, err := expirationCollection.CountDocuments(ctx, bson.M{
+	rewardExpiringCount, err := expirationCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
 		"type":        "reward_expiration",
 		"expire_time": bson.M{"$lte": primitive.NewDateTimeFromTime(next24Hours)},
 		"status":      "pending",
-	})
+	}))
 	if err != nil {
 		// ถ้าไม่มี expiration_events collection ให้คำนวณจาก missions
-		rewardExpiringCount, _ = dc.missionCollection.CountDocuments(ctx, bson.M{
+		rewardExpiringCount, _ = dc.missionCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
 			"tiers.expire_reward": bson.M{
 				"$lte": next24Hours,
 				"$gte": now,
 			},
 			"status": "completed",
-		})
+		}))
 	}
 
 	// 2. รอการอนุมัติ (Approval Pending) - จาก logs ที่ status = "pending"
-	approvalPendingCount, err := dc.logCollection.CountDocuments(ctx, bson.M{
+	approvalPendingCount, err := dc.logCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
 		"status": "pending",
-	})
+	}))
 	if err != nil {
 		approvalPendingCount = 0
 	}
@@ -492,23 +421,23 @@ func (dc *DashboardController) GetUrgentAlertsData(c *fiber.Ctx) error {
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	endOfDay := startOfDay.Add(24 * time.Hour)
 
-	levelExpiringCount, err := expirationCollection.CountDocuments(ctx, bson.M{
+	levelExpiringCount, err := expirationCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
 		"type":        "level_expiration",
 		"expire_time": bson.M{"$gte": primitive.NewDateTimeFromTime(startOfDay), "$lt": primitive.NewDateTimeFromTime(endOfDay)},
 		"status":      "pending",
-	})
+	}))
 	if err != nil {
 		// ถ้าไม่มี expiration_events collection ให้คำนวณจาก missions
 		pipeline := mongo.Pipeline{
 			bson.D{{Key: "$unwind", Value: "$tiers"}},
 			bson.D{{Key: "$unwind", Value: "$tiers.levels"}},
-			bson.D{{Key: "$match", Value: bson.M{
+			bson.D{{Key: "$match", Value: tenant.Scope(ctx, bson.M{
 				"tiers.levels.expire_date": bson.M{
 					"$gte": startOfDay,
 					"$lt":  endOfDay,
 				},
 				"tiers.levels.status": bson.M{"$ne": "completed"},
-			}}},
+			})}},
 			bson.D{{Key: "$count", Value: "total"}},
 		}
 
@@ -566,33 +495,17 @@ func (dc *DashboardController) GetUrgentAlertsData(c *fiber.Ctx) error {
 
 // GetRecentActivitiesData - ข้อมูลกิจกรรมล่าสุด
 func (dc *DashboardController) GetRecentActivitiesData(c *fiber.Ctx) error {
-	ctx := context.Background()
-
-	// ดึง limit จาก query parameter (default = 10)
-	limit := c.QueryInt("limit", 10)
-
-	// Aggregation pipeline เพื่อดึง recent activities จาก logs
-	pipeline := mongo.Pipeline{
-		// เรียงตาม created_at ล่าสุด
-		bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}},
+	ctx := c.UserContext()
 
-		// จำกัดจำนวน
-		bson.D{{Key: "$limit", Value: limit}},
-	}
-
-	cursor, err := dc.logCollection.Aggregate(ctx, pipeline)
+	// กรองและเรียงตาม ClaimQuerySchema (ค่าเริ่มต้น created_at ล่าสุด) แบ่งหน้าด้วย ?limit= (default = 10) และ ?cursor=
+	q, err := parseListQuery(c, ClaimQuerySchema)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get recent activities",
-		})
+		return listQueryFailed(c, err)
 	}
-	defer cursor.Close(ctx)
 
-	var logs []bson.M
-	if err := cursor.All(ctx, &logs); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to decode recent activities",
-		})
+	logs, page, err := findListPage[bson.M](c, dc.logCollection, tenant.Scope(ctx, q.Filter), q, nil, 10, 100)
+	if err != nil {
+		return listPageFailed(c, err, "Failed to get recent activities")
 	}
 
 	// แปลง logs เป็น activities format
@@ -728,59 +641,38 @@ func (dc *DashboardController) GetRecentActivitiesData(c *fiber.Ctx) error {
 		activities = append(activities, activity)
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    activities,
-	})
+	return c.JSON(utils.ListPage{Items: activities, Pagination: page})
 }
 
 // GetPendingRewards - สรุปภาพรวมรางวัล (pending/approved/rejected) และรายการที่รออนุมัติพร้อมข้อมูล client
 func (dc *DashboardController) GetPendingRewards(c *fiber.Ctx) error {
-	ctx := context.Background()
-
-	// ดึง limit จาก query parameter (default = 50)
-	limit := c.QueryInt("limit", 50)
+	ctx := c.UserContext()
 
-	// Aggregation pipeline เพื่อดึงรายการ pending rewards พร้อม client info
-	pipeline := mongo.Pipeline{
-		// Filter เฉพาะ status = "pending"
-		bson.D{{Key: "$match", Value: bson.M{"status": "pending"}}},
-
-		// เรียงตาม created_at ล่าสุด
-		bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}},
-
-		// จำกัดจำนวน
-		bson.D{{Key: "$limit", Value: limit}},
-	}
-
-	cursor, err := dc.logCollection.Aggregate(ctx, pipeline)
+	// กรองเพิ่มตาม ClaimQuerySchema ได้ แต่รายการนี้เป็น status = "pending" เสมอ แบ่งหน้าด้วย ?limit= (default = 50) และ ?cursor=
+	q, err := parseListQuery(c, ClaimQuerySchema)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get pending rewards",
-		})
+		return listQueryFailed(c, err)
 	}
-	defer cursor.Close(ctx)
+	q.Filter["status"] = "pending"
 
-	var logs []bson.M
-	if err := cursor.All(ctx, &logs); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to decode pending rewards",
-		})
+	logs, page, err := findListPage[bson.M](c, dc.logCollection, tenant.Scope(ctx, q.Filter), q, nil, 50, 100)
+	if err != nil {
+		return listPageFailed(c, err, "Failed to get pending rewards")
 	}
 
 	// นับจำนวนรายการ pending ทั้งหมด
-	totalPending, err := dc.logCollection.CountDocuments(ctx, bson.M{"status": "pending"})
+	totalPending, err := dc.logCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{"status": "pending"}))
 	if err != nil {
 		totalPending = 0
 	}
 
 	// นับจำนวนรายการ approved และ rejected
-	totalApproved, _ := dc.logCollection.CountDocuments(ctx, bson.M{"status": "approve"})
-	totalRejected, _ := dc.logCollection.CountDocuments(ctx, bson.M{"status": "reject"})
+	totalApproved, _ := dc.logCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{"status": "approve"}))
+	totalRejected, _ := dc.logCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{"status": "reject"}))
 
 	// คำนวณยอดเงินที่จ่ายไปแล้ว (approved)
 	approvedPipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.M{"status": "approve"}}},
+		bson.D{{Key: "$match", Value: tenant.Scope(ctx, bson.M{"status": "approve"})}},
 		bson.D{{Key: "$group", Value: bson.D{
 			{Key: "_id", Value: nil},
 			{Key: "totalAmount", Value: bson.M{"$sum": "$reward"}},
@@ -904,9 +796,9 @@ func (dc *DashboardController) GetPendingRewards(c *fiber.Ctx) error {
 		"total":     totalPending + totalApproved + totalRejected,
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"summary": summary,
-		"data":    pendingRewards,
-	})
+	// summary อยู่คู่กับซองรายการเดียวกับ endpoint อื่น
+	return c.JSON(struct {
+		utils.ListPage
+		Summary fiber.Map `json:"summary"`
+	}{utils.ListPage{Items: pendingRewards, Pagination: page}, summary})
 }
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go-server/eventbus"
+	"go-server/tenant"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EventStreamController ส่ง event ของระบบให้หน้า admin แบบ real-time ผ่าน Server-Sent Events
+type EventStreamController struct {
+	bus *eventbus.Bus
+}
+
+func NewEventStreamController(bus *eventbus.Bus) *EventStreamController {
+	return &EventStreamController{bus: bus}
+}
+
+const eventStreamHeartbeat = 25 * time.Second
+
+// StreamEvents - GET /api/dashboard/events?types=claim_requested,callback_received
+// client ที่ต่อใหม่ส่ง Last-Event-ID (หรือ ?last_event_id=) เพื่อรับ event ที่พลาดไประหว่างหลุด
+func (ec *EventStreamController) StreamEvents(c *fiber.Ctx) error {
+	var types []string
+	if raw := c.Query("types"); raw != "" {
+		for _, eventType := range strings.Split(raw, ",") {
+			if eventType = strings.TrimSpace(eventType); eventType != "" {
+				types = append(types, eventType)
+			}
+		}
+	}
+	lastID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	lastEventID, _ := strconv.ParseUint(lastID, 10, 64)
+
+	tenantID := tenant.FromContext(c.UserContext())
+	sub, missed := ec.bus.Subscribe(tenantID, types, lastEventID)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer func() {
+			if dropped := ec.bus.Unsubscribe(sub); dropped > 0 {
+				log.Printf("StreamEvents: subscriber for tenant %s dropped %d events", tenantID, dropped)
+			}
+		}()
+
+		fmt.Fprintf(w, "retry: 5000\n\n")
+		for _, event := range missed {
+			writeServerSentEvent(w, event)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(eventStreamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case event := <-sub.C:
+				writeServerSentEvent(w, event)
+			case <-heartbeat.C:
+				fmt.Fprintf(w, ": ping\n\n")
+			}
+			// Flush ล้มเหลวเมื่อ client ปิดการเชื่อมต่อ
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+func writeServerSentEvent(w *bufio.Writer, event eventbus.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("StreamEvents: failed to encode %s event: %v", event.Type, err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -3,7 +3,10 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"go-server/config"
+	"go-server/eventbus"
 	"go-server/models"
+	"go-server/tenant"
 	"log"
 	"strconv"
 	"time"
@@ -18,15 +21,15 @@ import (
 type ExpirationEventController struct {
 	eventCollection   *mongo.Collection
 	missionCollection *mongo.Collection
-	configCollection  *mongo.Collection
+	configService     *config.Service
 	lineController    *LineController
 }
 
-func NewExpirationEventController(eventCollection, missionCollection, configCollection *mongo.Collection, lineController *LineController) *ExpirationEventController {
+func NewExpirationEventController(eventCollection, missionCollection *mongo.Collection, configService *config.Service, lineController *LineController) *ExpirationEventController {
 	return &ExpirationEventController{
 		eventCollection:   eventCollection,
 		missionCollection: missionCollection,
-		configCollection:  configCollection,
+		configService:     configService,
 		lineController:    lineController,
 	}
 }
@@ -40,7 +43,7 @@ func (c *ExpirationEventController) ProcessEvents() {
 			"expire_time": bson.M{"$lte": now},
 			"status":      "pending",
 		}
-		update := bson.M{"$set": bson.M{"status": "processed"}}
+		update := bson.M{"$set": bson.M{"status": "processed", "processed_at": now}}
 		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
 		var event models.ExpirationEvent
@@ -56,15 +59,61 @@ func (c *ExpirationEventController) ProcessEvents() {
 		}
 
 		log.Printf("Processing event: Type: %s, MissionID: %s", event.Type, event.MissionID.Hex())
-		err = c.handleExpiredMission(ctx, event)
+
+		// event ใช้ config, LINE bot และ bet API ของ tenant เจ้าของ event
+		ctx = tenant.With(ctx, event.TenantID)
+		err = c.safeHandleExpiredMission(ctx, event)
 		if err != nil {
 			log.Printf("Error handling expired mission: %v", err)
+			c.retryOrFailEvent(ctx, event, err)
 		}
 
 		time.Sleep(100 * time.Millisecond)
 	}
 }
 
+// safeHandleExpiredMission กัน panic จาก event ที่ข้อมูลไม่ถูกต้อง ไม่ให้ทำให้ loop หยุดทำงาน
+func (c *ExpirationEventController) safeHandleExpiredMission(ctx context.Context, event models.ExpirationEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while handling event: %v", r)
+		}
+	}()
+	return c.handleExpiredMission(ctx, event)
+}
+
+// maxEventAttempts จำนวนครั้งที่ลองประมวลผล event ก่อนย้ายไปเป็น dead letter
+const maxEventAttempts = 5
+
+// eventRetryDelay ระยะรอก่อนลองใหม่ครั้งที่ attempt (1 นาที แล้วเพิ่มเท่าตัว ไม่เกิน 1 ชั่วโมง)
+func eventRetryDelay(attempt int) time.Duration {
+	delay := time.Minute << (attempt - 1)
+	if delay <= 0 || delay > time.Hour {
+		return time.Hour
+	}
+	return delay
+}
+
+// retryOrFailEvent คืน event ที่ประมวลผลไม่สำเร็จเป็น pending พร้อมเลื่อนเวลาตาม eventRetryDelay
+// error ชั่วคราวของ MongoDB หรือ LINE จึงไม่ทำให้ event หาย เมื่อครบ maxEventAttempts จึงย้ายไปเป็น dead letter (status "failed")
+func (c *ExpirationEventController) retryOrFailEvent(ctx context.Context, event models.ExpirationEvent, cause error) {
+	attempts := event.Attempts + 1
+	set := bson.M{"attempts": attempts, "error": cause.Error()}
+	if attempts < maxEventAttempts {
+		set["status"] = "pending"
+		set["expire_time"] = time.Now().Add(eventRetryDelay(attempts))
+		log.Printf("Event %s failed (attempt %d/%d), retrying at %v", event.ID.Hex(), attempts, maxEventAttempts, set["expire_time"])
+	} else {
+		set["status"] = "failed"
+		log.Printf("Event %s failed %d times, moved to dead letter", event.ID.Hex(), attempts)
+	}
+
+	_, err := c.eventCollection.UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{"$set": set})
+	if err != nil {
+		log.Printf("Failed to reschedule event %s: %v", event.ID.Hex(), err)
+	}
+}
+
 func (c *ExpirationEventController) handleExpiredMission(ctx context.Context, event models.ExpirationEvent) error {
 	var mission models.Mission
 	err := c.missionCollection.FindOne(ctx, bson.M{"_id": event.MissionID}).Decode(&mission)
@@ -72,15 +121,45 @@ func (c *ExpirationEventController) handleExpiredMission(ctx context.Context, ev
 		return err
 	}
 
-	var config models.Config
-	err = c.configCollection.FindOne(ctx, bson.M{}).Decode(&config)
+	if event.TierIndex < 0 || event.TierIndex >= len(mission.Tiers) ||
+		event.LevelIndex < 0 || event.LevelIndex >= len(mission.Tiers[event.TierIndex].Levels) {
+		return fmt.Errorf("event %s points to missing tier %d level %d", event.ID.Hex(), event.TierIndex, event.LevelIndex)
+	}
+
+	// กติกา (รวม overlay) ของ level_expiration ใช้ ณ เวลาที่ level หมดอายุ ไม่ใช่เวลาที่ประมวลผลหลัง processing delay
+	evaluatedAt := time.Now()
+	if event.Type == "level_expiration" {
+		evaluatedAt = mission.Tiers[event.TierIndex].Levels[event.LevelIndex].ExpireDate
+	}
+	config, err := c.configService.Effective(ctx, evaluatedAt)
+	if err != nil {
+		return err
+	}
+	currentTierConfig, err := missionTierRule(&mission, config, event.TierIndex)
 	if err != nil {
 		return err
 	}
 
 	currentTier := &mission.Tiers[event.TierIndex]
 	currentLevel := &currentTier.Levels[event.LevelIndex]
-	currentTierConfig := config.Tiers[event.TierIndex]
+
+	// ข้อความแจ้งเตือนที่ไม่เร่งด่วนจะถูกเลื่อนออกไปตามช่วงงดส่งและจำนวนข้อความต่อวัน
+	// ส่วน level_expiration และ reward_expiration ยังประมวลผลตรงเวลาเสมอ
+	var deadline time.Time
+	switch event.Type {
+	case "follow_up":
+		deadline = currentLevel.ExpireDate
+	case "reward_notification", "recurring_reward_notification":
+		deadline = currentTier.ExpireReward
+	}
+	if !deadline.IsZero() {
+		deferred, err := c.deferNotification(ctx, event, &mission, deadline, config.Notification)
+		if err != nil {
+			log.Printf("Failed to check notification window: %v", err)
+		} else if deferred {
+			return nil
+		}
+	}
 
 	switch event.Type {
 	case "level_expiration":
@@ -96,15 +175,85 @@ func (c *ExpirationEventController) handleExpiredMission(ctx context.Context, ev
 	}
 }
 
+// deferNotification เลื่อน event แจ้งเตือนไปยังช่วงเวลาที่ส่งได้ถัดไป
+// คืนค่า true ถ้า event ถูกเลื่อนหรือถูกข้ามไป (เช่น เลื่อนแล้วเกิน deadline)
+func (c *ExpirationEventController) deferNotification(ctx context.Context, event models.ExpirationEvent, mission *models.Mission, deadline time.Time, settings models.NotificationConfig) (bool, error) {
+	now := time.Now()
+	nextSend := utils.NextAllowedSendTime(settings, now)
+
+	if !nextSend.After(now) && settings.DailyMessageCap > 0 {
+		startOfDay := utils.StartOfCampaignDay(settings, now)
+		sentToday, err := c.lineController.CountMessagesSince(ctx, mission.UserID, startOfDay)
+		if err != nil {
+			return false, fmt.Errorf("failed to count messages: %v", err)
+		}
+		if sentToday >= int64(settings.DailyMessageCap) {
+			nextSend = utils.NextAllowedSendTime(settings, startOfDay.AddDate(0, 0, 1))
+			log.Printf("Mission ID: %s - Daily message cap (%d) reached for user %s", mission.ID.Hex(), settings.DailyMessageCap, mission.UserID)
+		}
+	}
+
+	if !nextSend.After(now) {
+		return false, nil
+	}
+
+	if !nextSend.Before(deadline) {
+		log.Printf("Mission ID: %s - %s skipped, next send window %v is after %v", mission.ID.Hex(), event.Type, nextSend, deadline)
+		return true, nil
+	}
+
+	// Tier 3 มีการแจ้งเตือนซ้ำตาม NotifyInterval ถ้ามีรอบถัดไปรออยู่ก่อนเวลาที่เลื่อนไป ก็ไม่ต้องส่งซ้อน
+	if event.Type == "recurring_reward_notification" {
+		pending, err := c.eventCollection.CountDocuments(ctx, bson.M{
+			"_id":         bson.M{"$ne": event.ID},
+			"mission_id":  event.MissionID,
+			"type":        event.Type,
+			"status":      "pending",
+			"expire_time": bson.M{"$lte": nextSend},
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to check pending notifications: %v", err)
+		}
+		if pending > 0 {
+			log.Printf("Mission ID: %s - %s skipped, superseded by a later notification", mission.ID.Hex(), event.Type)
+			return true, nil
+		}
+	}
+
+	_, err := c.eventCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": event.ID},
+		bson.M{
+			"$set": bson.M{"status": "pending", "expire_time": nextSend},
+			"$inc": bson.M{"defer_count": 1},
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to defer event: %v", err)
+	}
+
+	log.Printf("Mission ID: %s - %s deferred to %v", mission.ID.Hex(), event.Type, nextSend)
+	return true, nil
+}
+
+// handleLevelExpiration ตัดสินผลของ level บันทึกมิชชันก่อน แล้วจึงสร้าง event และส่งข้อความ
+// ถ้าบันทึกไม่สำเร็จ event จะถูกลองใหม่ (retryOrFailEvent) โดยยังไม่มีผลข้างเคียงใดเกิดขึ้น
+// การบันทึกใช้เงื่อนไขว่า level ยังเป็น "processing" จึงมีผู้ตัดสินได้ครั้งเดียว แม้ event ถูกประมวลผลซ้ำ
 func (c *ExpirationEventController) handleLevelExpiration(ctx context.Context, mission *models.Mission, currentTier *models.Tier, currentLevel *models.Level, currentTierConfig models.TierDetail) error {
+	if currentLevel.Status != "processing" {
+		log.Printf("Mission ID: %s, Tier: %d, Level: %s - already evaluated (%s), skipping", mission.ID.Hex(), mission.CurrentTier, currentLevel.Name, currentLevel.Status)
+		return nil
+	}
+
 	var config models.Config
-	err := c.configCollection.FindOne(ctx, bson.M{}).Decode(&config)
+	err := c.configService.Load(ctx, &config)
 	if err != nil {
 		return fmt.Errorf("failed to fetch config: %v", err)
 	}
 
 	// Calculate the actual expiration time without processing delay
 	actualExpireTime := currentLevel.ExpireDate
+	tierIndex, levelNumber := mission.CurrentTier-1, currentTier.CurrentLevel
 
 	currentBet, err := utils.GetCurrentBet(config, mission.UserID, currentLevel.StartDate, actualExpireTime)
 	if err != nil {
@@ -114,61 +263,52 @@ func (c *ExpirationEventController) handleLevelExpiration(ctx context.Context, m
 
 	currentLevel.CurrentBet = currentBet
 
+	// ผลข้างเคียง (event ใหม่และข้อความ LINE) ทำหลังบันทึกมิชชันสำเร็จเท่านั้น
+	var newLevel *models.Level
+	rewardPending := false
+	var notify func() error
+
 	if currentBet >= float64(currentTierConfig.Target) {
 		currentLevel.Status = "success"
 		log.Printf("Mission ID: %s, Tier: %d, Level: %d - SUCCESS", mission.ID.Hex(), mission.CurrentTier, currentTier.CurrentLevel)
 
+		// รางวัลยึดตามกติกา ณ เวลาที่ทำเป้าสำเร็จ (เช่น ช่วงโปรรางวัลสองเท่า)
+		currentTier.Reward = currentTierConfig.Reward
+
 		mission.ConsecutiveFails = 0 // Reset consecutive fails on success
 
-		if mission.CurrentTier < 3 {
-			// Tier 1 or 2 handling
-			if currentTier.CurrentLevel == currentTier.MaxLevel {
-				currentTier.Status = "awaiting_reward"
-				log.Printf("Mission ID: %s, Tier: %d - COMPLETE, AWAITING REWARD", mission.ID.Hex(), mission.CurrentTier)
-				c.createRewardExpirationEvent(ctx, mission, currentTier, currentTierConfig)
+		if mission.CurrentTier < 3 && currentTier.CurrentLevel != currentTier.MaxLevel {
+			// Prepare next level for Tier 1 and 2
+			currentTier.CurrentLevel++
+			level := createNewLevel(currentTier.CurrentLevel, currentTierConfig.Period, currentTierConfig.FollowUpHours)
+			currentTier.Levels = append(currentTier.Levels, level)
+			newLevel = &currentTier.Levels[len(currentTier.Levels)-1]
 
-				err := c.lineController.SendMissionCompleteFlexMessage(
-					mission.UserID,
-					fmt.Sprintf("%d", currentTierConfig.ExpireRewardHours),
-					strconv.Itoa(mission.CurrentTier),
-					strconv.Itoa(currentTier.CurrentLevel),
-					mission.ID,
-				)
-				if err != nil {
-					log.Printf("Failed to send mission complete notification: %v", err)
-				}
-			} else {
-				// Prepare next level for Tier 1 and 2
-				currentTier.CurrentLevel++
-				newLevel := createNewLevel(currentTier.CurrentLevel, currentTierConfig.Period, currentTierConfig.FollowUpHours)
-				currentTier.Levels = append(currentTier.Levels, newLevel)
-				c.createNewEvents(ctx, mission, currentTier, &newLevel, currentTierConfig)
-
-				err := c.lineController.SendMissionSuccessFlexMessage(
+			notify = func() error {
+				return c.lineController.SendMissionSuccessFlexMessage(
+					ctx,
 					mission.UserID,
 					strconv.Itoa(mission.CurrentTier),
 					strconv.Itoa(currentTier.CurrentLevel-1), // Send the completed level
 					mission.ID,
 				)
-				if err != nil {
-					log.Printf("Failed to send mission success notification: %v", err)
-				}
 			}
 		} else {
-			// Tier 3 handling
+			// Tier 1-2 ครบทุก level หรือ Tier 3 ผ่าน level: รอรับรางวัล
 			currentTier.Status = "awaiting_reward"
-			log.Printf("Mission ID: %s, Tier: 3, Level: %d - AWAITING REWARD", mission.ID.Hex(), currentTier.CurrentLevel)
-			c.createRewardExpirationEvent(ctx, mission, currentTier, currentTierConfig)
+			currentTier.ExpireReward = time.Now().Add(time.Duration(currentTierConfig.ExpireRewardHours) * time.Hour)
+			rewardPending = true
+			log.Printf("Mission ID: %s, Tier: %d, Level: %d - AWAITING REWARD", mission.ID.Hex(), mission.CurrentTier, currentTier.CurrentLevel)
 
-			err := c.lineController.SendMissionCompleteFlexMessage(
-				mission.UserID,
-				fmt.Sprintf("%d", currentTierConfig.ExpireRewardHours),
-				strconv.Itoa(mission.CurrentTier),
-				strconv.Itoa(currentTier.CurrentLevel),
-				mission.ID,
-			)
-			if err != nil {
-				log.Printf("Failed to send mission complete notification: %v", err)
+			notify = func() error {
+				return c.lineController.SendMissionCompleteFlexMessage(
+					ctx,
+					mission.UserID,
+					fmt.Sprintf("%d", currentTierConfig.ExpireRewardHours),
+					strconv.Itoa(mission.CurrentTier),
+					strconv.Itoa(currentTier.CurrentLevel),
+					mission.ID,
+				)
 			}
 		}
 	} else {
@@ -187,35 +327,53 @@ func (c *ExpirationEventController) handleLevelExpiration(ctx context.Context, m
 			} else {
 				// Continue to next level in Tier 3
 				currentTier.CurrentLevel++
-				newLevel := createNewLevel(currentTier.CurrentLevel, currentTierConfig.Period, currentTierConfig.FollowUpHours)
-				currentTier.Levels = append(currentTier.Levels, newLevel)
-				c.createNewEvents(ctx, mission, currentTier, &newLevel, currentTierConfig)
+				level := createNewLevel(currentTier.CurrentLevel, currentTierConfig.Period, currentTierConfig.FollowUpHours)
+				currentTier.Levels = append(currentTier.Levels, level)
+				newLevel = &currentTier.Levels[len(currentTier.Levels)-1]
 				currentTier.Status = "processing"
 			}
 		}
 
 		// Send mission failed notification
-		err := c.lineController.SendMissionFailedFlexMessage(
-			mission.UserID,
-			fmt.Sprintf("%d", currentTierConfig.Target),
-			strconv.Itoa(mission.CurrentTier),
-			strconv.Itoa(currentTier.CurrentLevel),
-			mission.ID,
-		)
-		if err != nil {
-			log.Printf("Failed to send mission failed notification: %v", err)
+		notify = func() error {
+			return c.lineController.SendMissionFailedFlexMessage(
+				ctx,
+				mission.UserID,
+				fmt.Sprintf("%d", currentTierConfig.Target),
+				strconv.Itoa(mission.CurrentTier),
+				strconv.Itoa(currentTier.CurrentLevel),
+				mission.ID,
+			)
 		}
 	}
 
 	mission.UpdatedAt = time.Now()
-	_, err = c.missionCollection.UpdateOne(
+	result, err := c.missionCollection.UpdateOne(
 		ctx,
-		bson.M{"_id": mission.ID},
+		bson.M{
+			"_id": mission.ID,
+			fmt.Sprintf("tiers.%d.levels.%d.status", tierIndex, levelNumber-1): "processing",
+		},
 		bson.M{"$set": mission},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update mission: %v", err)
 	}
+	if result.MatchedCount == 0 {
+		log.Printf("Mission ID: %s, Tier: %d, Level: %d - already evaluated by another run, skipping", mission.ID.Hex(), tierIndex+1, levelNumber)
+		return nil
+	}
+	eventbus.Default.LevelOutcome(ctx, *mission, tierIndex+1, levelNumber, mission.Tiers[tierIndex].Levels[levelNumber-1], currentTierConfig.Target)
+
+	if newLevel != nil {
+		c.createNewEvents(ctx, mission, currentTier, newLevel, currentTierConfig)
+	}
+	if rewardPending {
+		c.createRewardExpirationEvent(ctx, mission, currentTier, currentTierConfig)
+	}
+	if err := notify(); err != nil {
+		log.Printf("Failed to send mission %s notification: %v", currentLevel.Status, err)
+	}
 
 	log.Printf("Mission ID: %s updated. Status: %s, Tier Status: %s, Level Status: %s",
 		mission.ID.Hex(), mission.Status, currentTier.Status, currentLevel.Status)
@@ -225,7 +383,7 @@ func (c *ExpirationEventController) handleLevelExpiration(ctx context.Context, m
 
 func (c *ExpirationEventController) handleFollowUp(ctx context.Context, mission *models.Mission, currentLevel *models.Level, currentTierConfig models.TierDetail) error {
 	var config models.Config
-	err := c.configCollection.FindOne(ctx, bson.M{}).Decode(&config)
+	err := c.configService.Load(ctx, &config)
 	if err != nil {
 		return fmt.Errorf("failed to fetch config: %v", err)
 	}
@@ -247,6 +405,7 @@ func (c *ExpirationEventController) handleFollowUp(ctx context.Context, mission
 
 	// Send follow-up notification
 	err = c.lineController.SendFollowUpFlexMessage(
+		ctx,
 		mission.UserID,
 		fmt.Sprintf("%d", currentTierConfig.Target),
 		fmt.Sprintf("%.2f", currentBet),
@@ -292,6 +451,7 @@ func (c *ExpirationEventController) handleRewardExpiration(ctx context.Context,
 					"status":       latestMission.Status,
 					"current_tier": latestMission.CurrentTier,
 					fmt.Sprintf("tiers.%d.status", mission.CurrentTier-1): "expire_reward",
+					"updated_at": time.Now(),
 				}},
 			)
 			if err != nil {
@@ -319,6 +479,7 @@ func (c *ExpirationEventController) handleRewardNotification(ctx context.Context
 	levelString := strconv.Itoa(currentTier.CurrentLevel)
 
 	err := c.lineController.SendRewardNotificationFlexMessage(
+		ctx,
 		mission.UserID,
 		fmt.Sprintf("%d", remainingDays),
 		tierString,
@@ -337,6 +498,7 @@ func (c *ExpirationEventController) createNewEvents(ctx context.Context, mission
 	log.Printf("Creating new events with Processing Delay: %v", processingDelay)
 
 	levelExpirationEvent := models.ExpirationEvent{
+		TenantID:   mission.TenantID,
 		MissionID:  mission.ID,
 		TierIndex:  mission.CurrentTier - 1,
 		LevelIndex: currentTier.CurrentLevel - 1,
@@ -344,13 +506,14 @@ func (c *ExpirationEventController) createNewEvents(ctx context.Context, mission
 		Status:     "pending",
 		Type:       "level_expiration",
 	}
-	_, err := c.eventCollection.InsertOne(ctx, levelExpirationEvent)
+	err := c.scheduleEvent(ctx, levelExpirationEvent)
 	if err != nil {
 		log.Printf("Failed to create new level expiration event: %v", err)
 	}
 	log.Printf("Level expiration event created with ExpireTime: %v", levelExpirationEvent.ExpireTime)
 
 	followUpEvent := models.ExpirationEvent{
+		TenantID:   mission.TenantID,
 		MissionID:  mission.ID,
 		TierIndex:  mission.CurrentTier - 1,
 		LevelIndex: currentTier.CurrentLevel - 1,
@@ -358,17 +521,18 @@ func (c *ExpirationEventController) createNewEvents(ctx context.Context, mission
 		Status:     "pending",
 		Type:       "follow_up",
 	}
-	_, err = c.eventCollection.InsertOne(ctx, followUpEvent)
+	err = c.scheduleEvent(ctx, followUpEvent)
 	if err != nil {
 		log.Printf("Failed to create follow-up event: %v", err)
 	}
 }
 
+// createRewardExpirationEvent สร้าง event หมดอายุรางวัลและแจ้งเตือนตาม currentTier.ExpireReward ที่บันทึกไว้ในมิชชันแล้ว
 func (c *ExpirationEventController) createRewardExpirationEvent(ctx context.Context, mission *models.Mission, currentTier *models.Tier, currentTierConfig models.TierDetail) {
-	expireRewardTime := time.Now().Add(time.Duration(currentTierConfig.ExpireRewardHours) * time.Hour)
-	currentTier.ExpireReward = expireRewardTime
+	expireRewardTime := currentTier.ExpireReward
 
 	rewardExpirationEvent := models.ExpirationEvent{
+		TenantID:   mission.TenantID,
 		MissionID:  mission.ID,
 		TierIndex:  mission.CurrentTier - 1,
 		LevelIndex: currentTier.CurrentLevel - 1,
@@ -376,8 +540,7 @@ func (c *ExpirationEventController) createRewardExpirationEvent(ctx context.Cont
 		Status:     "pending",
 		Type:       "reward_expiration",
 	}
-	_, err := c.eventCollection.InsertOne(ctx, rewardExpirationEvent)
-	if err != nil {
+	if err := c.scheduleEvent(ctx, rewardExpirationEvent); err != nil {
 		log.Printf("Failed to create reward expiration event: %v", err)
 	}
 
@@ -387,6 +550,7 @@ func (c *ExpirationEventController) createRewardExpirationEvent(ctx context.Cont
 		if currentTierConfig.NotifyBeforeExpire > 0 {
 			notifyTime := expireRewardTime.Add(-time.Duration(currentTierConfig.NotifyBeforeExpire) * time.Hour)
 			notificationEvent := models.ExpirationEvent{
+				TenantID:   mission.TenantID,
 				MissionID:  mission.ID,
 				TierIndex:  mission.CurrentTier - 1,
 				LevelIndex: currentTier.CurrentLevel - 1,
@@ -394,18 +558,19 @@ func (c *ExpirationEventController) createRewardExpirationEvent(ctx context.Cont
 				Status:     "pending",
 				Type:       "reward_notification",
 			}
-			_, err := c.eventCollection.InsertOne(ctx, notificationEvent)
-			if err != nil {
+			if err := c.scheduleEvent(ctx, notificationEvent); err != nil {
 				log.Printf("Failed to create notification event for Tier %d: %v", mission.CurrentTier, err)
 			}
 		}
 	} else {
 		// For Tier 3: Create recurring notification events
+		// นับถอยหลังจากเวลาหมดอายุ เวลาของแต่ละรอบจึงเหมือนเดิมทุกครั้งที่คำนวณ
 		if currentTierConfig.NotifyInterval > 0 {
 			now := time.Now()
 			notifyInterval := time.Duration(currentTierConfig.NotifyInterval) * time.Hour
-			for notifyTime := now.Add(notifyInterval); notifyTime.Before(expireRewardTime); notifyTime = notifyTime.Add(notifyInterval) {
+			for notifyTime := expireRewardTime.Add(-notifyInterval); notifyTime.After(now); notifyTime = notifyTime.Add(-notifyInterval) {
 				notificationEvent := models.ExpirationEvent{
+					TenantID:   mission.TenantID,
 					MissionID:  mission.ID,
 					TierIndex:  mission.CurrentTier - 1,
 					LevelIndex: currentTier.CurrentLevel - 1,
@@ -413,43 +578,61 @@ func (c *ExpirationEventController) createRewardExpirationEvent(ctx context.Cont
 					Status:     "pending",
 					Type:       "recurring_reward_notification",
 				}
-				_, err := c.eventCollection.InsertOne(ctx, notificationEvent)
-				if err != nil {
+				if err := c.scheduleEvent(ctx, notificationEvent); err != nil {
 					log.Printf("Failed to create recurring notification event for Tier 3: %v", err)
 				}
 			}
 		}
 	}
+}
 
-	// Update the mission with the new ExpireReward time and status
-	update := bson.M{
-		fmt.Sprintf("tiers.%d.expire_reward", mission.CurrentTier-1): expireRewardTime,
-		fmt.Sprintf("tiers.%d.status", mission.CurrentTier-1):        "completed",
-	}
-
-	if mission.CurrentTier == 3 {
-		// For Tier 3, also update the current level's status
-		update[fmt.Sprintf("tiers.%d.levels.%d.status", mission.CurrentTier-1, currentTier.CurrentLevel-1)] = "completed"
-	}
-
-	_, err = c.missionCollection.UpdateOne(
+// scheduleEvent สร้าง event ถ้ายังไม่มี event ชนิดเดียวกันของ mission/tier/level ที่เวลาเดียวกัน
+// สร้างซ้ำ (เช่น ประมวลผล event ต้นทางใหม่) จึงไม่เกิด event และข้อความซ้ำ
+func (c *ExpirationEventController) scheduleEvent(ctx context.Context, event models.ExpirationEvent) error {
+	_, err := c.eventCollection.UpdateOne(
 		ctx,
-		bson.M{"_id": mission.ID},
-		bson.M{"$set": update},
+		bson.M{
+			"mission_id":  event.MissionID,
+			"tier_index":  event.TierIndex,
+			"level_index": event.LevelIndex,
+			"type":        event.Type,
+			"expire_time": event.ExpireTime,
+		},
+		bson.M{"$setOnInsert": event},
+		options.Update().SetUpsert(true),
 	)
-	if err != nil {
-		log.Printf("Failed to update mission with new ExpireReward time and status: %v", err)
-	}
+	return err
 }
 
-func (c *ExpirationEventController) getNextTierConfig(ctx context.Context, nextTierIndex int) models.TierDetail {
-	var config models.Config
-	err := c.configCollection.FindOne(ctx, bson.M{}).Decode(&config)
+func (c *ExpirationEventController) getNextTierConfig(ctx context.Context, mission *models.Mission, nextTierIndex int) models.TierDetail {
+	config, err := c.configService.Effective(ctx, time.Now())
 	if err != nil {
 		log.Printf("Failed to fetch config: %v", err)
 		return models.TierDetail{} // Return empty config in case of error
 	}
-	return config.Tiers[nextTierIndex-1]
+	tierConfig, err := missionTierRule(mission, config, nextTierIndex-1)
+	if err != nil {
+		log.Printf("Failed to get tier config: %v", err)
+		return models.TierDetail{}
+	}
+	return tierConfig
+}
+
+// missionTierRules คืนกติกา tier ที่ถูก snapshot ไว้ตอนสร้างมิชชัน ปรับด้วย overlay ที่มีผลอยู่ใน config
+// มิชชันเก่าที่ยังไม่มี snapshot จะใช้ config ปัจจุบันแทน (ซึ่งรวม overlay แล้วถ้าได้มาจาก Effective)
+func missionTierRules(mission *models.Mission, config models.Config) []models.TierDetail {
+	if len(mission.TierRules) > 0 {
+		return utils.ApplyTierOverlay(mission.TierRules, config.ActiveOverlay)
+	}
+	return config.Tiers
+}
+
+func missionTierRule(mission *models.Mission, config models.Config, tierIndex int) (models.TierDetail, error) {
+	rules := missionTierRules(mission, config)
+	if tierIndex < 0 || tierIndex >= len(rules) {
+		return models.TierDetail{}, fmt.Errorf("mission %s has no tier rule for tier %d", mission.ID.Hex(), tierIndex+1)
+	}
+	return rules[tierIndex], nil
 }
 
 func createNewLevel(levelNumber, period, followUpDays int) models.Level {
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExportController ส่งออกข้อมูลเป็น CSV (UTF-8 BOM) หรือ XLSX แบบ stream ผ่าน cursor
+// ใช้ filter ชุดเดียวกับ endpoint รายการ เลือกรูปแบบด้วย ?format=csv|xlsx
+type ExportController struct {
+	missionCollection *mongo.Collection
+	clientCollection  *mongo.Collection
+	logCollection     *mongo.Collection
+	messageCollection *mongo.Collection
+}
+
+func NewExportController(missionCollection, clientCollection, logCollection, messageCollection *mongo.Collection) *ExportController {
+	return &ExportController{
+		missionCollection: missionCollection,
+		clientCollection:  clientCollection,
+		logCollection:     logCollection,
+		messageCollection: messageCollection,
+	}
+}
+
+const exportBatchSize = 500
+
+// exportRows แปลงเอกสารหนึ่งรายการเป็นแถว (หนึ่งเอกสารอาจได้หลายแถว)
+type exportRows func(cursor *mongo.Cursor) ([][]interface{}, error)
+
+// exportQuery ใช้ query string แบบเดียวกับ endpoint รายการ (ดู utils.ParseListQuery)
+// และรับ ?from/?to เป็นทางลัดของช่วงเวลาบน dateField
+func exportQuery(c *fiber.Ctx, schema utils.QuerySchema, dateField string) (utils.ListQuery, error) {
+	q, err := parseListQuery(c, schema, "format", "from", "to")
+	if err != nil {
+		return q, err
+	}
+	dateFilter, err := utils.ParseDateFilter(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return q, err
+	}
+	if dateFilter != nil {
+		q.Filter[dateField] = dateFilter
+	}
+	return q, nil
+}
+
+// stream เปิด cursor ก่อนตอบกลับ (เพื่อแจ้ง error เป็น JSON ได้) แล้วเขียนทีละแถวใน body stream
+func (ec *ExportController) stream(c *fiber.Ctx, collection *mongo.Collection, filter bson.M, sort bson.D, name string, header []interface{}, rows exportRows) error {
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "format must be csv or xlsx"})
+	}
+
+	ctx := c.UserContext()
+	opts := options.Find().SetSort(sort).SetBatchSize(exportBatchSize)
+	cursor, err := collection.Find(ctx, tenant.Scope(ctx, filter), opts)
+	if err != nil {
+		log.Printf("Export %s: Database error: %v", name, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to export " + name})
+	}
+
+	contentType, ext := utils.ExportContentType(format)
+	c.Attachment(fmt.Sprintf("%s_%s%s", name, time.Now().In(utils.AnalyticsLocation()).Format("20060102_150405"), ext))
+	c.Set(fiber.HeaderContentType, contentType)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cursor.Close(context.Background())
+
+		table, err := utils.NewTableWriter(w, format, name)
+		if err != nil {
+			log.Printf("Export %s: %v", name, err)
+			return
+		}
+		if err := table.WriteRow(header); err != nil {
+			log.Printf("Export %s: %v", name, err)
+			return
+		}
+
+		count := 0
+		for cursor.Next(ctx) {
+			docRows, err := rows(cursor)
+			if err != nil {
+				log.Printf("Export %s: failed to decode document: %v", name, err)
+				continue
+			}
+			for _, row := range docRows {
+				if err := table.WriteRow(row); err != nil {
+					log.Printf("Export %s: client disconnected after %d documents: %v", name, count, err)
+					return
+				}
+			}
+			count++
+		}
+		if err := cursor.Err(); err != nil {
+			log.Printf("Export %s: cursor error after %d documents: %v", name, count, err)
+		}
+		if err := table.Close(); err != nil {
+			log.Printf("Export %s: %v", name, err)
+			return
+		}
+		w.Flush()
+		log.Printf("Export %s: %d documents exported as %s", name, count, format)
+	})
+	return nil
+}
+
+// ExportMissions - มิชชันแบบหนึ่งแถวต่อ level (filter เดียวกับ /api/tbl_mission, ?from/?to ตาม created_at)
+func (ec *ExportController) ExportMissions(c *fiber.Ctx) error {
+	q, err := exportQuery(c, MissionQuerySchema, "created_at")
+	if err != nil {
+		return listQueryFailed(c, err)
+	}
+
+	header := []interface{}{
+		"mission_id", "user_id", "phone_number", "mission_status", "current_tier", "mission_created_at", "mission_updated_at",
+		"tier", "tier_name", "tier_status", "reward", "target", "reward_expire",
+		"level", "level_name", "level_status", "start_date", "expire_date", "current_bet",
+	}
+	return ec.stream(c, ec.missionCollection, q.Filter, q.Sort, "missions", header, func(cursor *mongo.Cursor) ([][]interface{}, error) {
+		var mission models.Mission
+		if err := cursor.Decode(&mission); err != nil {
+			return nil, err
+		}
+		base := []interface{}{
+			mission.ID, mission.UserID, mission.PhoneNumber, mission.Status, mission.CurrentTier, mission.CreatedAt, mission.UpdatedAt,
+		}
+
+		var rows [][]interface{}
+		for tierIndex, tier := range mission.Tiers {
+			tierColumns := []interface{}{tierIndex + 1, tier.Name, tier.Status, tier.Reward, tier.Target, tier.ExpireReward}
+			if len(tier.Levels) == 0 {
+				rows = append(rows, exportRow(base, tierColumns, make([]interface{}, 6)))
+			}
+			for levelIndex, level := range tier.Levels {
+				rows = append(rows, exportRow(base, tierColumns, []interface{}{
+					levelIndex + 1, level.Name, level.Status, level.StartDate, level.ExpireDate, level.CurrentBet,
+				}))
+			}
+		}
+		if len(rows) == 0 {
+			rows = append(rows, exportRow(base, make([]interface{}, 12)))
+		}
+		return rows, nil
+	})
+}
+
+// ExportClients - ลูกค้า (filter เดียวกับ GET /api/clients, ?from/?to ตาม created_at)
+func (ec *ExportController) ExportClients(c *fiber.Ctx) error {
+	q, err := exportQuery(c, ClientQuerySchema, "created_at")
+	if err != nil {
+		return listQueryFailed(c, err)
+	}
+
+	header := []interface{}{"user_id", "display_name", "phone_number", "status_message", "created_at", "updated_at"}
+	return ec.stream(c, ec.clientCollection, q.Filter, q.Sort, "clients", header, func(cursor *mongo.Cursor) ([][]interface{}, error) {
+		var client models.Client
+		if err := cursor.Decode(&client); err != nil {
+			return nil, err
+		}
+		return [][]interface{}{{
+			client.UserID, client.DisplayName, client.PhoneNumber, client.StatusMessage, client.CreatedAt, client.UpdatedAt,
+		}}, nil
+	})
+}
+
+// ExportClaims - การขอรับรางวัลจาก tbl_logs (เช่น ?status=in:approve,reject, ?from/?to ตาม created_at)
+func (ec *ExportController) ExportClaims(c *fiber.Ctx) error {
+	q, err := exportQuery(c, ClaimQuerySchema, "created_at")
+	if err != nil {
+		return listQueryFailed(c, err)
+	}
+
+	header := []interface{}{"log_id", "user_id", "mission_id", "tier", "mission_detail", "reward", "status", "created_at", "callback_time"}
+	return ec.stream(c, ec.logCollection, q.Filter, q.Sort, "claims", header, func(cursor *mongo.Cursor) ([][]interface{}, error) {
+		var entry models.Log
+		if err := cursor.Decode(&entry); err != nil {
+			return nil, err
+		}
+		var tier interface{}
+		if entry.Tier > 0 {
+			tier = entry.Tier
+		}
+		return [][]interface{}{{
+			entry.ID, entry.UserID, entry.MissionID, tier, entry.MissionDetail, entry.Reward, entry.Status, entry.CreatedAt, entry.CallbackTime,
+		}}, nil
+	})
+}
+
+// ExportMessages - ข้อความที่ส่งหาลูกค้า (filter เดียวกับ /api/tbl_logs_message, ?from/?to ตาม sent_at)
+func (ec *ExportController) ExportMessages(c *fiber.Ctx) error {
+	q, err := exportQuery(c, MessageQuerySchema, "sent_at")
+	if err != nil {
+		return listQueryFailed(c, err)
+	}
+
+	header := []interface{}{"message_id", "user_id", "status", "tier", "level", "mission_id", "broadcast_id", "title", "description", "sent_at", "read_at", "deleted_at"}
+	return ec.stream(c, ec.messageCollection, q.Filter, q.Sort, "messages", header, func(cursor *mongo.Cursor) ([][]interface{}, error) {
+		var message models.MessageLog
+		if err := cursor.Decode(&message); err != nil {
+			return nil, err
+		}
+		return [][]interface{}{{
+			message.ID, message.UserID, message.Status, message.Tier, message.Level, message.MissionID, message.BroadcastID,
+			message.FlexContent.Title, message.FlexContent.Description, message.SentAt, message.ReadAt, message.DeletedAt,
+		}}, nil
+	})
+}
+
+func exportRow(parts ...[]interface{}) []interface{} {
+	var row []interface{}
+	for _, part := range parts {
+		row = append(row, part...)
+	}
+	return row
+}
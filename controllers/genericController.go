@@ -2,8 +2,14 @@ package controllers
 
 import (
 	"context"
-	"math"
-	"strconv"
+	"encoding/json"
+	"fmt"
+	"go-server/tenant"
+	"go-server/utils"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,27 +18,150 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type GenericController struct {
-	Collection   *mongo.Collection
-	SearchFields []string
-	SortFields   []string
+// ResourceOp การดำเนินการที่ Validate ถูกเรียก
+type ResourceOp string
+
+const (
+	ResourceCreate ResourceOp = "create"
+	ResourceUpdate ResourceOp = "update"
+)
+
+// Resource กำหนดว่า GenericController เปิด collection ให้แก้ไขผ่าน API ได้แค่ไหน
+// ชื่อ field ใน CreateFields, UpdateFields และ ReadOnlyFields เป็นชื่อ json ของ T
+type Resource[T any] struct {
+	Collection *mongo.Collection
+	Query      utils.QuerySchema // field ที่กรอง เรียง และเลือกคืนได้ใน GetAll/Search
+
+	CreateFields   []string // field ที่ส่งมาตอน POST ได้ ว่างคือปิด POST
+	UpdateFields   []string // field ที่ส่งมาตอน PUT ได้ ว่างคือปิด PUT
+	ReadOnlyFields []string // field ที่แก้ไม่ได้เลย (แจ้ง error ต่างจาก field ที่ไม่อยู่ใน allowlist)
+	HiddenFields   []string // ชื่อ bson ที่ไม่อ่านออกจากฐานข้อมูล เช่น password
+	TenantScoped   bool     // collection มี tenant_id
+	DisableDelete  bool     // ปิด DELETE (เช่น event ที่ worker ยังต้องใช้)
+
+	// Validate ตรวจเอกสารทั้งฉบับหลังรวมค่าที่ส่งมาแล้ว
+	Validate func(ctx context.Context, item *T, op ResourceOp) utils.ValidationErrors
+}
+
+// GenericController CRUD ของ collection ผ่าน model T แทน bson.M ดิบ
+type GenericController[T any] struct {
+	resource Resource[T]
+	fields   map[string]resourceField // ชื่อ json -> field ของ T
+}
+
+type resourceField struct {
+	index   int
+	bsonKey string
+}
+
+func NewGenericController[T any](resource Resource[T]) *GenericController[T] {
+	fields := resourceFields(reflect.TypeOf((*T)(nil)).Elem())
+	for _, name := range append(append([]string{}, resource.CreateFields...), resource.UpdateFields...) {
+		if _, ok := fields[name]; !ok {
+			panic(fmt.Sprintf("resource %s: %q is not a field of %T", resource.Collection.Name(), name, *new(T)))
+		}
+	}
+	return &GenericController[T]{resource: resource, fields: fields}
+}
+
+// resourceFields จับคู่ชื่อ json กับชื่อ bson ของ field ระดับบนของ struct (field ที่ json:"-" ไม่เปิดให้ API)
+func resourceFields(t reflect.Type) map[string]resourceField {
+	fields := map[string]resourceField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+		bsonName := strings.Split(f.Tag.Get("bson"), ",")[0]
+		if !f.IsExported() || jsonName == "-" || bsonName == "-" {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = f.Name
+		}
+		if bsonName == "" {
+			bsonName = strings.ToLower(f.Name)
+		}
+		fields[jsonName] = resourceField{index: i, bsonKey: bsonName}
+	}
+	return fields
+}
+
+func (gc *GenericController[T]) scope(ctx context.Context, filter bson.M) bson.M {
+	if gc.resource.TenantScoped {
+		return tenant.Scope(ctx, filter)
+	}
+	return filter
 }
 
-func NewGenericController(collection *mongo.Collection, searchFields []string, sortFields []string) *GenericController {
-	return &GenericController{
-		Collection:   collection,
-		SearchFields: searchFields,
-		SortFields:   sortFields,
+func (gc *GenericController[T]) projection() bson.M {
+	if len(gc.resource.HiddenFields) == 0 {
+		return nil
 	}
+	projection := bson.M{}
+	for _, field := range gc.resource.HiddenFields {
+		projection[field] = 0
+	}
+	return projection
 }
 
-func (gc *GenericController) Create(c *fiber.Ctx) error {
-	var data map[string]interface{}
-	if err := c.BodyParser(&data); err != nil {
+// checkFields ตรวจว่า body มีเฉพาะ field ที่ op นี้อนุญาต คืน key ที่ส่งมา
+func (gc *GenericController[T]) checkFields(body []byte, allowed []string) (map[string]json.RawMessage, utils.ValidationErrors, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	errs := utils.ValidationErrors{}
+	for key := range raw {
+		switch {
+		case containsField(gc.resource.ReadOnlyFields, key):
+			errs = append(errs, utils.FieldError{Field: key, Message: "field is read-only"})
+		case !containsField(allowed, key):
+			errs = append(errs, utils.FieldError{Field: key, Message: "field cannot be set"})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return raw, errs, nil
+}
+
+func (gc *GenericController[T]) validate(ctx context.Context, item *T, op ResourceOp) utils.ValidationErrors {
+	if gc.resource.Validate == nil {
+		return nil
+	}
+	return gc.resource.Validate(ctx, item, op)
+}
+
+func (gc *GenericController[T]) Create(c *fiber.Ctx) error {
+	if len(gc.resource.CreateFields) == 0 {
+		return c.Status(fiber.StatusMethodNotAllowed).JSON(fiber.Map{"error": "Items cannot be created through this endpoint"})
+	}
+
+	_, errs, err := gc.checkFields(c.Body(), gc.resource.CreateFields)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
+	if len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
 
-	result, err := gc.Collection.InsertOne(context.Background(), data)
+	var item T
+	if err := json.Unmarshal(c.Body(), &item); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if errs := gc.validate(c.UserContext(), &item, ResourceCreate); len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
+
+	doc, err := toBsonM(item)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if gc.resource.TenantScoped {
+		if tenantID := tenant.Value(c.UserContext()); tenantID != "" {
+			doc["tenant_id"] = tenantID
+		}
+	}
+
+	result, err := gc.resource.Collection.InsertOne(c.UserContext(), doc)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create item"})
 	}
@@ -40,42 +169,47 @@ func (gc *GenericController) Create(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"id": result.InsertedID})
 }
 
-func (gc *GenericController) GetAll(c *fiber.Ctx) error {
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
-	skip := (page - 1) * limit
+// GetAll และ Search รับ query string เดียวกัน (ดู utils.ParseListQuery และ findListPage) Search คงไว้ให้ client เดิม
+func (gc *GenericController[T]) GetAll(c *fiber.Ctx) error {
+	return gc.list(c)
+}
+
+func (gc *GenericController[T]) Search(c *fiber.Ctx) error {
+	return gc.list(c)
+}
 
-	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit))
-	cursor, err := gc.Collection.Find(context.Background(), bson.M{}, opts)
+func (gc *GenericController[T]) list(c *fiber.Ctx) error {
+	q, err := parseListQuery(c, gc.resource.Query)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch items"})
+		return listQueryFailed(c, err)
 	}
-	defer cursor.Close(context.Background())
+	filter := gc.scope(c.UserContext(), q.Filter)
 
-	var items []bson.M
-	if err = cursor.All(context.Background(), &items); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode items"})
+	projection := q.Projection
+	if projection == nil {
+		projection = gc.projection()
+	}
+	items, page, err := findListPage[T](c, gc.resource.Collection, filter, q, projection, 20, 100)
+	if err != nil {
+		return listPageFailed(c, err, "Failed to fetch items")
+	}
+	projected, err := projectItems(items, reflect.TypeOf((*T)(nil)).Elem(), q.Fields)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to encode items"})
 	}
 
-	totalItems, _ := gc.Collection.CountDocuments(context.Background(), bson.M{})
-	totalPages := int(math.Ceil(float64(totalItems) / float64(limit)))
-
-	return c.JSON(fiber.Map{
-		"items":       items,
-		"currentPage": page,
-		"totalPages":  totalPages,
-		"totalItems":  totalItems,
-	})
+	return c.JSON(utils.ListPage{Items: projected, Pagination: page})
 }
 
-func (gc *GenericController) GetById(c *fiber.Ctx) error {
+func (gc *GenericController[T]) GetById(c *fiber.Ctx) error {
 	id, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
-	var item bson.M
-	err = gc.Collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&item)
+	var item T
+	opts := options.FindOne().SetProjection(gc.projection())
+	err = gc.resource.Collection.FindOne(context.Background(), gc.scope(c.UserContext(), bson.M{"_id": id}), opts).Decode(&item)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Item not found"})
@@ -86,21 +220,60 @@ func (gc *GenericController) GetById(c *fiber.Ctx) error {
 	return c.JSON(item)
 }
 
-func (gc *GenericController) Update(c *fiber.Ctx) error {
+// Update แก้เฉพาะ field ใน UpdateFields ค่าที่ส่งมาถูกรวมกับเอกสารเดิมแล้วตรวจทั้งฉบับก่อน $set
+func (gc *GenericController[T]) Update(c *fiber.Ctx) error {
+	if len(gc.resource.UpdateFields) == 0 {
+		return c.Status(fiber.StatusMethodNotAllowed).JSON(fiber.Map{"error": "Items cannot be updated through this endpoint"})
+	}
+
 	id, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
-	var data map[string]interface{}
-	if err := c.BodyParser(&data); err != nil {
+	raw, errs, err := gc.checkFields(c.Body(), gc.resource.UpdateFields)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
+	if len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
+	if len(raw) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No fields to update"})
+	}
 
-	update := bson.M{"$set": data}
-	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	var updatedItem bson.M
-	err = gc.Collection.FindOneAndUpdate(context.Background(), bson.M{"_id": id}, update, opts).Decode(&updatedItem)
+	filter := gc.scope(c.UserContext(), bson.M{"_id": id})
+	var item T
+	err = gc.resource.Collection.FindOne(c.UserContext(), filter).Decode(&item)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Item not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch item"})
+	}
+
+	if err := json.Unmarshal(c.Body(), &item); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if errs := gc.validate(c.UserContext(), &item, ResourceUpdate); len(errs) > 0 {
+		return validationFailed(c, errs)
+	}
+
+	// ค่าที่จะ $set มาจาก struct ที่ตรวจแล้ว ไม่ใช่จาก body โดยตรง
+	value := reflect.ValueOf(item)
+	set := bson.M{}
+	for key := range raw {
+		field := gc.fields[key]
+		set[field.bsonKey] = value.Field(field.index).Interface()
+	}
+	if _, ok := gc.bsonField("updated_at"); ok {
+		set["updated_at"] = time.Now()
+	}
+
+	update := bson.M{"$set": set}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(gc.projection())
+	var updatedItem T
+	err = gc.resource.Collection.FindOneAndUpdate(c.UserContext(), filter, update, opts).Decode(&updatedItem)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Item not found"})
@@ -111,13 +284,26 @@ func (gc *GenericController) Update(c *fiber.Ctx) error {
 	return c.JSON(updatedItem)
 }
 
-func (gc *GenericController) Delete(c *fiber.Ctx) error {
+func (gc *GenericController[T]) bsonField(bsonKey string) (resourceField, bool) {
+	for _, field := range gc.fields {
+		if field.bsonKey == bsonKey {
+			return field, true
+		}
+	}
+	return resourceField{}, false
+}
+
+func (gc *GenericController[T]) Delete(c *fiber.Ctx) error {
+	if gc.resource.DisableDelete {
+		return c.Status(fiber.StatusMethodNotAllowed).JSON(fiber.Map{"error": "Items cannot be deleted through this endpoint"})
+	}
+
 	id, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
-	result, err := gc.Collection.DeleteOne(context.Background(), bson.M{"_id": id})
+	result, err := gc.resource.Collection.DeleteOne(context.Background(), gc.scope(c.UserContext(), bson.M{"_id": id}))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete item"})
 	}
@@ -129,54 +315,20 @@ func (gc *GenericController) Delete(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Item deleted successfully"})
 }
 
-func (gc *GenericController) Search(c *fiber.Ctx) error {
-	query := c.Query("query")
-	field := c.Query("field", "")
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
-	skip := (page - 1) * limit
-
-	searchQuery := bson.M{}
-	if query != "" && field != "" {
-		searchQuery[field] = primitive.Regex{Pattern: query, Options: "i"}
-	} else if query != "" {
-		orConditions := []bson.M{}
-		for _, field := range gc.SearchFields {
-			orConditions = append(orConditions, bson.M{field: primitive.Regex{Pattern: query, Options: "i"}})
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
 		}
-		searchQuery["$or"] = orConditions
 	}
+	return false
+}
 
-	// Create dynamic sort based on SortFields
-	sort := bson.D{}
-	for _, field := range gc.SortFields {
-		// Assume descending order for all fields. You can modify this logic if needed.
-		sort = append(sort, bson.E{Key: field, Value: -1})
-	}
-
-	opts := options.Find().
-		SetSort(sort).
-		SetSkip(int64(skip)).
-		SetLimit(int64(limit))
-
-	cursor, err := gc.Collection.Find(context.Background(), searchQuery, opts)
+func toBsonM(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to search items"})
-	}
-	defer cursor.Close(context.Background())
-
-	var items []bson.M
-	if err = cursor.All(context.Background(), &items); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode items"})
+		return nil, err
 	}
-
-	totalItems, _ := gc.Collection.CountDocuments(context.Background(), searchQuery)
-	totalPages := int(math.Ceil(float64(totalItems) / float64(limit)))
-
-	return c.JSON(fiber.Map{
-		"items":       items,
-		"currentPage": page,
-		"totalPages":  totalPages,
-		"totalItems":  totalItems,
-	})
+	doc := bson.M{}
+	return doc, bson.Unmarshal(data, &doc)
 }
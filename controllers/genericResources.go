@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"go-server/models"
+	"go-server/utils"
+	"net/mail"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// resource ของ collection ที่เปิดผ่าน SetupGenericRoutes
+// การสร้างเอกสารใหม่ต้องผ่าน endpoint เฉพาะ (/api/admin/register, /api/missions, การส่งข้อความ) จึงไม่เปิด POST
+
+var (
+	userRoles       = []string{"admin", "user"}
+	userStatuses    = []string{"active", "inactive"}
+	missionStatuses = []string{"processing", "pending", "completed", "failed"}
+	messageStatuses = []string{"sent", "unread", "read"}
+)
+
+// UserResource ผู้ใช้ admin แก้ได้เฉพาะอีเมล, role และสถานะ ไม่อ่าน password hash ออกมาเลย
+func UserResource(collection *mongo.Collection) Resource[models.User] {
+	return Resource[models.User]{
+		Collection:     collection,
+		Query:          UserQuerySchema,
+		UpdateFields:   []string{"email", "role", "status"},
+		ReadOnlyFields: []string{"id", "createDate"},
+		HiddenFields:   []string{"password"},
+		Validate: func(ctx context.Context, user *models.User, op ResourceOp) utils.ValidationErrors {
+			errs := utils.ValidationErrors{}
+			if _, err := mail.ParseAddress(user.Email); err != nil {
+				errs = append(errs, utils.FieldError{Field: "email", Message: "email must be a valid address"})
+			}
+			if !containsField(userRoles, user.Role) {
+				errs = append(errs, utils.FieldError{Field: "role", Message: "role must be admin or user"})
+			}
+			if !containsField(userStatuses, user.Status) {
+				errs = append(errs, utils.FieldError{Field: "status", Message: "status must be active or inactive"})
+			}
+			return errs
+		},
+	}
+}
+
+// MissionResource แก้ได้เฉพาะสถานะและเบอร์โทร ส่วน tier/level เปลี่ยนผ่าน flow ของมิชชันเท่านั้น
+func MissionResource(collection *mongo.Collection) Resource[models.Mission] {
+	return Resource[models.Mission]{
+		Collection:     collection,
+		Query:          MissionQuerySchema,
+		UpdateFields:   []string{"status", "phone_number"},
+		ReadOnlyFields: []string{"id", "tenant_id", "user_id", "created_at", "updated_at", "config_revision", "tier_rules"},
+		TenantScoped:   true,
+		Validate: func(ctx context.Context, mission *models.Mission, op ResourceOp) utils.ValidationErrors {
+			errs := utils.ValidationErrors{}
+			if !containsField(missionStatuses, mission.Status) {
+				errs = append(errs, utils.FieldError{Field: "status", Message: "status must be processing, pending, completed or failed"})
+			}
+			if mission.PhoneNumber == "" {
+				errs = append(errs, utils.FieldError{Field: "phone_number", Message: "phone number is required"})
+			}
+			return errs
+		},
+	}
+}
+
+// MessageResource ประวัติข้อความ แก้ได้เฉพาะสถานะการอ่าน
+func MessageResource(collection *mongo.Collection) Resource[models.MessageLog] {
+	return Resource[models.MessageLog]{
+		Collection:     collection,
+		Query:          MessageQuerySchema,
+		UpdateFields:   []string{"status"},
+		ReadOnlyFields: []string{"id", "tenant_id", "user_id", "mission_id", "broadcast_id", "sent_at", "flex_content"},
+		TenantScoped:   true,
+		Validate: func(ctx context.Context, message *models.MessageLog, op ResourceOp) utils.ValidationErrors {
+			if !containsField(messageStatuses, message.Status) {
+				return utils.ValidationErrors{{Field: "status", Message: "status must be sent, unread or read"}}
+			}
+			return nil
+		},
+	}
+}
+
+// EventResource event ของ expiration worker (tbl_events) อ่านได้อย่างเดียว
+func EventResource(collection *mongo.Collection) Resource[models.ExpirationEvent] {
+	return Resource[models.ExpirationEvent]{
+		Collection:    collection,
+		Query:         EventQuerySchema,
+		TenantScoped:  true,
+		DisableDelete: true,
+	}
+}
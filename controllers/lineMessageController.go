@@ -3,9 +3,14 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"go-server/config"
+	"go-server/eventbus"
 	"go-server/models"
+	"go-server/tenant"
+	"log"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/line/line-bot-sdk-go/v7/linebot"
@@ -15,32 +20,72 @@ import (
 )
 
 type LineController struct {
-	configCollection  *mongo.Collection
+	configService     *config.Service
 	messageCollection *mongo.Collection
-	bot               *linebot.Client
+
+	botMu sync.RWMutex
+	bots  map[string]*linebot.Client // LINE bot แยกตาม tenant
+}
+
+func NewLineController(configService *config.Service, messageCollection *mongo.Collection) (*LineController, error) {
+	lc := &LineController{
+		configService:     configService,
+		messageCollection: messageCollection,
+		bots:              make(map[string]*linebot.Client),
+	}
+
+	// สร้าง bot ของ tenant หลักไว้ก่อนเพื่อให้รู้ตั้งแต่ตอนเริ่มว่า config ใช้งานได้
+	if _, err := lc.client(context.Background()); err != nil {
+		return nil, err
+	}
+
+	configService.Subscribe(lc.onConfigChange)
+	return lc, nil
 }
 
-func NewLineController(configCollection, messageCollection *mongo.Collection) (*LineController, error) {
-	var config models.Config
-	err := configCollection.FindOne(context.Background(), bson.M{}).Decode(&config)
+// onConfigChange ทิ้ง LINE bot ของ tenant เมื่อ channel secret หรือ access token เปลี่ยน
+// bot ใหม่จะถูกสร้างตอนส่งข้อความครั้งถัดไป โดยไม่ต้อง restart
+func (lc *LineController) onConfigChange(tenantID string, previous, current models.Config) {
+	if previous.ChannelSecret == current.ChannelSecret && previous.ChannelAccessToken == current.ChannelAccessToken {
+		return
+	}
+
+	lc.botMu.Lock()
+	delete(lc.bots, tenantID)
+	lc.botMu.Unlock()
+	log.Printf("LINE channel credentials changed for tenant %s, bot client will be rebuilt", tenantID)
+}
+
+// client คืน LINE bot ของ tenant ใน ctx
+func (lc *LineController) client(ctx context.Context) (*linebot.Client, error) {
+	tenantID := tenant.FromContext(ctx)
+
+	lc.botMu.RLock()
+	bot, ok := lc.bots[tenantID]
+	lc.botMu.RUnlock()
+	if ok {
+		return bot, nil
+	}
+
+	config, err := lc.configService.Get(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch config: %v", err)
 	}
 
-	bot, err := linebot.New(config.ChannelSecret, config.ChannelAccessToken)
+	bot, err = linebot.New(config.ChannelSecret, config.ChannelAccessToken)
 	if err != nil {
 		return nil, fmt.Errorf("error creating LINE bot client: %v", err)
 	}
 
-	return &LineController{
-		configCollection:  configCollection,
-		messageCollection: messageCollection,
-		bot:               bot,
-	}, nil
+	lc.botMu.Lock()
+	lc.bots[tenantID] = bot
+	lc.botMu.Unlock()
+	return bot, nil
 }
 
-func (lc *LineController) logMessage(userID string, tier string, level string, missionID primitive.ObjectID, flexConfig models.BaseFlexMessageContent, placeholders map[string]string) error {
+func (lc *LineController) logMessage(ctx context.Context, userID string, tier string, level string, missionID primitive.ObjectID, flexConfig models.BaseFlexMessageContent, placeholders map[string]string) error {
 	messageLog := models.MessageLog{
+		TenantID:  tenant.Value(ctx),
 		UserID:    userID,
 		Status:    "unread",
 		Tier:      tier,
@@ -54,15 +99,68 @@ func (lc *LineController) logMessage(userID string, tier string, level string, m
 		},
 	}
 
-	_, err := lc.messageCollection.InsertOne(context.Background(), messageLog)
+	_, err := lc.messageCollection.InsertOne(ctx, messageLog)
 	return err
 }
 
-func (lc *LineController) sendFlexMessageAndLog(userID, tier, level string, missionID primitive.ObjectID, flexConfig models.BaseFlexMessageContent, placeholders map[string]string) error {
+// CountMessagesSince นับจำนวนข้อความที่ส่งถึง user ตั้งแต่เวลาที่กำหนด
+func (lc *LineController) CountMessagesSince(ctx context.Context, userID string, since time.Time) (int64, error) {
+	return lc.messageCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{
+		"user_id": userID,
+		"sent_at": bson.M{"$gte": since},
+	}))
+}
+
+// MulticastFlexMessage ส่ง Flex message เดียวกันถึงหลาย user ในครั้งเดียว (LINE จำกัด 500 คนต่อครั้ง)
+func (lc *LineController) MulticastFlexMessage(ctx context.Context, userIDs []string, flexConfig models.BaseFlexMessageContent) error {
+	bot, err := lc.client(ctx)
+	if err != nil {
+		return err
+	}
+	flexMessage := createFlexMessage(flexConfig, nil)
+	_, err = bot.Multicast(userIDs, flexMessage).Do()
+	return err
+}
+
+// GetQuotaUsage คืนจำนวนข้อความที่ใช้ไปในเดือนนี้และโควต้าทั้งหมด (limit = 0 คือไม่จำกัด)
+func (lc *LineController) GetQuotaUsage(ctx context.Context) (used int64, limit int64, err error) {
+	bot, err := lc.client(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	quota, err := bot.GetMessageQuota().Do()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get message quota: %v", err)
+	}
+	consumption, err := bot.GetMessageConsumption().Do()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get message consumption: %v", err)
+	}
+	if quota.Type != "limited" {
+		return consumption.TotalUsage, 0, nil
+	}
+	return consumption.TotalUsage, quota.Value, nil
+}
+
+func (lc *LineController) sendFlexMessageAndLog(ctx context.Context, userID, tier, level string, missionID primitive.ObjectID, flexConfig models.BaseFlexMessageContent, placeholders map[string]string) error {
+	bot, err := lc.client(ctx)
+	if err != nil {
+		return err
+	}
 	flexMessage := createFlexMessage(flexConfig, placeholders)
-	_, err := lc.bot.PushMessage(userID, flexMessage).Do()
+	_, err = bot.PushMessage(userID, flexMessage).Do()
+	if err != nil {
+		eventbus.Publish(ctx, eventbus.MessageFailed, "", map[string]interface{}{
+			"user_id":    userID,
+			"tier":       tier,
+			"level":      level,
+			"mission_id": missionID.Hex(),
+			"title":      flexConfig.Title,
+			"error":      err.Error(),
+		})
+	}
 
-	logErr := lc.logMessage(userID, tier, level, missionID, flexConfig, placeholders)
+	logErr := lc.logMessage(ctx, userID, tier, level, missionID, flexConfig, placeholders)
 
 	if err != nil && logErr == nil {
 		return fmt.Errorf("message not sent (possibly due to quota), but logged successfully: %v", err)
@@ -175,9 +273,8 @@ func formatNumberWithCommas(n int) string {
 	return string(out)
 }
 
-func (lc *LineController) SendFollowUpFlexMessage(userID, target, currentBet, tier, level string, missionID primitive.ObjectID) error {
-	var config models.Config
-	err := lc.configCollection.FindOne(context.Background(), bson.M{}).Decode(&config)
+func (lc *LineController) SendFollowUpFlexMessage(ctx context.Context, userID, target, currentBet, tier, level string, missionID primitive.ObjectID) error {
+	config, err := lc.configService.Effective(ctx, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to fetch config: %v", err)
 	}
@@ -187,22 +284,20 @@ func (lc *LineController) SendFollowUpFlexMessage(userID, target, currentBet, ti
 		"currentBet": currentBet,
 	}
 
-	return lc.sendFlexMessageAndLog(userID, tier, level, missionID, config.FlexMessages.Followup, placeholders)
+	return lc.sendFlexMessageAndLog(ctx, userID, tier, level, missionID, config.FlexMessages.Followup, placeholders)
 }
 
-func (lc *LineController) SendMissionSuccessFlexMessage(userID, tier, level string, missionID primitive.ObjectID) error {
-	var config models.Config
-	err := lc.configCollection.FindOne(context.Background(), bson.M{}).Decode(&config)
+func (lc *LineController) SendMissionSuccessFlexMessage(ctx context.Context, userID, tier, level string, missionID primitive.ObjectID) error {
+	config, err := lc.configService.Effective(ctx, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to fetch config: %v", err)
 	}
 
-	return lc.sendFlexMessageAndLog(userID, tier, level, missionID, config.FlexMessages.MissionSuccess, nil)
+	return lc.sendFlexMessageAndLog(ctx, userID, tier, level, missionID, config.FlexMessages.MissionSuccess, nil)
 }
 
-func (lc *LineController) SendMissionFailedFlexMessage(userID, target, tier, level string, missionID primitive.ObjectID) error {
-	var config models.Config
-	err := lc.configCollection.FindOne(context.Background(), bson.M{}).Decode(&config)
+func (lc *LineController) SendMissionFailedFlexMessage(ctx context.Context, userID, target, tier, level string, missionID primitive.ObjectID) error {
+	config, err := lc.configService.Effective(ctx, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to fetch config: %v", err)
 	}
@@ -211,12 +306,11 @@ func (lc *LineController) SendMissionFailedFlexMessage(userID, target, tier, lev
 		"target": target,
 	}
 
-	return lc.sendFlexMessageAndLog(userID, tier, level, missionID, config.FlexMessages.MissionFailed, placeholders)
+	return lc.sendFlexMessageAndLog(ctx, userID, tier, level, missionID, config.FlexMessages.MissionFailed, placeholders)
 }
 
-func (lc *LineController) SendMissionCompleteFlexMessage(userID, expireRewardDays, tier, level string, missionID primitive.ObjectID) error {
-	var config models.Config
-	err := lc.configCollection.FindOne(context.Background(), bson.M{}).Decode(&config)
+func (lc *LineController) SendMissionCompleteFlexMessage(ctx context.Context, userID, expireRewardDays, tier, level string, missionID primitive.ObjectID) error {
+	config, err := lc.configService.Effective(ctx, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to fetch config: %v", err)
 	}
@@ -225,22 +319,20 @@ func (lc *LineController) SendMissionCompleteFlexMessage(userID, expireRewardDay
 		"expireRewardDays": expireRewardDays,
 	}
 
-	return lc.sendFlexMessageAndLog(userID, tier, level, missionID, config.FlexMessages.MissionComplete, placeholders)
+	return lc.sendFlexMessageAndLog(ctx, userID, tier, level, missionID, config.FlexMessages.MissionComplete, placeholders)
 }
 
-func (lc *LineController) SendGetRewardFlexMessage(userID, tier, level string, missionID primitive.ObjectID) error {
-	var config models.Config
-	err := lc.configCollection.FindOne(context.Background(), bson.M{}).Decode(&config)
+func (lc *LineController) SendGetRewardFlexMessage(ctx context.Context, userID, tier, level string, missionID primitive.ObjectID) error {
+	config, err := lc.configService.Effective(ctx, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to fetch config: %v", err)
 	}
 
-	return lc.sendFlexMessageAndLog(userID, tier, level, missionID, config.FlexMessages.GetReward, nil)
+	return lc.sendFlexMessageAndLog(ctx, userID, tier, level, missionID, config.FlexMessages.GetReward, nil)
 }
 
-func (lc *LineController) SendRewardNotificationFlexMessage(userID, remainingDays, tier, level string, missionID primitive.ObjectID) error {
-	var config models.Config
-	err := lc.configCollection.FindOne(context.Background(), bson.M{}).Decode(&config)
+func (lc *LineController) SendRewardNotificationFlexMessage(ctx context.Context, userID, remainingDays, tier, level string, missionID primitive.ObjectID) error {
+	config, err := lc.configService.Effective(ctx, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to fetch config: %v", err)
 	}
@@ -249,5 +341,5 @@ func (lc *LineController) SendRewardNotificationFlexMessage(userID, remainingDay
 		"remainingDays": remainingDays,
 	}
 
-	return lc.sendFlexMessageAndLog(userID, tier, level, missionID, config.FlexMessages.RewardNotification, placeholders)
+	return lc.sendFlexMessageAndLog(ctx, userID, tier, level, missionID, config.FlexMessages.RewardNotification, placeholders)
 }
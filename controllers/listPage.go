@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"go-server/utils"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// estimatedTotalCap เพดานการนับของ ?total=estimated เมื่อมีเงื่อนไขกรอง (เกินนี้ตอบ total_estimated)
+const estimatedTotalCap = 10000
+
+// findListPage อ่านหน้าหนึ่งของรายการตาม filter (scope tenant แล้ว) และ sort ของ q
+//
+//	?cursor=<next_cursor>  อ่านต่อจากหน้าก่อนโดยไม่ใช้ skip (ไม่ส่งคือหน้าแรก)
+//	?page=N                โหมดเดิม ใช้ skip และนับจำนวนทั้งหมดทุกครั้ง
+//	?total=exact|estimated นับจำนวนทั้งหมดในโหมด cursor (ค่าเริ่มต้นไม่นับ)
+//
+// error ที่เป็น *utils.QueryError มาจาก query string ของ client (ดู listPageFailed)
+func findListPage[T any](c *fiber.Ctx, collection *mongo.Collection, filter bson.M, q utils.ListQuery, projection bson.M, defaultLimit, maxLimit int) ([]T, utils.PageInfo, error) {
+	ctx := c.UserContext()
+	limit := c.QueryInt("limit", defaultLimit)
+	if limit < 1 || limit > maxLimit {
+		limit = defaultLimit
+	}
+	info := utils.PageInfo{Limit: limit}
+	sortSpec := utils.CursorSort(q.Sort)
+
+	if c.Query("page") != "" {
+		page := c.QueryInt("page", 1)
+		if page < 1 {
+			page = 1
+		}
+		opts := options.Find().
+			SetSort(sortSpec).
+			SetSkip(int64((page - 1) * limit)).
+			SetLimit(int64(limit)).
+			SetProjection(projection)
+		items, _, err := findListItems[T](ctx, collection, filter, opts, limit)
+		if err != nil {
+			return nil, info, err
+		}
+		total, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, info, err
+		}
+		info.Page = page
+		info.Total = &total
+		info.TotalPages = (total + int64(limit) - 1) / int64(limit)
+		info.HasNext = int64(page) < info.TotalPages
+		info.HasPrev = page > 1
+		return items, info, nil
+	}
+
+	pageFilter := filter
+	if token := c.Query("cursor"); token != "" {
+		after, err := utils.CursorFilter(sortSpec, token)
+		if err != nil {
+			return nil, info, err
+		}
+		pageFilter = utils.AndFilter(filter, after)
+		info.HasPrev = true
+	}
+
+	// projection ที่เลือก field ต้องมี sort key ไว้สร้าง cursor (projectItems ตัดออกจาก response ภายหลัง)
+	if len(q.Fields) > 0 && projection != nil {
+		withKeys := bson.M{}
+		for key, value := range projection {
+			withKeys[key] = value
+		}
+		for _, e := range sortSpec {
+			if _, ok := withKeys[strings.Split(e.Key, ".")[0]]; !ok {
+				withKeys[e.Key] = 1
+			}
+		}
+		projection = withKeys
+	}
+
+	// ดึงเกินมา 1 รายการเพื่อตรวจสอบว่ายังมีหน้าถัดไปหรือไม่
+	opts := options.Find().
+		SetSort(sortSpec).
+		SetLimit(int64(limit + 1)).
+		SetProjection(projection)
+	items, last, err := findListItems[T](ctx, collection, pageFilter, opts, limit)
+	if err != nil {
+		return nil, info, err
+	}
+	if len(items) > limit {
+		items = items[:limit]
+		info.HasNext = true
+		if info.NextCursor, err = utils.EncodeCursor(sortSpec, last); err != nil {
+			return nil, info, err
+		}
+	}
+
+	switch c.Query("total") {
+	case "":
+	case "exact":
+		total, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, info, err
+		}
+		info.Total = &total
+	case "estimated":
+		total, estimated, err := estimateListTotal(ctx, collection, filter)
+		if err != nil {
+			return nil, info, err
+		}
+		info.Total, info.TotalEstimated = &total, estimated
+	default:
+		return nil, info, &utils.QueryError{Param: "total", Message: "expected exact or estimated"}
+	}
+	return items, info, nil
+}
+
+// findListItems อ่านเอกสารทั้งหมดของ opts และคืนเอกสารดิบลำดับที่ limit (ตัวสุดท้ายของหน้า) ไว้สร้าง cursor
+func findListItems[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, opts *options.FindOptions, limit int) ([]T, bson.Raw, error) {
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]T, 0, limit)
+	var last bson.Raw
+	for cursor.Next(ctx) {
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return nil, nil, err
+		}
+		items = append(items, item)
+		if len(items) == limit {
+			last = append(bson.Raw(nil), cursor.Current...)
+		}
+	}
+	return items, last, cursor.Err()
+}
+
+// estimateListTotal ใช้ metadata ของ collection เมื่อไม่มีเงื่อนไข ไม่เช่นนั้นนับไม่เกิน estimatedTotalCap
+func estimateListTotal(ctx context.Context, collection *mongo.Collection, filter bson.M) (int64, bool, error) {
+	if len(filter) == 0 {
+		total, err := collection.EstimatedDocumentCount(ctx)
+		return total, true, err
+	}
+	total, err := collection.CountDocuments(ctx, filter, options.Count().SetLimit(estimatedTotalCap))
+	return total, total >= estimatedTotalCap, err
+}
+
+// listPageFailed ตอบ 400 เมื่อ query string ผิด (เช่น cursor ไม่ถูกต้อง) ไม่เช่นนั้น 500 พร้อม message
+func listPageFailed(c *fiber.Ctx, err error, message string) error {
+	var queryErr *utils.QueryError
+	if errors.As(err, &queryErr) {
+		return listQueryFailed(c, err)
+	}
+	log.Printf("%s: %v", message, err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"encoding/json"
+	"go-server/utils"
+	"reflect"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// schema ของ query string ที่ endpoint รายการแต่ละ collection รับ (ดู utils.ParseListQuery)
+// field ที่ไม่อยู่ใน schema (เช่น password) กรอง เรียง หรือขอคืนไม่ได้
+
+var UserQuerySchema = utils.QuerySchema{
+	Fields: map[string]utils.QueryFieldType{
+		"_id":        utils.QueryObjectID,
+		"email":      utils.QueryString,
+		"role":       utils.QueryString,
+		"status":     utils.QueryString,
+		"createDate": utils.QueryDate,
+	},
+	TextFields:  []string{"email", "role", "status"},
+	DefaultSort: bson.D{{Key: "createDate", Value: -1}},
+}
+
+var MissionQuerySchema = utils.QuerySchema{
+	Fields: map[string]utils.QueryFieldType{
+		"_id":                 utils.QueryObjectID,
+		"user_id":             utils.QueryString,
+		"phone_number":        utils.QueryString,
+		"status":              utils.QueryString,
+		"current_tier":        utils.QueryNumber,
+		"consecutive_fails":   utils.QueryNumber,
+		"config_revision":     utils.QueryNumber,
+		"tiers":               utils.QueryString | utils.QueryUnsortable,
+		"tiers.status":        utils.QueryString | utils.QueryUnsortable,
+		"tiers.levels.status": utils.QueryString | utils.QueryUnsortable,
+		"created_at":          utils.QueryDate,
+		"updated_at":          utils.QueryDate,
+	},
+	TextFields:  []string{"user_id", "status", "phone_number"},
+	DefaultSort: bson.D{{Key: "created_at", Value: -1}},
+}
+
+var MessageQuerySchema = utils.QuerySchema{
+	Fields: map[string]utils.QueryFieldType{
+		"_id":                utils.QueryObjectID,
+		"user_id":            utils.QueryString,
+		"status":             utils.QueryString,
+		"tier":               utils.QueryString,
+		"level":              utils.QueryString,
+		"mission_id":         utils.QueryObjectID,
+		"broadcast_id":       utils.QueryObjectID,
+		"flex_content":       utils.QueryString | utils.QueryUnsortable,
+		"flex_content.title": utils.QueryString,
+		"sent_at":            utils.QueryDate,
+		"read_at":            utils.QueryDate,
+		"deleted_at":         utils.QueryDate,
+	},
+	TextFields:  []string{"user_id", "status", "flex_content.title"},
+	DefaultSort: bson.D{{Key: "status", Value: -1}, {Key: "sent_at", Value: -1}},
+}
+
+var ClientQuerySchema = utils.QuerySchema{
+	Fields: map[string]utils.QueryFieldType{
+		"_id":            utils.QueryObjectID,
+		"user_id":        utils.QueryString,
+		"display_name":   utils.QueryString,
+		"phone_number":   utils.QueryString,
+		"status_message": utils.QueryString,
+		"picture_url":    utils.QueryString,
+		"created_at":     utils.QueryDate,
+		"updated_at":     utils.QueryDate,
+	},
+	TextParam:   "search",
+	TextFields:  []string{"display_name", "user_id", "phone_number"},
+	DefaultSort: bson.D{{Key: "updated_at", Value: -1}},
+}
+
+// EventQuerySchema event ของ expiration worker ใน tbl_events
+var EventQuerySchema = utils.QuerySchema{
+	Fields: map[string]utils.QueryFieldType{
+		"_id":          utils.QueryObjectID,
+		"mission_id":   utils.QueryObjectID,
+		"tier_index":   utils.QueryNumber,
+		"level_index":  utils.QueryNumber,
+		"type":         utils.QueryString,
+		"status":       utils.QueryString,
+		"defer_count":  utils.QueryNumber,
+		"error":        utils.QueryString,
+		"expire_time":  utils.QueryDate,
+		"processed_at": utils.QueryDate,
+	},
+	TextFields:  []string{"type", "status", "error"},
+	DefaultSort: bson.D{{Key: "expire_time", Value: -1}},
+}
+
+// ClaimQuerySchema การขอรับรางวัลใน tbl_logs
+var ClaimQuerySchema = utils.QuerySchema{
+	Fields: map[string]utils.QueryFieldType{
+		"_id":            utils.QueryObjectID,
+		"user_id":        utils.QueryString,
+		"mission_id":     utils.QueryString,
+		"mission_detail": utils.QueryString,
+		"tier":           utils.QueryNumber,
+		"reward":         utils.QueryNumber,
+		"status":         utils.QueryString,
+		"created_at":     utils.QueryDate,
+		"callback_time":  utils.QueryDate,
+	},
+	TextFields:  []string{"user_id", "mission_detail"},
+	DefaultSort: bson.D{{Key: "created_at", Value: -1}},
+}
+
+// parseListQuery อ่าน query string ของ request ตาม schema (extra คือ parameter เฉพาะของ endpoint)
+func parseListQuery(c *fiber.Ctx, schema utils.QuerySchema, extra ...string) (utils.ListQuery, error) {
+	return utils.ParseListQuery(func(visit func(key, value string)) {
+		c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+			visit(string(key), string(value))
+		})
+	}, schema, extra...)
+}
+
+func listQueryFailed(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid query: " + err.Error()})
+}
+
+// projectItems ตัด response ให้เหลือเฉพาะ field ที่ขอผ่าน ?fields= (แปลงชื่อ bson เป็นชื่อ json ของ model)
+// คืน items เดิมเมื่อไม่ได้ขอ field
+func projectItems(items interface{}, model reflect.Type, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return items, nil
+	}
+	jsonNames := map[string]bool{}
+	for jsonName, field := range resourceFields(model) {
+		if field.bsonKey == "_id" {
+			jsonNames[jsonName] = true
+		}
+		for _, name := range fields {
+			if field.bsonKey == strings.Split(name, ".")[0] {
+				jsonNames[jsonName] = true
+			}
+		}
+	}
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var docs []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		for key := range doc {
+			if !jsonNames[key] {
+				delete(doc, key)
+			}
+		}
+	}
+	return docs, nil
+}
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MessageController struct {
+	messageCollection *mongo.Collection
+}
+
+func NewMessageController(messageCollection *mongo.Collection) *MessageController {
+	return &MessageController{
+		messageCollection: messageCollection,
+	}
+}
+
+// inboxFilter คืน filter ของข้อความใน inbox ของ user ใน tenant ของ ctx (ไม่รวมข้อความที่ลบแล้ว)
+func inboxFilter(ctx context.Context, userID string) bson.M {
+	return tenant.Scope(ctx, bson.M{
+		"user_id":    userID,
+		"deleted_at": bson.M{"$exists": false},
+	})
+}
+
+// GetMessages - รายการข้อความของ user เรียงจากใหม่ไปเก่า แบ่งหน้าด้วย cursor (ดู findListPage)
+func (mc *MessageController) GetMessages(c *fiber.Ctx) error {
+	userID := c.Params("userId")
+
+	filter := inboxFilter(c.UserContext(), userID)
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	q := utils.ListQuery{Sort: bson.D{{Key: "_id", Value: -1}}}
+	messages, page, err := findListPage[models.MessageLog](c, mc.messageCollection, filter, q, nil, 20, 100)
+	if err != nil {
+		log.Printf("GetMessages: Database error: %v", err)
+		return listPageFailed(c, err, "Failed to fetch messages")
+	}
+
+	return c.JSON(utils.ListPage{Items: messages, Pagination: page})
+}
+
+// GetUnreadCount - จำนวนข้อความที่ยังไม่ได้อ่าน
+func (mc *MessageController) GetUnreadCount(c *fiber.Ctx) error {
+	userID := c.Params("userId")
+
+	count, err := mc.CountUnread(c.UserContext(), userID)
+	if err != nil {
+		log.Printf("GetUnreadCount: Database error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count unread messages"})
+	}
+
+	return c.JSON(fiber.Map{"unread_count": count})
+}
+
+// CountUnread นับข้อความที่ยังไม่ได้อ่านของ user
+func (mc *MessageController) CountUnread(ctx context.Context, userID string) (int64, error) {
+	filter := inboxFilter(ctx, userID)
+	filter["status"] = "unread"
+	return mc.messageCollection.CountDocuments(ctx, filter)
+}
+
+// MarkAsRead - ทำเครื่องหมายว่าอ่านข้อความแล้ว
+func (mc *MessageController) MarkAsRead(c *fiber.Ctx) error {
+	userID := c.Params("userId")
+	messageID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid message ID"})
+	}
+
+	filter := inboxFilter(c.UserContext(), userID)
+	filter["_id"] = messageID
+
+	var message models.MessageLog
+	err = mc.messageCollection.FindOne(context.Background(), filter).Decode(&message)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Message not found"})
+		}
+		log.Printf("MarkAsRead: Database error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch message"})
+	}
+
+	// อ่านไปแล้วก็ไม่ต้องเปลี่ยน read_at
+	if message.Status == "read" {
+		return c.JSON(message)
+	}
+
+	now := time.Now()
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	update := bson.M{"$set": bson.M{"status": "read", "read_at": now}}
+
+	var updatedMessage models.MessageLog
+	err = mc.messageCollection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&updatedMessage)
+	if err != nil {
+		log.Printf("MarkAsRead: Error updating message: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update message"})
+	}
+
+	return c.JSON(updatedMessage)
+}
+
+// MarkAllAsRead - ทำเครื่องหมายว่าอ่านข้อความทั้งหมดแล้ว
+func (mc *MessageController) MarkAllAsRead(c *fiber.Ctx) error {
+	userID := c.Params("userId")
+
+	filter := inboxFilter(c.UserContext(), userID)
+	filter["status"] = "unread"
+	update := bson.M{"$set": bson.M{"status": "read", "read_at": time.Now()}}
+
+	result, err := mc.messageCollection.UpdateMany(context.Background(), filter, update)
+	if err != nil {
+		log.Printf("MarkAllAsRead: Database error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update messages"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":       "Messages marked as read",
+		"updated_count": result.ModifiedCount,
+	})
+}
+
+// DeleteMessage - ลบข้อความออกจาก inbox
+// ใช้ soft delete เพราะ log ยังถูกใช้นับจำนวนข้อความต่อวันและรายงาน
+func (mc *MessageController) DeleteMessage(c *fiber.Ctx) error {
+	userID := c.Params("userId")
+	messageID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid message ID"})
+	}
+
+	filter := inboxFilter(c.UserContext(), userID)
+	filter["_id"] = messageID
+
+	result, err := mc.messageCollection.UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{"deleted_at": time.Now()}})
+	if err != nil {
+		log.Printf("DeleteMessage: Database error: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete message"})
+	}
+
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Message not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Message deleted successfully"})
+}
@@ -5,11 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"go-server/config"
+	"go-server/eventbus"
 	"go-server/models"
+	"go-server/tenant"
 	"go-server/utils"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"time"
@@ -23,20 +27,20 @@ import (
 
 type MissionController struct {
 	missionCollection  *mongo.Collection
-	configCollection   *mongo.Collection
+	configService      *config.Service
 	eventCollection    *mongo.Collection
 	logCollection      *mongo.Collection // เพิ่ม logCollection
 	telegramController *TelegramController
 	lineController     *LineController
 }
 
-func NewMissionController(missionCollection, configCollection, eventCollection, logCollection *mongo.Collection, lineController *LineController) *MissionController {
+func NewMissionController(missionCollection *mongo.Collection, configService *config.Service, eventCollection, logCollection *mongo.Collection, lineController *LineController) *MissionController {
 	return &MissionController{
 		missionCollection:  missionCollection,
-		configCollection:   configCollection,
+		configService:      configService,
 		eventCollection:    eventCollection,
 		logCollection:      logCollection,
-		telegramController: NewTelegramController(configCollection),
+		telegramController: NewTelegramController(configService),
 		lineController:     lineController,
 	}
 }
@@ -49,8 +53,8 @@ func (c *MissionController) GetProcessingMission(ctx *fiber.Ctx) error {
 
 	var mission models.Mission
 	cursor, err := c.missionCollection.Find(
-		ctx.Context(),
-		bson.M{"user_id": userID},
+		ctx.UserContext(),
+		tenant.Scope(ctx.UserContext(), bson.M{"user_id": userID}),
 		options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(1),
 	)
 
@@ -58,9 +62,9 @@ func (c *MissionController) GetProcessingMission(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch mission"})
 	}
 
-	defer cursor.Close(ctx.Context())
+	defer cursor.Close(ctx.UserContext())
 
-	if cursor.Next(ctx.Context()) {
+	if cursor.Next(ctx.UserContext()) {
 		if err := cursor.Decode(&mission); err != nil {
 			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode mission"})
 		}
@@ -88,13 +92,21 @@ func (c *MissionController) CreateMission(ctx *fiber.Ctx) error {
 	}
 
 	var config models.Config
-	err := c.configCollection.FindOne(ctx.Context(), bson.M{}).Decode(&config)
+	err := c.configService.Load(ctx.UserContext(), &config)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
 	}
 
+	// snapshot เก็บกติกาปกติ ส่วน tier แรกเริ่มด้วยกติกาที่รวม overlay ที่มีผลอยู่
+	overlay, err := c.configService.ActiveOverlay(ctx.UserContext(), time.Now())
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config overlay"})
+	}
+	effectiveTiers := utils.ApplyTierOverlay(config.Tiers, overlay)
+
 	// สร้าง mission object จาก request body
 	mission := &models.Mission{
+		TenantID:         tenant.Value(ctx.UserContext()),
 		UserID:           requestBody.UserID,
 		PhoneNumber:      requestBody.PhoneNumber,
 		CreatedAt:        time.Now(),
@@ -102,10 +114,12 @@ func (c *MissionController) CreateMission(ctx *fiber.Ctx) error {
 		Status:           "processing",
 		CurrentTier:      1,
 		ConsecutiveFails: 0,
+		ConfigRevision:   config.Revision,
+		TierRules:        config.Tiers,
 	}
 
-	if len(config.Tiers) > 0 {
-		firstTierConfig := config.Tiers[0]
+	if len(effectiveTiers) > 0 {
+		firstTierConfig := effectiveTiers[0]
 		mission.Tiers = []models.Tier{
 			{
 				Name:         firstTierConfig.Name,
@@ -123,14 +137,15 @@ func (c *MissionController) CreateMission(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "No tier configuration found"})
 	}
 
-	result, err := c.missionCollection.InsertOne(ctx.Context(), mission)
+	result, err := c.missionCollection.InsertOne(ctx.UserContext(), mission)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create mission"})
 	}
 
 	mission.ID = result.InsertedID.(primitive.ObjectID)
 
-	c.createNewEvents(ctx.Context(), mission, &mission.Tiers[0], &mission.Tiers[0].Levels[0], config.Tiers[0])
+	c.createNewEvents(ctx.UserContext(), mission, &mission.Tiers[0], &mission.Tiers[0].Levels[0], effectiveTiers[0])
+	eventbus.Default.MissionCreated(ctx.UserContext(), *mission)
 
 	return ctx.Status(fiber.StatusCreated).JSON(mission)
 }
@@ -149,20 +164,29 @@ func (c *MissionController) UpdateMissionStatus(ctx *fiber.Ctx) error {
 	}
 
 	var mission models.Mission
-	err = c.missionCollection.FindOne(ctx.Context(), bson.M{"_id": missionID}).Decode(&mission)
+	err = c.missionCollection.FindOne(ctx.UserContext(), tenant.Scope(ctx.UserContext(), bson.M{"_id": missionID})).Decode(&mission)
 	if err != nil {
 		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Mission not found"})
 	}
 
-	var config models.Config
-	err = c.configCollection.FindOne(ctx.Context(), bson.M{}).Decode(&config)
+	config, err := c.configService.Effective(ctx.UserContext(), time.Now())
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
 	}
 
+	if updateData.TierIndex < 0 || updateData.TierIndex >= len(mission.Tiers) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid tier index"})
+	}
+	tierRules := missionTierRules(&mission, config)
+	currentTierConfig, err := missionTierRule(&mission, config, updateData.TierIndex)
+	if err != nil {
+		log.Printf("Failed to get tier config: %v", err)
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "Mission has no rule for this tier"})
+	}
+
 	currentTier := &mission.Tiers[updateData.TierIndex]
-	currentLevel := &currentTier.Levels[currentTier.CurrentLevel-1]
-	currentTierConfig := config.Tiers[updateData.TierIndex]
+	levelNumber := currentTier.CurrentLevel
+	currentLevel := &currentTier.Levels[levelNumber-1]
 
 	// Get current bet for the mission
 	currentBet, err := utils.GetCurrentBet(config, mission.UserID, currentLevel.StartDate, currentLevel.ExpireDate)
@@ -175,6 +199,9 @@ func (c *MissionController) UpdateMissionStatus(ctx *fiber.Ctx) error {
 	currentLevel.CurrentBet = currentBet
 
 	if currentBet >= float64(currentTierConfig.Target) {
+		// รางวัลยึดตามกติกา ณ เวลาที่ทำเป้าสำเร็จ (เช่น ช่วงโปรรางวัลสองเท่า)
+		currentTier.Reward = currentTierConfig.Reward
+
 		if updateData.TierIndex == 2 {
 			// Tier 3 handling
 			log.Printf("Tier 3 level %d completed! Reward: %d", currentTier.CurrentLevel, currentTier.Reward)
@@ -184,7 +211,7 @@ func (c *MissionController) UpdateMissionStatus(ctx *fiber.Ctx) error {
 			newLevel := createNewLevel(currentTier.CurrentLevel, currentTierConfig.Period, currentTierConfig.FollowUpHours)
 			currentTier.Levels = append(currentTier.Levels, newLevel)
 
-			c.createNewEvents(ctx.Context(), &mission, currentTier, &newLevel, currentTierConfig)
+			c.createNewEvents(ctx.UserContext(), &mission, currentTier, &newLevel, currentTierConfig)
 		} else {
 			// Tier 1 and 2 handling
 			currentLevel.Status = "success"
@@ -194,21 +221,21 @@ func (c *MissionController) UpdateMissionStatus(ctx *fiber.Ctx) error {
 				newLevel := createNewLevel(currentTier.CurrentLevel, currentTierConfig.Period, currentTierConfig.FollowUpHours)
 				currentTier.Levels = append(currentTier.Levels, newLevel)
 
-				c.createNewEvents(ctx.Context(), &mission, currentTier, &newLevel, currentTierConfig)
+				c.createNewEvents(ctx.UserContext(), &mission, currentTier, &newLevel, currentTierConfig)
 			} else {
 				// Last level of current tier
 				currentTier.Status = "completed"
 				log.Printf("Tier %d completed! Reward: %d", updateData.TierIndex+1, currentTier.Reward)
 
-				if updateData.TierIndex < len(config.Tiers)-1 {
+				if updateData.TierIndex < len(tierRules)-1 {
 					// Move to next tier
 					mission.CurrentTier++
-					newTierConfig := config.Tiers[mission.CurrentTier-1]
+					newTierConfig := tierRules[mission.CurrentTier-1]
 					newTier := createNewTier(newTierConfig)
 					mission.Tiers = append(mission.Tiers, newTier)
 					log.Printf("Creating new Tier %d, starting at level 1", mission.CurrentTier)
 
-					c.createNewEvents(ctx.Context(), &mission, &newTier, &newTier.Levels[0], newTierConfig)
+					c.createNewEvents(ctx.UserContext(), &mission, &newTier, &newTier.Levels[0], newTierConfig)
 				} else {
 					mission.Status = "completed"
 					log.Println("Mission completed")
@@ -228,13 +255,14 @@ func (c *MissionController) UpdateMissionStatus(ctx *fiber.Ctx) error {
 	mission.UpdatedAt = time.Now()
 
 	_, err = c.missionCollection.UpdateOne(
-		ctx.Context(),
+		ctx.UserContext(),
 		bson.M{"_id": missionID},
 		bson.M{"$set": mission},
 	)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update mission"})
 	}
+	eventbus.Default.LevelOutcome(ctx.UserContext(), mission, updateData.TierIndex+1, levelNumber, mission.Tiers[updateData.TierIndex].Levels[levelNumber-1], currentTierConfig.Target)
 
 	return ctx.JSON(mission)
 }
@@ -246,7 +274,7 @@ func (c *MissionController) ClaimReward(ctx *fiber.Ctx) error {
 	}
 
 	var mission models.Mission
-	err = c.missionCollection.FindOne(ctx.Context(), bson.M{"_id": missionID}).Decode(&mission)
+	err = c.missionCollection.FindOne(ctx.UserContext(), tenant.Scope(ctx.UserContext(), bson.M{"_id": missionID})).Decode(&mission)
 	if err != nil {
 		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Mission not found"})
 	}
@@ -263,7 +291,7 @@ func (c *MissionController) ClaimReward(ctx *fiber.Ctx) error {
 	}
 
 	// Clear all reward-related events
-	err = c.clearRewardRelatedEvents(ctx.Context(), missionID)
+	err = c.clearRewardRelatedEvents(ctx.UserContext(), missionID)
 	if err != nil {
 		log.Printf("Failed to clear reward related events: %v", err)
 		// Continue processing even if clearing events fails
@@ -271,11 +299,12 @@ func (c *MissionController) ClaimReward(ctx *fiber.Ctx) error {
 
 	// Set mission status to pending
 	_, err = c.missionCollection.UpdateOne(
-		ctx.Context(),
+		ctx.UserContext(),
 		bson.M{"_id": missionID},
 		bson.M{"$set": bson.M{
 			"status": "pending",
 			fmt.Sprintf("tiers.%d.status", mission.CurrentTier-1): "pending",
+			"updated_at": time.Now(),
 		}},
 	)
 	if err != nil {
@@ -283,20 +312,14 @@ func (c *MissionController) ClaimReward(ctx *fiber.Ctx) error {
 	}
 
 	var config models.Config
-	err = c.configCollection.FindOne(ctx.Context(), bson.M{}).Decode(&config)
+	err = c.configService.Load(ctx.UserContext(), &config)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
 	}
 
-	// Send Telegram message for claiming reward
-	err = c.telegramController.SendRewardClaimedMessage(mission.ID.Hex(), mission.UserID, mission.CurrentTier, currentTier.CurrentLevel, currentTier.Reward)
-	if err != nil {
-		log.Printf("Failed to send Telegram message: %v", err)
-		// Continue with the process even if sending the message fails
-	}
-
 	// Send get reward notification
 	err = c.lineController.SendGetRewardFlexMessage(
+		ctx.UserContext(),
 		mission.UserID,
 		strconv.Itoa(mission.CurrentTier),
 		strconv.Itoa(currentTier.CurrentLevel),
@@ -337,7 +360,17 @@ func (c *MissionController) ClaimReward(ctx *fiber.Ctx) error {
 	rewardFloat := float64(currentTier.Reward)
 
 	// Send reward claim to external API
-	err = c.sendRewardClaimToExternalAPI(mission.UserID, rewardFloat, missionID.Hex(), missionDetailStr, &config)
+	logID, err := c.sendRewardClaimToExternalAPI(ctx.UserContext(), mission.UserID, rewardFloat, missionID.Hex(), mission.CurrentTier, missionDetailStr, &config)
+
+	// Send Telegram message for claiming reward (แนบปุ่มอนุมัติ/ปฏิเสธเมื่อสร้าง log สำเร็จ)
+	if !logID.IsZero() {
+		telegramErr := c.telegramController.SendRewardClaimedMessage(ctx.UserContext(), mission.ID.Hex(), mission.UserID, mission.CurrentTier, currentTier.CurrentLevel, currentTier.Reward, logID.Hex())
+		if telegramErr != nil {
+			log.Printf("Failed to send Telegram message: %v", telegramErr)
+			// Continue with the process even if sending the message fails
+		}
+	}
+
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to send reward claim"})
 	}
@@ -364,46 +397,56 @@ func (c *MissionController) clearRewardRelatedEvents(ctx context.Context, missio
 }
 
 // แก้ไขฟังก์ชัน sendRewardClaimToExternalAPI
-func (c *MissionController) sendRewardClaimToExternalAPI(userID string, reward float64, missionID string, missionDetail string, config *models.Config) error {
+func (c *MissionController) sendRewardClaimToExternalAPI(ctx context.Context, userID string, reward float64, missionID string, tier int, missionDetail string, config *models.Config) (primitive.ObjectID, error) {
 	logEntry := models.Log{
+		TenantID:      tenant.Value(ctx),
 		UserID:        userID,
 		MissionID:     missionID,
 		MissionDetail: missionDetail,
+		Tier:          tier,
 		Reward:        reward,
 		CreatedAt:     time.Now(),
 		Status:        "pending",
 	}
 
-	result, err := c.logCollection.InsertOne(context.Background(), logEntry)
+	result, err := c.logCollection.InsertOne(ctx, logEntry)
 	if err != nil {
 		log.Printf("Failed to create log entry: %v", err)
-		return err
+		return primitive.NilObjectID, err
 	}
 
 	logID := result.InsertedID.(primitive.ObjectID)
+	logEntry.ID = logID
+	eventbus.Default.ClaimRequested(ctx, logEntry)
 
 	log.Printf("Sending reward claim: UserID: %s, Reward: %.2f, MissionID: %s, LogID: %s", userID, reward, missionID, logID.Hex())
 
+	// callback ต้องกลับมาที่ tenant เดียวกับที่ส่งคำขอ
+	callbackURL := fmt.Sprintf("%s/api/missions/reward-callback", os.Getenv("BASE_URL"))
+	if tenantID := tenant.Value(ctx); tenantID != "" {
+		callbackURL += "?tenant_id=" + url.QueryEscape(tenantID)
+	}
+
 	externalAPIPayload := map[string]interface{}{
 		"log_id":         logID.Hex(),
 		"user_id":        userID,
 		"mission_detail": missionDetail,
 		"reward":         reward,
-		"callback_url":   fmt.Sprintf("%s/api/missions/reward-callback", os.Getenv("BASE_URL")),
+		"callback_url":   callbackURL,
 		"line_at":        config.LineAt,
 	}
 
 	jsonData, err := json.Marshal(externalAPIPayload)
 	if err != nil {
 		log.Printf("Failed to marshal payload: %v", err)
-		return err
+		return logID, err
 	}
 
 	client := &http.Client{}
 	req, err := http.NewRequest("POST", config.ApiEndpoint+"/players/v1/line/rewards/claim", bytes.NewBuffer(jsonData))
 	if err != nil {
 		log.Printf("Failed to create request: %v", err)
-		return err
+		return logID, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -415,23 +458,23 @@ func (c *MissionController) sendRewardClaimToExternalAPI(userID string, reward f
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Printf("Failed to send request to external API: %v", err)
-		return err
+		return logID, err
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		log.Printf("Failed to read response body: %v", err)
-		return err
+		return logID, err
 	}
 
 	log.Printf("Response from external API - Status: %d, Body: %s", resp.StatusCode, string(body))
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("external API returned non-OK status: %d", resp.StatusCode)
+		return logID, fmt.Errorf("external API returned non-OK status: %d", resp.StatusCode)
 	}
 
-	return nil
+	return logID, nil
 }
 
 func (c *MissionController) createNewEvents(ctx context.Context, mission *models.Mission, currentTier *models.Tier, newLevel *models.Level, currentTierConfig models.TierDetail) {
@@ -439,6 +482,7 @@ func (c *MissionController) createNewEvents(ctx context.Context, mission *models
 	log.Printf("Creating new events with Processing Delay: %v", processingDelay)
 
 	levelExpirationEvent := models.ExpirationEvent{
+		TenantID:   mission.TenantID,
 		MissionID:  mission.ID,
 		TierIndex:  mission.CurrentTier - 1,
 		LevelIndex: currentTier.CurrentLevel - 1,
@@ -453,6 +497,7 @@ func (c *MissionController) createNewEvents(ctx context.Context, mission *models
 	log.Printf("Level expiration event created with ExpireTime: %v", levelExpirationEvent.ExpireTime)
 
 	followUpEvent := models.ExpirationEvent{
+		TenantID:   mission.TenantID,
 		MissionID:  mission.ID,
 		TierIndex:  mission.CurrentTier - 1,
 		LevelIndex: currentTier.CurrentLevel - 1,
@@ -468,6 +513,7 @@ func (c *MissionController) createNewEvents(ctx context.Context, mission *models
 	// Create reward_expiration event only when the tier is completed for Tier 1 and 2
 	if mission.CurrentTier < 3 && currentTier.Status == "completed" {
 		rewardExpirationEvent := models.ExpirationEvent{
+			TenantID:   mission.TenantID,
 			MissionID:  mission.ID,
 			TierIndex:  mission.CurrentTier - 1,
 			LevelIndex: currentTier.CurrentLevel - 1,
@@ -489,10 +535,10 @@ func (c *MissionController) CheckExistingMission(ctx *fiber.Ctx) error {
 	}
 
 	// ค้นหา mission ที่มีสถานะ processing หรือ pending
-	count, err := c.missionCollection.CountDocuments(ctx.Context(), bson.M{
+	count, err := c.missionCollection.CountDocuments(ctx.UserContext(), tenant.Scope(ctx.UserContext(), bson.M{
 		"user_id": userID,
 		"status":  bson.M{"$in": []string{"processing", "pending"}},
-	})
+	}))
 
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check mission"})
@@ -502,3 +548,191 @@ func (c *MissionController) CheckExistingMission(ctx *fiber.Ctx) error {
 		"hasMission": count > 0,
 	})
 }
+
+// MigrateTierRules ย้ายมิชชันที่กำลังทำอยู่ไปใช้กติกา tier ของ config ปัจจุบัน
+// ระบุ mission_ids เพื่อย้ายเฉพาะบางมิชชัน และใช้ dry_run เพื่อดูผลก่อนบันทึกจริง
+func (c *MissionController) MigrateTierRules(ctx *fiber.Ctx) error {
+	var requestBody struct {
+		MissionIDs []string `json:"mission_ids"`
+		DryRun     bool     `json:"dry_run"`
+	}
+	if err := ctx.BodyParser(&requestBody); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	var config models.Config
+	err := c.configService.Load(ctx.UserContext(), &config)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
+	}
+	if len(config.Tiers) == 0 {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "No tier configuration found"})
+	}
+
+	filter := bson.M{"status": bson.M{"$in": []string{"processing", "pending"}}}
+	if len(requestBody.MissionIDs) > 0 {
+		ids := make([]primitive.ObjectID, 0, len(requestBody.MissionIDs))
+		for _, hex := range requestBody.MissionIDs {
+			id, err := primitive.ObjectIDFromHex(hex)
+			if err != nil {
+				return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid mission ID: %s", hex)})
+			}
+			ids = append(ids, id)
+		}
+		filter["_id"] = bson.M{"$in": ids}
+	}
+
+	cursor, err := c.missionCollection.Find(ctx.UserContext(), tenant.Scope(ctx.UserContext(), filter))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch missions"})
+	}
+	defer cursor.Close(ctx.UserContext())
+
+	type skippedMission struct {
+		MissionID string `json:"mission_id"`
+		Reason    string `json:"reason"`
+	}
+	migrated := make([]string, 0)
+	skipped := make([]skippedMission, 0)
+	rescheduled := 0
+
+	for cursor.Next(ctx.UserContext()) {
+		var mission models.Mission
+		if err := cursor.Decode(&mission); err != nil {
+			log.Printf("Failed to decode mission: %v", err)
+			continue
+		}
+
+		if len(mission.Tiers) > len(config.Tiers) {
+			skipped = append(skipped, skippedMission{
+				MissionID: mission.ID.Hex(),
+				Reason:    fmt.Sprintf("mission is on tier %d but config has only %d tiers", len(mission.Tiers), len(config.Tiers)),
+			})
+			continue
+		}
+		// tier ที่ยังไม่ผ่านและอยู่ level เกิน max_level ใหม่แล้ว จะไม่มีวันครบ CurrentLevel == MaxLevel
+		if reason := maxLevelConflict(mission, config.Tiers); reason != "" {
+			skipped = append(skipped, skippedMission{MissionID: mission.ID.Hex(), Reason: reason})
+			continue
+		}
+
+		update := bson.M{
+			"tier_rules":      config.Tiers,
+			"config_revision": config.Revision,
+			"updated_at":      time.Now(),
+		}
+		for i := range mission.Tiers {
+			update[fmt.Sprintf("tiers.%d.name", i)] = config.Tiers[i].Name
+			update[fmt.Sprintf("tiers.%d.reward", i)] = config.Tiers[i].Reward
+			update[fmt.Sprintf("tiers.%d.target", i)] = config.Tiers[i].Target
+			update[fmt.Sprintf("tiers.%d.max_level", i)] = config.Tiers[i].MaxLevel
+		}
+
+		// event ที่ยัง pending คำนวณเวลาจาก Period เดิม ต้องเลื่อนตามกติกาใหม่ด้วย
+		var events []models.ExpirationEvent
+		eventCursor, err := c.eventCollection.Find(ctx.UserContext(), bson.M{"mission_id": mission.ID, "status": "pending"})
+		if err == nil {
+			err = eventCursor.All(ctx.UserContext(), &events)
+		}
+		if err != nil {
+			log.Printf("Failed to fetch pending events for mission %s: %v", mission.ID.Hex(), err)
+			skipped = append(skipped, skippedMission{MissionID: mission.ID.Hex(), Reason: "failed to fetch pending events"})
+			continue
+		}
+		eventTimes := migratedEventTimes(mission, events, config.Tiers, update)
+
+		if !requestBody.DryRun {
+			_, err := c.missionCollection.UpdateOne(ctx.UserContext(), bson.M{"_id": mission.ID}, bson.M{"$set": update})
+			if err != nil {
+				log.Printf("Failed to migrate tier rules for mission %s: %v", mission.ID.Hex(), err)
+				skipped = append(skipped, skippedMission{MissionID: mission.ID.Hex(), Reason: "failed to update mission"})
+				continue
+			}
+			for eventID, expireTime := range eventTimes {
+				_, err := c.eventCollection.UpdateOne(ctx.UserContext(),
+					bson.M{"_id": eventID, "status": "pending"},
+					bson.M{"$set": bson.M{"expire_time": expireTime}},
+				)
+				if err != nil {
+					log.Printf("Failed to reschedule event %s for mission %s: %v", eventID.Hex(), mission.ID.Hex(), err)
+				}
+			}
+		}
+		migrated = append(migrated, mission.ID.Hex())
+		rescheduled += len(eventTimes)
+	}
+
+	log.Printf("Tier rules migration to config revision %d by %s: migrated=%d skipped=%d rescheduled_events=%d dry_run=%v",
+		config.Revision, utils.RequestActor(ctx), len(migrated), len(skipped), rescheduled, requestBody.DryRun)
+
+	return ctx.JSON(fiber.Map{
+		"dry_run":            requestBody.DryRun,
+		"config_revision":    config.Revision,
+		"migrated":           migrated,
+		"skipped":            skipped,
+		"rescheduled_events": rescheduled,
+	})
+}
+
+// maxLevelConflict คืนเหตุผลเมื่อ tier ปัจจุบันหรือ tier ถัดไปของมิชชันอยู่ level ที่เกิน max_level ของ rules (ว่าง = ย้ายได้)
+func maxLevelConflict(mission models.Mission, rules []models.TierDetail) string {
+	for i := mission.CurrentTier - 1; i >= 0 && i < len(mission.Tiers) && i < len(rules); i++ {
+		if mission.Tiers[i].CurrentLevel > rules[i].MaxLevel {
+			return fmt.Sprintf("tier %d current level %d exceeds new max_level %d", i+1, mission.Tiers[i].CurrentLevel, rules[i].MaxLevel)
+		}
+	}
+	return ""
+}
+
+// migratedEventTimes คำนวณ expire_time ใหม่ของ event ที่ยัง pending ตามกติกา rules
+// และเพิ่มวันที่ของ level/tier ที่เปลี่ยนตามลงใน update ของมิชชัน คืนเฉพาะ event ที่เวลาเปลี่ยน
+//
+// level_expiration และ follow_up นับจากวันเริ่ม level ส่วน reward_expiration และ reward_notification
+// เลื่อนตามส่วนต่างของ ExpireRewardHours จากกติกาเดิมในมิชชัน (มิชชันเก่าที่ไม่มี tier_rules ไม่รู้เวลาที่ผ่าน tier จึงไม่เลื่อน)
+func migratedEventTimes(mission models.Mission, events []models.ExpirationEvent, rules []models.TierDetail, update bson.M) map[primitive.ObjectID]time.Time {
+	times := make(map[primitive.ObjectID]time.Time)
+	for _, event := range events {
+		if event.TierIndex < 0 || event.TierIndex >= len(mission.Tiers) || event.TierIndex >= len(rules) {
+			continue
+		}
+		tier := mission.Tiers[event.TierIndex]
+		rule := rules[event.TierIndex]
+
+		var expireTime time.Time
+		switch event.Type {
+		case "level_expiration", "follow_up":
+			if event.LevelIndex < 0 || event.LevelIndex >= len(tier.Levels) {
+				continue
+			}
+			level := tier.Levels[event.LevelIndex]
+			expireDate := level.StartDate.Add(time.Duration(rule.Period) * time.Hour)
+			followUpDate := level.StartDate.Add(time.Duration(rule.FollowUpHours) * time.Hour)
+			update[fmt.Sprintf("tiers.%d.levels.%d.expire_date", event.TierIndex, event.LevelIndex)] = expireDate
+			update[fmt.Sprintf("tiers.%d.levels.%d.follow_up_date", event.TierIndex, event.LevelIndex)] = followUpDate
+			if event.Type == "level_expiration" {
+				expireTime = expireDate.Add(time.Duration(rule.ProcessingDelay) * time.Minute)
+			} else {
+				expireTime = followUpDate
+			}
+		case "reward_expiration", "reward_notification":
+			if event.TierIndex >= len(mission.TierRules) || tier.ExpireReward.IsZero() {
+				continue
+			}
+			previous := mission.TierRules[event.TierIndex]
+			expireReward := tier.ExpireReward.Add(time.Duration(rule.ExpireRewardHours-previous.ExpireRewardHours) * time.Hour)
+			update[fmt.Sprintf("tiers.%d.expire_reward", event.TierIndex)] = expireReward
+			if event.Type == "reward_expiration" {
+				expireTime = expireReward
+			} else {
+				expireTime = expireReward.Add(-time.Duration(rule.NotifyBeforeExpire) * time.Hour)
+			}
+		default:
+			continue
+		}
+
+		if !expireTime.Equal(event.ExpireTime) {
+			times[event.ID] = expireTime
+		}
+	}
+	return times
+}
@@ -2,8 +2,12 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"go-server/config"
+	"go-server/eventbus"
 	"go-server/models"
+	"go-server/tenant"
 	"go-server/utils"
 	"log"
 	"time"
@@ -17,21 +21,28 @@ import (
 type RewardCallbackController struct {
 	missionCollection *mongo.Collection
 	logCollection     *mongo.Collection
-	configCollection  *mongo.Collection
+	configService     *config.Service
 	eventCollection   *mongo.Collection
 	lineController    *LineController
 }
 
-func NewRewardCallbackController(missionCollection, logCollection, configCollection, eventCollection *mongo.Collection, lineController *LineController) *RewardCallbackController {
+func NewRewardCallbackController(missionCollection, logCollection *mongo.Collection, configService *config.Service, eventCollection *mongo.Collection, lineController *LineController) *RewardCallbackController {
 	return &RewardCallbackController{
 		missionCollection: missionCollection,
 		logCollection:     logCollection,
-		configCollection:  configCollection,
+		configService:     configService,
 		eventCollection:   eventCollection,
 		lineController:    lineController,
 	}
 }
 
+var (
+	errRewardLogNotFound    = errors.New("log entry not found")
+	errNoPendingRewardClaim = errors.New("no matching pending reward claim found")
+	errInvalidRewardStatus  = errors.New("invalid status. Must be 'approve' or 'reject'")
+	errRewardMissionMissing = errors.New("mission not found")
+)
+
 func (c *RewardCallbackController) HandleRewardCallback(ctx *fiber.Ctx) error {
 	var callback struct {
 		LogID  string `json:"log_id"`
@@ -46,14 +57,55 @@ func (c *RewardCallbackController) HandleRewardCallback(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid log ID"})
 	}
 
-	// Fetch the log entry to get the mission_id
+	_, callbackTime, err := c.ProcessRewardDecision(ctx.UserContext(), logID, callback.Status)
+	if err != nil {
+		switch err {
+		case errInvalidRewardStatus:
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status. Must be 'approve' or 'reject'"})
+		case errRewardLogNotFound:
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Log entry not found"})
+		case errNoPendingRewardClaim:
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No matching pending reward claim found"})
+		case errRewardMissionMissing:
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Mission not found"})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Prepare the new response
+	var message string
+	if callback.Status == "approve" {
+		message = "Reward approved successfully"
+	} else {
+		message = "Reward rejected"
+	}
+
+	response := fiber.Map{
+		"log_id":        logID.Hex(),
+		"callback_time": callbackTime,
+		"status":        callback.Status,
+		"message":       message,
+	}
+
+	// Return both the original success message and the new response
+	return ctx.JSON(response)
+}
+
+// ProcessRewardDecision อนุมัติหรือปฏิเสธการขอรับรางวัลจาก log ที่ยัง pending
+// ใช้ร่วมกันระหว่าง callback จากระบบภายนอกและปุ่มใน Telegram
+func (c *RewardCallbackController) ProcessRewardDecision(ctx context.Context, logID primitive.ObjectID, status string) (*models.Mission, time.Time, error) {
+	if status != "approve" && status != "reject" {
+		return nil, time.Time{}, errInvalidRewardStatus
+	}
+
+	// Fetch the log entry to get the mission_id (เฉพาะ log ของ tenant ใน ctx)
 	var logEntry models.Log
-	err = c.logCollection.FindOne(context.Background(), bson.M{"_id": logID}).Decode(&logEntry)
+	err := c.logCollection.FindOne(ctx, tenant.Scope(ctx, bson.M{"_id": logID})).Decode(&logEntry)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Log entry not found"})
+			return nil, time.Time{}, errRewardLogNotFound
 		}
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch log entry"})
+		return nil, time.Time{}, fmt.Errorf("failed to fetch log entry: %v", err)
 	}
 
 	callbackTime := time.Now()
@@ -61,77 +113,60 @@ func (c *RewardCallbackController) HandleRewardCallback(ctx *fiber.Ctx) error {
 	update := bson.M{
 		"$set": bson.M{
 			"callback_time": callbackTime,
-			"status":        callback.Status,
+			"status":        status,
 		},
 	}
 
 	result, err := c.logCollection.UpdateOne(
-		context.Background(),
+		ctx,
 		bson.M{"_id": logID, "status": "pending"},
 		update,
 	)
 
 	if err != nil {
 		log.Printf("Failed to update log: %v", err)
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process callback"})
+		return nil, time.Time{}, fmt.Errorf("failed to process callback")
 	}
 
 	if result.MatchedCount == 0 {
-		log.Printf("No pending log found for LogID: %s", callback.LogID)
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No matching pending reward claim found"})
+		log.Printf("No pending log found for LogID: %s", logID.Hex())
+		return nil, time.Time{}, errNoPendingRewardClaim
 	}
+	logEntry.Status = status
+	logEntry.CallbackTime = callbackTime
+	eventbus.Default.CallbackReceived(ctx, logEntry)
 
 	missionID, err := primitive.ObjectIDFromHex(logEntry.MissionID)
 	if err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid mission ID in log entry"})
+		return nil, time.Time{}, fmt.Errorf("invalid mission ID in log entry")
 	}
 
 	var mission models.Mission
-	err = c.missionCollection.FindOne(ctx.Context(), bson.M{"_id": missionID}).Decode(&mission)
+	err = c.missionCollection.FindOne(ctx, tenant.Scope(ctx, bson.M{"_id": missionID})).Decode(&mission)
 	if err != nil {
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Mission not found"})
+		return nil, time.Time{}, errRewardMissionMissing
 	}
 
-	if callback.Status == "approve" {
-		err = c.processSuccessfulReward(ctx.Context(), &mission)
-	} else if callback.Status == "reject" {
-		err = c.processFailedReward(ctx.Context(), &mission)
+	if status == "approve" {
+		err = c.processSuccessfulReward(ctx, &mission)
 	} else {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status. Must be 'approve' or 'reject'"})
+		err = c.processFailedReward(ctx, &mission)
 	}
-
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return nil, time.Time{}, err
 	}
 
 	log.Printf("Reward callback processed successfully for Mission ID: %s, New Tier: %d, New Level: %d",
 		mission.ID.Hex(), mission.CurrentTier, mission.Tiers[mission.CurrentTier-1].CurrentLevel)
 
-	// Prepare the new response
-	var message string
-	if callback.Status == "approve" {
-		message = "Reward approved successfully"
-	} else {
-		message = "Reward rejected"
-	}
-
-	response := fiber.Map{
-		"log_id":        logID.Hex(),
-		"callback_time": callbackTime,
-		"status":        callback.Status,
-		"message":       message,
-	}
-
-	// Return both the original success message and the new response
-	return ctx.JSON(response)
+	return &mission, callbackTime, nil
 }
 
 func (c *RewardCallbackController) processSuccessfulReward(ctx context.Context, mission *models.Mission) error {
 	currentTier := &mission.Tiers[mission.CurrentTier-1]
 	currentTier.Status = "completed"
 
-	var config models.Config
-	err := c.configCollection.FindOne(ctx, bson.M{}).Decode(&config)
+	config, err := c.configService.Effective(ctx, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to fetch config: %v", err)
 	}
@@ -146,8 +181,11 @@ func (c *RewardCallbackController) processSuccessfulReward(ctx context.Context,
 
 	if mission.CurrentTier < 3 {
 		// Move to next tier for Tier 1 and 2
+		newTierConfig, err := missionTierRule(mission, config, mission.CurrentTier)
+		if err != nil {
+			return err
+		}
 		mission.CurrentTier++
-		newTierConfig := config.Tiers[mission.CurrentTier-1]
 		newTier := createNewTier(newTierConfig)
 		newTier.Levels[0].CurrentBet = currentBet // Set current bet for the first level of new tier
 		mission.Tiers = append(mission.Tiers, newTier)
@@ -156,7 +194,10 @@ func (c *RewardCallbackController) processSuccessfulReward(ctx context.Context,
 		c.createNewEvents(ctx, mission, &newTier, &newTier.Levels[0], newTierConfig)
 	} else {
 		// For Tier 3, create a new level
-		currentTierConfig := config.Tiers[mission.CurrentTier-1]
+		currentTierConfig, err := missionTierRule(mission, config, mission.CurrentTier-1)
+		if err != nil {
+			return err
+		}
 		currentTier.CurrentLevel++
 		newLevel := createNewLevel(currentTier.CurrentLevel, currentTierConfig.Period, currentTierConfig.FollowUpHours)
 		newLevel.CurrentBet = currentBet // Set current bet for the new level
@@ -205,6 +246,7 @@ func (c *RewardCallbackController) createNewEvents(ctx context.Context, mission
 	log.Printf("Creating new events with Processing Delay: %v", processingDelay)
 
 	levelExpirationEvent := models.ExpirationEvent{
+		TenantID:   mission.TenantID,
 		MissionID:  mission.ID,
 		TierIndex:  mission.CurrentTier - 1,
 		LevelIndex: currentTier.CurrentLevel - 1,
@@ -219,6 +261,7 @@ func (c *RewardCallbackController) createNewEvents(ctx context.Context, mission
 	log.Printf("Level expiration event created with ExpireTime: %v", levelExpirationEvent.ExpireTime)
 
 	followUpEvent := models.ExpirationEvent{
+		TenantID:   mission.TenantID,
 		MissionID:  mission.ID,
 		TierIndex:  mission.CurrentTier - 1,
 		LevelIndex: currentTier.CurrentLevel - 1,
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"context"
+	"go-server/config"
+	"go-server/models"
+	"go-server/tenant"
+	"go-server/utils"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StatsController ดูแล tbl_daily_stats ที่ dashboard ใช้แทนการ aggregate tbl_mission ทั้งหมดทุกครั้ง
+type StatsController struct {
+	statsCollection   *mongo.Collection
+	missionCollection *mongo.Collection
+	logCollection     *mongo.Collection
+	messageCollection *mongo.Collection
+	clientCollection  *mongo.Collection
+	configService     *config.Service
+
+	mu      sync.Mutex
+	lastRun map[string]time.Time // tenant -> เวลาที่ rollup ครั้งล่าสุดเริ่ม
+}
+
+func NewStatsController(statsCollection, missionCollection, logCollection, messageCollection, clientCollection *mongo.Collection, configService *config.Service) *StatsController {
+	return &StatsController{
+		statsCollection:   statsCollection,
+		missionCollection: missionCollection,
+		logCollection:     logCollection,
+		messageCollection: messageCollection,
+		clientCollection:  clientCollection,
+		configService:     configService,
+		lastRun:           map[string]time.Time{},
+	}
+}
+
+// ProcessRollup คำนวณสถิติรายวันใหม่ทุก 10 นาที เฉพาะวันนี้ เมื่อวาน และวันที่มีมิชชันเปลี่ยนแปลง
+// tenant ที่ยังไม่มีสถิติจะถูกคำนวณย้อนหลังตั้งแต่มิชชันแรก
+func (sc *StatsController) ProcessRollup() {
+	log.Println("Starting ProcessRollup")
+	for {
+		tenants, err := sc.configService.Tenants(context.Background())
+		if err != nil {
+			log.Printf("ProcessRollup: Failed to list tenants: %v", err)
+		}
+		for _, tenantID := range tenants {
+			ctx := tenant.With(context.Background(), tenantID)
+			if err := sc.rollup(ctx, tenantID); err != nil {
+				log.Printf("ProcessRollup: Failed to roll up tenant %s: %v", tenantID, err)
+			}
+		}
+		time.Sleep(10 * time.Minute)
+	}
+}
+
+func (sc *StatsController) rollup(ctx context.Context, tenantID string) error {
+	startedAt := time.Now()
+	sc.mu.Lock()
+	since, ok := sc.lastRun[tenantID]
+	sc.mu.Unlock()
+
+	if !ok {
+		count, err := sc.statsCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{}))
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			if err := sc.backfill(ctx); err != nil {
+				return err
+			}
+			sc.setLastRun(tenantID, startedAt)
+			return nil
+		}
+		// หลัง restart ไม่รู้ว่าครั้งล่าสุดทำถึงไหน จึงตรวจย้อนหลัง 1 วัน
+		since = startedAt.Add(-24 * time.Hour)
+	}
+
+	days := map[string]time.Time{}
+	r := utils.AnalyticsRange{Granularity: "day", Location: utils.AnalyticsLocation()}
+	for _, t := range []time.Time{startedAt, startedAt.AddDate(0, 0, -1)} {
+		day := r.Truncate(t)
+		days[day.Format("2006-01-02")] = day
+	}
+
+	opts := options.Find().SetProjection(bson.M{"created_at": 1})
+	cursor, err := sc.missionCollection.Find(ctx, tenant.Scope(ctx, bson.M{"updated_at": bson.M{"$gte": since}}), opts)
+	if err != nil {
+		return err
+	}
+	for cursor.Next(ctx) {
+		var mission models.Mission
+		if err := cursor.Decode(&mission); err == nil && !mission.CreatedAt.IsZero() {
+			day := r.Truncate(mission.CreatedAt)
+			days[day.Format("2006-01-02")] = day
+		}
+	}
+	cursor.Close(ctx)
+
+	for _, day := range days {
+		if err := sc.RebuildDay(ctx, day); err != nil {
+			return err
+		}
+	}
+	sc.setLastRun(tenantID, startedAt)
+	return nil
+}
+
+func (sc *StatsController) setLastRun(tenantID string, t time.Time) {
+	sc.mu.Lock()
+	sc.lastRun[tenantID] = t
+	sc.mu.Unlock()
+}
+
+// backfill คำนวณทุกวันตั้งแต่มิชชันแรกของ tenant ถึงวันนี้
+func (sc *StatsController) backfill(ctx context.Context) error {
+	var first models.Mission
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetProjection(bson.M{"created_at": 1})
+	err := sc.missionCollection.FindOne(ctx, tenant.Scope(ctx, bson.M{}), opts).Decode(&first)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r := utils.AnalyticsRange{From: first.CreatedAt, To: time.Now(), Granularity: "day", Location: utils.AnalyticsLocation()}
+	n, err := sc.RebuildRange(ctx, r)
+	log.Printf("ProcessRollup: Backfilled %d days of stats for tenant %s", n, tenant.FromContext(ctx))
+	return err
+}
+
+// RebuildRange คำนวณสถิติใหม่ทุกวันในช่วง
+func (sc *StatsController) RebuildRange(ctx context.Context, r utils.AnalyticsRange) (int, error) {
+	r.Granularity = "day"
+	days := 0
+	for _, day := range r.Buckets() {
+		if err := sc.RebuildDay(ctx, day); err != nil {
+			return days, err
+		}
+		days++
+	}
+	return days, nil
+}
+
+// RebuildDay คำนวณสถิติของวัน (day คือเวลาเริ่มวันตามเวลาไทย) แล้วบันทึกทับของเดิม
+func (sc *StatsController) RebuildDay(ctx context.Context, day time.Time) error {
+	next := day.AddDate(0, 0, 1)
+	inDay := bson.M{"$gte": day, "$lt": next}
+	stats := models.DailyStats{
+		TenantID:  tenant.Value(ctx),
+		Date:      day.Format("2006-01-02"),
+		Day:       day,
+		UpdatedAt: time.Now(),
+	}
+
+	opts := options.Find().SetProjection(bson.M{
+		"status":              1,
+		"tiers.name":          1,
+		"tiers.status":        1,
+		"tiers.current_level": 1,
+		"tiers.levels.status": 1,
+	})
+	cursor, err := sc.missionCollection.Find(ctx, tenant.Scope(ctx, bson.M{"created_at": inDay}), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var mission models.Mission
+		if err := cursor.Decode(&mission); err != nil {
+			log.Printf("RebuildDay: Failed to decode mission: %v", err)
+			continue
+		}
+		utils.AddMissionToDailyStats(&stats.Missions, mission)
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if stats.Claims.Requested, stats.Claims.RequestedAmount, err = sc.sumRewards(ctx, bson.M{"created_at": inDay}); err != nil {
+		return err
+	}
+	if stats.Claims.Approved, stats.Claims.ApprovedAmount, err = sc.sumRewards(ctx, bson.M{"status": "approve", "callback_time": inDay}); err != nil {
+		return err
+	}
+	if stats.Claims.Rejected, _, err = sc.sumRewards(ctx, bson.M{"status": "reject", "callback_time": inDay}); err != nil {
+		return err
+	}
+
+	sent, err := sc.messageCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{"sent_at": inDay}))
+	if err != nil {
+		return err
+	}
+	read, err := sc.messageCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{"read_at": inDay}))
+	if err != nil {
+		return err
+	}
+	newClients, err := sc.clientCollection.CountDocuments(ctx, tenant.Scope(ctx, bson.M{"created_at": inDay}))
+	if err != nil {
+		return err
+	}
+	stats.Messages.Sent, stats.Messages.Read, stats.NewClients = int(sent), int(read), int(newClients)
+
+	_, err = sc.statsCollection.ReplaceOne(ctx, tenant.Scope(ctx, bson.M{"date": stats.Date}), stats, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (sc *StatsController) sumRewards(ctx context.Context, match bson.M) (int, float64, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: tenant.Scope(ctx, match)}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "count", Value: bson.M{"$sum": 1}},
+			{Key: "amount", Value: bson.M{"$sum": "$reward"}},
+		}}},
+	}
+	cursor, err := sc.logCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Count  int         `bson:"count"`
+		Amount interface{} `bson:"amount"`
+	}
+	if err := cursor.All(ctx, &results); err != nil || len(results) == 0 {
+		return 0, 0, err
+	}
+	return results[0].Count, numberValue(results[0].Amount), nil
+}
+
+// Summary รวมสถิติทุกวันของ tenant ใน ctx (ถ้าระบุ r จะรวมเฉพาะวันในช่วง)
+func (sc *StatsController) Summary(ctx context.Context, r *utils.AnalyticsRange) (models.DailyStats, error) {
+	filter := bson.M{}
+	if r != nil {
+		filter["day"] = bson.M{"$gte": r.From, "$lt": r.To}
+	}
+	cursor, err := sc.statsCollection.Find(ctx, tenant.Scope(ctx, filter))
+	if err != nil {
+		return models.DailyStats{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var days []models.DailyStats
+	if err := cursor.All(ctx, &days); err != nil {
+		return models.DailyStats{}, err
+	}
+	return utils.SumDailyStats(days), nil
+}
+
+// GetDailyStats - สถิติรายวันในช่วง ?from=&to=
+func (sc *StatsController) GetDailyStats(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	r, err := utils.ParseAnalyticsRange(c.Query("from"), c.Query("to"), "day", 30)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "day", Value: 1}})
+	cursor, err := sc.statsCollection.Find(ctx, tenant.Scope(ctx, bson.M{"day": bson.M{"$gte": r.From, "$lt": r.To}}), opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get daily stats"})
+	}
+	defer cursor.Close(ctx)
+
+	days := make([]models.DailyStats, 0)
+	if err := cursor.All(ctx, &days); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode daily stats"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    days,
+		"total":   utils.SumDailyStats(days),
+	})
+}
+
+// RebuildStats - คำนวณสถิติรายวันใหม่ในช่วงที่ระบุ body: {"from": "2024-01-01", "to": "2024-01-31"}
+func (sc *StatsController) RebuildStats(c *fiber.Ctx) error {
+	var body struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if body.From == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from is required"})
+	}
+
+	r, err := utils.ParseAnalyticsRange(body.From, body.To, "day", 0)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	days, err := sc.RebuildRange(c.UserContext(), r)
+	if err != nil {
+		log.Printf("RebuildStats: Failed after %d days: %v", days, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to rebuild stats", "days": days})
+	}
+
+	log.Printf("Rebuilt %d days of stats by %s", days, utils.RequestActor(c))
+	return c.JSON(fiber.Map{
+		"success": true,
+		"from":    r.From,
+		"to":      r.To,
+		"days":    days,
+	})
+}
@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"go-server/models"
+	"go-server/tenant"
+	"html"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TelegramBotController รับ update จาก Telegram (webhook หรือ long-poll)
+// เพื่อให้ admin อนุมัติ/ปฏิเสธรางวัลและดูข้อมูลมิชชันผ่าน bot ได้
+type TelegramBotController struct {
+	telegramController       *TelegramController
+	rewardCallbackController *RewardCallbackController
+	missionCollection        *mongo.Collection
+	logCollection            *mongo.Collection
+	auditCollection          *mongo.Collection
+}
+
+func NewTelegramBotController(telegramController *TelegramController, rewardCallbackController *RewardCallbackController, missionCollection, logCollection, auditCollection *mongo.Collection) *TelegramBotController {
+	return &TelegramBotController{
+		telegramController:       telegramController,
+		rewardCallbackController: rewardCallbackController,
+		missionCollection:        missionCollection,
+		logCollection:            logCollection,
+		auditCollection:          auditCollection,
+	}
+}
+
+type telegramUser struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+}
+
+type telegramChat struct {
+	ID int64 `json:"id"`
+}
+
+type telegramMessage struct {
+	MessageID int64         `json:"message_id"`
+	From      *telegramUser `json:"from"`
+	Chat      telegramChat  `json:"chat"`
+	Text      string        `json:"text"`
+}
+
+type telegramCallbackQuery struct {
+	ID      string           `json:"id"`
+	From    telegramUser     `json:"from"`
+	Message *telegramMessage `json:"message"`
+	Data    string           `json:"data"`
+}
+
+type telegramUpdate struct {
+	UpdateID      int64                  `json:"update_id"`
+	Message       *telegramMessage       `json:"message"`
+	CallbackQuery *telegramCallbackQuery `json:"callback_query"`
+}
+
+// HandleWebhook รับ update จาก Telegram ผ่าน webhook (tenant มาจาก ?tenant_id= ใน URL ที่ลงทะเบียนไว้)
+func (bc *TelegramBotController) HandleWebhook(c *fiber.Ctx) error {
+	config, err := bc.telegramController.getConfig(c.UserContext())
+	if err != nil {
+		log.Printf("TelegramWebhook: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
+	}
+
+	if config.TelegramSecret == "" || c.Get("X-Telegram-Bot-Api-Secret-Token") != config.TelegramSecret {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid secret token"})
+	}
+
+	var update telegramUpdate
+	if err := c.BodyParser(&update); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid update"})
+	}
+
+	bc.handleUpdate(c.UserContext(), config, update)
+
+	// ตอบ 200 เสมอ ไม่เช่นนั้น Telegram จะส่ง update เดิมซ้ำ
+	return c.JSON(fiber.Map{"ok": true})
+}
+
+// SetWebhook ลงทะเบียน webhook URL กับ Telegram โดยใช้ BASE_URL และ secret token จาก config
+func (bc *TelegramBotController) SetWebhook(c *fiber.Ctx) error {
+	config, err := bc.telegramController.getConfig(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch config"})
+	}
+	if config.TelegramSecret == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Telegram secret is not configured"})
+	}
+
+	webhookURL := fmt.Sprintf("%s/api/telegram/webhook", os.Getenv("BASE_URL"))
+	if tenantID := tenant.Value(c.UserContext()); tenantID != "" {
+		webhookURL += "?tenant_id=" + url.QueryEscape(tenantID)
+	}
+	err = bc.telegramController.callAPI(config.TelegramBotToken, "setWebhook", map[string]interface{}{
+		"url":             webhookURL,
+		"secret_token":    config.TelegramSecret,
+		"allowed_updates": []string{"message", "callback_query"},
+	}, nil)
+	if err != nil {
+		log.Printf("SetWebhook: %v", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to set webhook"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Webhook set successfully", "url": webhookURL})
+}
+
+// StartPolling ดึง update ของ tenant ใน ctx ด้วย getUpdates แบบ long-poll (ใช้แทน webhook ตอนพัฒนา)
+func (bc *TelegramBotController) StartPolling(ctx context.Context) {
+	log.Printf("Starting Telegram polling for tenant %s", tenant.FromContext(ctx))
+	var offset int64
+	for {
+		config, err := bc.telegramController.getConfig(ctx)
+		if err != nil || config.TelegramBotToken == "" {
+			time.Sleep(30 * time.Second)
+			continue
+		}
+
+		var updates []telegramUpdate
+		err = bc.telegramController.callAPI(config.TelegramBotToken, "getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         30,
+			"allowed_updates": []string{"message", "callback_query"},
+		}, &updates)
+		if err != nil {
+			log.Printf("Error polling Telegram updates: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		for _, update := range updates {
+			bc.handleUpdate(ctx, config, update)
+			offset = update.UpdateID + 1
+		}
+	}
+}
+
+func (bc *TelegramBotController) handleUpdate(ctx context.Context, config models.Config, update telegramUpdate) {
+	switch {
+	case update.CallbackQuery != nil:
+		bc.handleCallbackQuery(ctx, config, update.CallbackQuery)
+	case update.Message != nil && update.Message.From != nil && strings.HasPrefix(update.Message.Text, "/"):
+		bc.handleCommand(ctx, config, update.Message)
+	}
+}
+
+func isTelegramAdmin(config models.Config, userID int64) bool {
+	id := strconv.FormatInt(userID, 10)
+	for _, adminID := range config.TelegramAdminIDs {
+		if adminID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func telegramActorName(user telegramUser) string {
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return user.FirstName
+}
+
+func (bc *TelegramBotController) handleCallbackQuery(ctx context.Context, config models.Config, query *telegramCallbackQuery) {
+	action, target, _ := strings.Cut(query.Data, ":")
+	auditAction := action + "_reward"
+
+	if !isTelegramAdmin(config, query.From.ID) {
+		bc.audit(ctx, query.From, auditAction, target, "denied", "user is not an authorised admin")
+		bc.answerCallback(config, query.ID, "คุณไม่มีสิทธิ์ดำเนินการนี้")
+		return
+	}
+
+	if action != "approve" && action != "reject" {
+		bc.answerCallback(config, query.ID, "ไม่รู้จักคำสั่งนี้")
+		return
+	}
+
+	logID, err := primitive.ObjectIDFromHex(target)
+	if err != nil {
+		bc.answerCallback(config, query.ID, "Log ID ไม่ถูกต้อง")
+		return
+	}
+
+	_, _, err = bc.rewardCallbackController.ProcessRewardDecision(ctx, logID, action)
+	if err != nil {
+		log.Printf("Telegram %s reward failed for LogID %s: %v", action, target, err)
+		bc.audit(ctx, query.From, auditAction, target, "error", err.Error())
+		bc.answerCallback(config, query.ID, "ดำเนินการไม่สำเร็จ: "+err.Error())
+		return
+	}
+
+	bc.audit(ctx, query.From, auditAction, target, "success", "")
+
+	resultText := "✅ อนุมัติรางวัลแล้ว"
+	if action == "reject" {
+		resultText = "❌ ปฏิเสธรางวัลแล้ว"
+	}
+	bc.answerCallback(config, query.ID, resultText)
+
+	// เอาปุ่มออกจากข้อความเดิม และแจ้งผลในแชท
+	if query.Message != nil {
+		err := bc.telegramController.callAPI(config.TelegramBotToken, "editMessageReplyMarkup", map[string]interface{}{
+			"chat_id":      query.Message.Chat.ID,
+			"message_id":   query.Message.MessageID,
+			"reply_markup": telegramInlineKeyboard{InlineKeyboard: [][]telegramInlineButton{}},
+		}, nil)
+		if err != nil {
+			log.Printf("Failed to remove Telegram inline keyboard: %v", err)
+		}
+
+		message := fmt.Sprintf("%s โดย %s\nLog ID: <code>%s</code>", resultText, html.EscapeString(telegramActorName(query.From)), target)
+		if err := bc.reply(config, query.Message.Chat.ID, query.Message.MessageID, message); err != nil {
+			log.Printf("Failed to send Telegram reply: %v", err)
+		}
+	}
+}
+
+func (bc *TelegramBotController) handleCommand(ctx context.Context, config models.Config, message *telegramMessage) {
+	fields := strings.Fields(message.Text)
+	// คำสั่งในกลุ่มอาจมาในรูป /mission@BotName
+	command, _, _ := strings.Cut(fields[0], "@")
+	args := fields[1:]
+
+	if !isTelegramAdmin(config, message.From.ID) {
+		bc.audit(ctx, *message.From, strings.TrimPrefix(command, "/"), strings.Join(args, " "), "denied", "user is not an authorised admin")
+		bc.reply(config, message.Chat.ID, message.MessageID, "คุณไม่มีสิทธิ์ใช้คำสั่งนี้")
+		return
+	}
+
+	var response string
+	switch command {
+	case "/mission":
+		if len(args) == 0 {
+			response = "วิธีใช้: /mission &lt;userId&gt;"
+			break
+		}
+		response = bc.missionSummary(ctx, args[0])
+		bc.audit(ctx, *message.From, "query_mission", args[0], "success", "")
+	case "/pending":
+		response = bc.pendingClaimsSummary(ctx)
+		bc.audit(ctx, *message.From, "query_pending", "", "success", "")
+	case "/help", "/start":
+		response = "<b>คำสั่งที่ใช้ได้</b>\n" +
+			"/mission &lt;userId&gt; - ดูมิชชันล่าสุดของผู้ใช้\n" +
+			"/pending - รายการรางวัลที่รออนุมัติ"
+	default:
+		response = "ไม่รู้จักคำสั่งนี้ พิมพ์ /help เพื่อดูคำสั่งที่ใช้ได้"
+	}
+
+	if err := bc.reply(config, message.Chat.ID, message.MessageID, response); err != nil {
+		log.Printf("Failed to send Telegram reply: %v", err)
+	}
+}
+
+func (bc *TelegramBotController) missionSummary(ctx context.Context, userID string) string {
+	var mission models.Mission
+	opts := options.FindOne().SetSort(bson.M{"created_at": -1})
+	err := bc.missionCollection.FindOne(ctx, tenant.Scope(ctx, bson.M{"user_id": userID}), opts).Decode(&mission)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return fmt.Sprintf("ไม่พบมิชชันของ <code>%s</code>", html.EscapeString(userID))
+		}
+		log.Printf("Failed to fetch mission for Telegram command: %v", err)
+		return "ดึงข้อมูลมิชชันไม่สำเร็จ"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<b>Mission</b> <code>%s</code>\n", mission.ID.Hex())
+	fmt.Fprintf(&sb, "User ID: <code>%s</code>\n", html.EscapeString(mission.UserID))
+	fmt.Fprintf(&sb, "Phone: %s\n", html.EscapeString(mission.PhoneNumber))
+	fmt.Fprintf(&sb, "Status: <b>%s</b>\n", mission.Status)
+	fmt.Fprintf(&sb, "Current Tier: <b>%d</b>\n", mission.CurrentTier)
+	for i, tier := range mission.Tiers {
+		fmt.Fprintf(&sb, "\nTier %d (%s): %s, Level %d/%d", i+1, html.EscapeString(tier.Name), tier.Status, tier.CurrentLevel, tier.MaxLevel)
+		if len(tier.Levels) > 0 {
+			level := tier.Levels[len(tier.Levels)-1]
+			fmt.Fprintf(&sb, "\n  %s: %s, Bet %.2f / %d", level.Name, level.Status, level.CurrentBet, tier.Target)
+		}
+	}
+	return sb.String()
+}
+
+func (bc *TelegramBotController) pendingClaimsSummary(ctx context.Context) string {
+	opts := options.Find().SetSort(bson.M{"created_at": 1}).SetLimit(10)
+	cursor, err := bc.logCollection.Find(ctx, tenant.Scope(ctx, bson.M{"status": "pending"}), opts)
+	if err != nil {
+		log.Printf("Failed to fetch pending claims for Telegram command: %v", err)
+		return "ดึงข้อมูลรางวัลที่รออนุมัติไม่สำเร็จ"
+	}
+	defer cursor.Close(ctx)
+
+	var logs []models.Log
+	if err := cursor.All(ctx, &logs); err != nil {
+		return "ดึงข้อมูลรางวัลที่รออนุมัติไม่สำเร็จ"
+	}
+	if len(logs) == 0 {
+		return "ไม่มีรางวัลที่รออนุมัติ"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<b>รางวัลที่รออนุมัติ</b>\n")
+	for _, entry := range logs {
+		fmt.Fprintf(&sb, "\n<code>%s</code> %s - %.0f (%s)", entry.ID.Hex(), html.EscapeString(entry.UserID), entry.Reward, entry.CreatedAt.Format("02/01/2006 15:04"))
+	}
+	return sb.String()
+}
+
+func (bc *TelegramBotController) reply(config models.Config, chatID, replyTo int64, message string) error {
+	return bc.telegramController.callAPI(config.TelegramBotToken, "sendMessage", map[string]interface{}{
+		"chat_id":             chatID,
+		"text":                message,
+		"parse_mode":          "HTML",
+		"reply_to_message_id": replyTo,
+	}, nil)
+}
+
+func (bc *TelegramBotController) answerCallback(config models.Config, callbackID, text string) {
+	err := bc.telegramController.callAPI(config.TelegramBotToken, "answerCallbackQuery", map[string]interface{}{
+		"callback_query_id": callbackID,
+		"text":              text,
+	}, nil)
+	if err != nil {
+		log.Printf("Failed to answer Telegram callback: %v", err)
+	}
+}
+
+func (bc *TelegramBotController) audit(ctx context.Context, user telegramUser, action, target, result, detail string) {
+	entry := models.AdminAction{
+		TenantID:  tenant.Value(ctx),
+		Source:    "telegram",
+		ActorID:   strconv.FormatInt(user.ID, 10),
+		ActorName: telegramActorName(user),
+		Action:    action,
+		Target:    target,
+		Result:    result,
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	}
+	if _, err := bc.auditCollection.InsertOne(ctx, entry); err != nil {
+		log.Printf("Failed to write admin audit log: %v", err)
+	}
+}
@@ -0,0 +1,469 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-server/config"
+	"go-server/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+const (
+	testBotToken   = "123:test-token"
+	testBotSecret  = "webhook-secret"
+	testAdminID    = 1001
+	testOutsiderID = 2002
+)
+
+// fakeTelegram Bot API จำลองที่บันทึกทุก request (ชี้ TELEGRAM_API_URL มาที่ server นี้)
+type fakeTelegram struct {
+	server *httptest.Server
+	mu     sync.Mutex
+	calls  []telegramCall
+}
+
+type telegramCall struct {
+	Token   string
+	Method  string
+	Payload map[string]interface{}
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	fake := &fakeTelegram{}
+	fake.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// path อยู่ในรูป /bot<token>/<method>
+		token, method, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		fake.mu.Lock()
+		fake.calls = append(fake.calls, telegramCall{Token: token, Method: method, Payload: payload})
+		fake.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	t.Cleanup(fake.server.Close)
+	t.Setenv("TELEGRAM_API_URL", fake.server.URL)
+	return fake
+}
+
+func (f *fakeTelegram) methods() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	methods := make([]string, 0, len(f.calls))
+	for _, call := range f.calls {
+		methods = append(methods, call.Method)
+	}
+	return methods
+}
+
+func (f *fakeTelegram) call(t *testing.T, method string) telegramCall {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, call := range f.calls {
+		if call.Method == method {
+			if call.Token != testBotToken {
+				t.Errorf("%s called with token %q", method, call.Token)
+			}
+			return call
+		}
+	}
+	t.Fatalf("Bot API method %s was not called (calls: %v)", method, f.calls)
+	return telegramCall{}
+}
+
+func newTestTelegramBot(mt *mtest.T) *fiber.App {
+	configService := config.NewService(mt.DB.Collection("tbl_config"), mt.DB.Collection("tbl_config_overlay"))
+	missionCollection := mt.DB.Collection("tbl_mission")
+	logCollection := mt.DB.Collection("tbl_logs")
+	bot := NewTelegramBotController(
+		NewTelegramController(configService),
+		NewRewardCallbackController(missionCollection, logCollection, configService, mt.DB.Collection("tbl_events"), nil),
+		missionCollection,
+		logCollection,
+		mt.DB.Collection("tbl_logs_admin_action"),
+	)
+
+	app := fiber.New()
+	app.Post("/api/telegram/webhook", bot.HandleWebhook)
+	return app
+}
+
+func testTierRules() []models.TierDetail {
+	return []models.TierDetail{
+		{Name: "Bronze", Period: 24, Target: 1000, Reward: 100, MaxLevel: 3, FollowUpHours: 12},
+		{Name: "Silver", Period: 48, Target: 3000, Reward: 300, MaxLevel: 3, FollowUpHours: 24},
+		{Name: "Gold", Period: 72, Target: 5000, Reward: 500, MaxLevel: 5, FollowUpHours: 36},
+	}
+}
+
+func testTelegramConfig() models.Config {
+	return models.Config{
+		Tiers:            testTierRules(),
+		TelegramBotToken: testBotToken,
+		TelegramChatID:   "-100",
+		TelegramAdminIDs: []string{"1001"},
+		TelegramSecret:   testBotSecret,
+	}
+}
+
+// testAwaitingRewardMission มิชชันที่ผ่าน tier 1 แล้วและรอการอนุมัติรางวัล
+func testAwaitingRewardMission() models.Mission {
+	now := time.Now()
+	return models.Mission{
+		ID:          primitive.NewObjectID(),
+		UserID:      "U123",
+		PhoneNumber: "0812345678",
+		Status:      "pending",
+		CurrentTier: 1,
+		TierRules:   testTierRules(),
+		Tiers: []models.Tier{{
+			Name: "Bronze", Reward: 100, Target: 1000, Status: "pending", CurrentLevel: 1, MaxLevel: 3,
+			Levels: []models.Level{{
+				Name: "level 1", StartDate: now.Add(-24 * time.Hour), ExpireDate: now, FollowUpDate: now.Add(-12 * time.Hour),
+				Status: "completed", CurrentBet: 1200,
+			}},
+		}},
+		CreatedAt: now.Add(-24 * time.Hour),
+		UpdatedAt: now,
+	}
+}
+
+// mockDocument แปลง struct เป็นเอกสารสำหรับ mock response
+func mockDocument(t *testing.T, v interface{}) bson.D {
+	t.Helper()
+	data, err := bson.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func mockFindOne(mt *mtest.T, ns string, doc bson.D) bson.D {
+	return mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, doc)
+}
+
+func mockWrite(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+}
+
+// sentCommands คืน command ที่ส่งไปยัง collection ตามชื่อ command (เช่น "update", "insert")
+func sentCommands(mt *mtest.T, name, collection string) []bson.Raw {
+	var commands []bson.Raw
+	for _, started := range mt.GetAllStartedEvents() {
+		if started.CommandName != name {
+			continue
+		}
+		if value, err := started.Command.LookupErr(name); err == nil && value.StringValue() == collection {
+			commands = append(commands, started.Command)
+		}
+	}
+	return commands
+}
+
+// updateOf คืน filter และ update ของ update command แรกใน cmd
+func updateOf(t *testing.T, cmd bson.Raw) (bson.M, bson.M) {
+	t.Helper()
+	var decoded struct {
+		Updates []struct {
+			Q bson.M `bson:"q"`
+			U bson.M `bson:"u"`
+		} `bson:"updates"`
+	}
+	if err := bson.Unmarshal(cmd, &decoded); err != nil || len(decoded.Updates) == 0 {
+		t.Fatalf("cannot decode update command %s: %v", cmd, err)
+	}
+	return decoded.Updates[0].Q, decoded.Updates[0].U
+}
+
+// insertedAudit คืนรายการ audit ที่เขียนลง tbl_logs_admin_action
+func insertedAudit(t *testing.T, mt *mtest.T) []models.AdminAction {
+	t.Helper()
+	var actions []models.AdminAction
+	for _, cmd := range sentCommands(mt, "insert", "tbl_logs_admin_action") {
+		var decoded struct {
+			Documents []models.AdminAction `bson:"documents"`
+		}
+		if err := bson.Unmarshal(cmd, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, decoded.Documents...)
+	}
+	return actions
+}
+
+func postUpdate(t *testing.T, app *fiber.App, secret string, update interface{}) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(update)
+	req := httptest.NewRequest(http.MethodPost, "/api/telegram/webhook", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func callbackUpdate(fromID int64, data string) fiber.Map {
+	return fiber.Map{
+		"update_id": 1,
+		"callback_query": fiber.Map{
+			"id":      "cb-1",
+			"from":    fiber.Map{"id": fromID, "username": "admin"},
+			"data":    data,
+			"message": fiber.Map{"message_id": 55, "chat": fiber.Map{"id": -100}},
+		},
+	}
+}
+
+func TestHandleWebhookRejectsInvalidSecret(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("invalid secret", func(mt *mtest.T) {
+		fake := newFakeTelegram(t)
+		app := newTestTelegramBot(mt)
+		mt.AddMockResponses(mockFindOne(mt, "test.tbl_config", mockDocument(t, testTelegramConfig())))
+
+		resp := postUpdate(t, app, "wrong-secret", callbackUpdate(testAdminID, "approve:"+primitive.NewObjectID().Hex()))
+		if resp.StatusCode != fiber.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", resp.StatusCode)
+		}
+		if methods := fake.methods(); len(methods) != 0 {
+			t.Errorf("Bot API should not be called, got %v", methods)
+		}
+		if updates := sentCommands(mt, "update", "tbl_logs"); len(updates) != 0 {
+			t.Errorf("claim should not be updated, got %d updates", len(updates))
+		}
+	})
+}
+
+func TestHandleWebhookApproveReward(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("approve", func(mt *mtest.T) {
+		fake := newFakeTelegram(t)
+		app := newTestTelegramBot(mt)
+		mission := testAwaitingRewardMission()
+		claim := models.Log{ID: primitive.NewObjectID(), UserID: mission.UserID, MissionID: mission.ID.Hex(), Tier: 1, Reward: 100, Status: "pending", CreatedAt: time.Now()}
+
+		mt.AddMockResponses(
+			mockFindOne(mt, "test.tbl_config", mockDocument(t, testTelegramConfig())),
+			mockFindOne(mt, "test.tbl_logs", mockDocument(t, claim)),
+			mockWrite(1), // tbl_logs: pending -> approve
+			mockFindOne(mt, "test.tbl_mission", mockDocument(t, mission)),
+			mtest.CreateCursorResponse(0, "test.tbl_config_overlay", mtest.FirstBatch), // ไม่มี overlay
+			mockWrite(1), // tbl_events: level_expiration
+			mockWrite(1), // tbl_events: follow_up
+			mockWrite(1), // tbl_mission
+			mockWrite(1), // audit
+		)
+
+		resp := postUpdate(t, app, testBotSecret, callbackUpdate(testAdminID, "approve:"+claim.ID.Hex()))
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+
+		// claim ถูกเปลี่ยนจาก pending เป็น approve
+		logUpdates := sentCommands(mt, "update", "tbl_logs")
+		if len(logUpdates) != 1 {
+			t.Fatalf("tbl_logs updates = %d, want 1", len(logUpdates))
+		}
+		filter, update := updateOf(t, logUpdates[0])
+		if filter["_id"] != claim.ID || filter["status"] != "pending" {
+			t.Errorf("claim filter = %v", filter)
+		}
+		if set := update["$set"].(bson.M); set["status"] != "approve" {
+			t.Errorf("claim status = %v, want approve", set["status"])
+		}
+
+		// มิชชันขึ้น tier 2 และกลับมา processing
+		missionUpdates := sentCommands(mt, "update", "tbl_mission")
+		if len(missionUpdates) != 1 {
+			t.Fatalf("tbl_mission updates = %d, want 1", len(missionUpdates))
+		}
+		_, update = updateOf(t, missionUpdates[0])
+		set := update["$set"].(bson.M)
+		if set["current_tier"] != int32(2) || set["status"] != "processing" || len(set["tiers"].(bson.A)) != 2 {
+			t.Errorf("mission after approve: current_tier=%v status=%v tiers=%d", set["current_tier"], set["status"], len(set["tiers"].(bson.A)))
+		}
+		if events := sentCommands(mt, "insert", "tbl_events"); len(events) != 2 {
+			t.Errorf("tbl_events inserts = %d, want 2", len(events))
+		}
+
+		audit := insertedAudit(t, mt)
+		if len(audit) != 1 || audit[0].Action != "approve_reward" || audit[0].Result != "success" || audit[0].ActorID != "1001" || audit[0].Target != claim.ID.Hex() {
+			t.Errorf("audit = %+v", audit)
+		}
+
+		if got := strings.Join(fake.methods(), ","); got != "answerCallbackQuery,editMessageReplyMarkup,sendMessage" {
+			t.Errorf("Bot API calls = %s", got)
+		}
+		if text := fake.call(t, "answerCallbackQuery").Payload["text"]; text != "✅ อนุมัติรางวัลแล้ว" {
+			t.Errorf("answerCallbackQuery text = %v", text)
+		}
+		edit := fake.call(t, "editMessageReplyMarkup").Payload
+		if edit["message_id"] != float64(55) || edit["chat_id"] != float64(-100) {
+			t.Errorf("editMessageReplyMarkup payload = %v", edit)
+		}
+		if text := fake.call(t, "sendMessage").Payload["text"].(string); !strings.Contains(text, claim.ID.Hex()) || !strings.Contains(text, "@admin") {
+			t.Errorf("sendMessage text = %q", text)
+		}
+	})
+}
+
+func TestHandleWebhookRejectReward(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("reject", func(mt *mtest.T) {
+		fake := newFakeTelegram(t)
+		app := newTestTelegramBot(mt)
+		mission := testAwaitingRewardMission()
+		claim := models.Log{ID: primitive.NewObjectID(), UserID: mission.UserID, MissionID: mission.ID.Hex(), Tier: 1, Reward: 100, Status: "pending", CreatedAt: time.Now()}
+
+		mt.AddMockResponses(
+			mockFindOne(mt, "test.tbl_config", mockDocument(t, testTelegramConfig())),
+			mockFindOne(mt, "test.tbl_logs", mockDocument(t, claim)),
+			mockWrite(1), // tbl_logs: pending -> reject
+			mockFindOne(mt, "test.tbl_mission", mockDocument(t, mission)),
+			mockWrite(1), // tbl_mission
+			mockWrite(1), // audit
+		)
+
+		resp := postUpdate(t, app, testBotSecret, callbackUpdate(testAdminID, "reject:"+claim.ID.Hex()))
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+
+		logUpdates := sentCommands(mt, "update", "tbl_logs")
+		if len(logUpdates) != 1 {
+			t.Fatalf("tbl_logs updates = %d, want 1", len(logUpdates))
+		}
+		if _, update := updateOf(t, logUpdates[0]); update["$set"].(bson.M)["status"] != "reject" {
+			t.Errorf("claim update = %v, want status reject", update)
+		}
+
+		// tier กลับไปรอรับรางวัล ไม่สร้าง tier หรือ event ใหม่
+		missionUpdates := sentCommands(mt, "update", "tbl_mission")
+		if len(missionUpdates) != 1 {
+			t.Fatalf("tbl_mission updates = %d, want 1", len(missionUpdates))
+		}
+		_, update := updateOf(t, missionUpdates[0])
+		set := update["$set"].(bson.M)
+		tiers := set["tiers"].(bson.A)
+		if set["current_tier"] != int32(1) || len(tiers) != 1 || tiers[0].(bson.M)["status"] != "awaiting_reward" {
+			t.Errorf("mission after reject: current_tier=%v tiers=%v", set["current_tier"], tiers)
+		}
+		if events := sentCommands(mt, "insert", "tbl_events"); len(events) != 0 {
+			t.Errorf("tbl_events inserts = %d, want 0", len(events))
+		}
+
+		audit := insertedAudit(t, mt)
+		if len(audit) != 1 || audit[0].Action != "reject_reward" || audit[0].Result != "success" {
+			t.Errorf("audit = %+v", audit)
+		}
+		if text := fake.call(t, "answerCallbackQuery").Payload["text"]; text != "❌ ปฏิเสธรางวัลแล้ว" {
+			t.Errorf("answerCallbackQuery text = %v", text)
+		}
+		fake.call(t, "editMessageReplyMarkup")
+		fake.call(t, "sendMessage")
+	})
+}
+
+func TestHandleWebhookDeniesNonAdmin(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("non-admin", func(mt *mtest.T) {
+		fake := newFakeTelegram(t)
+		app := newTestTelegramBot(mt)
+		logID := primitive.NewObjectID().Hex()
+
+		mt.AddMockResponses(
+			mockFindOne(mt, "test.tbl_config", mockDocument(t, testTelegramConfig())),
+			mockWrite(1), // audit
+		)
+
+		resp := postUpdate(t, app, testBotSecret, callbackUpdate(testOutsiderID, "approve:"+logID))
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		if updates := sentCommands(mt, "update", "tbl_logs"); len(updates) != 0 {
+			t.Errorf("claim should not be updated, got %d updates", len(updates))
+		}
+		audit := insertedAudit(t, mt)
+		if len(audit) != 1 || audit[0].Result != "denied" || audit[0].ActorID != "2002" || audit[0].Target != logID {
+			t.Errorf("audit = %+v", audit)
+		}
+		if got := strings.Join(fake.methods(), ","); got != "answerCallbackQuery" {
+			t.Errorf("Bot API calls = %s, want answerCallbackQuery only", got)
+		}
+	})
+}
+
+func TestHandleWebhookMissionCommand(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("mission", func(mt *mtest.T) {
+		fake := newFakeTelegram(t)
+		app := newTestTelegramBot(mt)
+		mission := testAwaitingRewardMission()
+
+		mt.AddMockResponses(
+			mockFindOne(mt, "test.tbl_config", mockDocument(t, testTelegramConfig())),
+			mockFindOne(mt, "test.tbl_mission", mockDocument(t, mission)),
+			mockWrite(1), // audit
+		)
+
+		resp := postUpdate(t, app, testBotSecret, fiber.Map{
+			"update_id": 2,
+			"message": fiber.Map{
+				"message_id": 77,
+				"from":       fiber.Map{"id": testAdminID, "username": "admin"},
+				"chat":       fiber.Map{"id": -100},
+				"text":       "/mission@RewardBot U123",
+			},
+		})
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+
+		finds := sentCommands(mt, "find", "tbl_mission")
+		if len(finds) != 1 {
+			t.Fatalf("tbl_mission finds = %d, want 1", len(finds))
+		}
+		if userID := finds[0].Lookup("filter", "user_id").StringValue(); userID != "U123" {
+			t.Errorf("mission filter user_id = %q", userID)
+		}
+		// คำสั่งอ่านอย่างเดียวต้องไม่แก้มิชชัน
+		if updates := sentCommands(mt, "update", "tbl_mission"); len(updates) != 0 {
+			t.Errorf("mission should not be updated, got %d updates", len(updates))
+		}
+
+		audit := insertedAudit(t, mt)
+		if len(audit) != 1 || audit[0].Action != "query_mission" || audit[0].Target != "U123" || audit[0].Result != "success" {
+			t.Errorf("audit = %+v", audit)
+		}
+
+		reply := fake.call(t, "sendMessage").Payload
+		text := reply["text"].(string)
+		if reply["reply_to_message_id"] != float64(77) || reply["parse_mode"] != "HTML" {
+			t.Errorf("sendMessage payload = %v", reply)
+		}
+		for _, want := range []string{mission.ID.Hex(), "U123", "Current Tier: <b>1</b>", "Bronze"} {
+			if !strings.Contains(text, want) {
+				t.Errorf("reply %q does not contain %q", text, want)
+			}
+		}
+	})
+}
@@ -5,26 +5,28 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"go-server/config"
 	"go-server/models"
 	"net/http"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"os"
+	"strings"
 )
 
 type TelegramController struct {
-	configCollection *mongo.Collection
+	configService *config.Service
 }
 
-func NewTelegramController(configCollection *mongo.Collection) *TelegramController {
+func NewTelegramController(configService *config.Service) *TelegramController {
 	return &TelegramController{
-		configCollection: configCollection,
+		configService: configService,
 	}
 }
 
 // Message templates
 var telegramMessages = struct {
 	RewardClaimed func(missionID string, userId string, tier int, level int, reward int) string
+	Alert         func(title string, detail string) string
+	AlertResolved func(title string) string
 }{
 	RewardClaimed: func(missionID string, userId string, tier int, level int, reward int) string {
 		return fmt.Sprintf(
@@ -36,13 +38,49 @@ var telegramMessages = struct {
 				"Reward: <b>%d</b>",
 			missionID, userId, tier, level, reward)
 	},
+	Alert: func(title string, detail string) string {
+		return fmt.Sprintf("<b>⚠️ %s</b>\n\n%s", title, detail)
+	},
+	AlertResolved: func(title string) string {
+		return fmt.Sprintf("<b>✅ %s</b>\n\nกลับสู่สถานะปกติแล้ว", title)
+	},
+}
+
+type telegramInlineButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+type telegramInlineKeyboard struct {
+	InlineKeyboard [][]telegramInlineButton `json:"inline_keyboard"`
+}
+
+// rewardDecisionKeyboard ปุ่มอนุมัติ/ปฏิเสธสำหรับข้อความขอรับรางวัล
+func rewardDecisionKeyboard(logID string) *telegramInlineKeyboard {
+	return &telegramInlineKeyboard{
+		InlineKeyboard: [][]telegramInlineButton{
+			{
+				{Text: "✅ อนุมัติ", CallbackData: "approve:" + logID},
+				{Text: "❌ ปฏิเสธ", CallbackData: "reject:" + logID},
+			},
+		},
+	}
 }
 
-func (tc *TelegramController) SendRewardClaimedMessage(missionID string, userId string, tier int, level int, reward int) error {
+func (tc *TelegramController) getConfig(ctx context.Context) (models.Config, error) {
 	var config models.Config
-	err := tc.configCollection.FindOne(context.Background(), bson.M{}).Decode(&config)
+	err := tc.configService.Load(ctx, &config)
+	if err != nil {
+		return config, fmt.Errorf("failed to fetch config: %v", err)
+	}
+	return config, nil
+}
+
+// SendRewardClaimedMessage แจ้ง admin ว่ามีการขอรับรางวัล พร้อมปุ่มอนุมัติ/ปฏิเสธเมื่อมี logID
+func (tc *TelegramController) SendRewardClaimedMessage(ctx context.Context, missionID string, userId string, tier int, level int, reward int, logID string) error {
+	config, err := tc.getConfig(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to fetch config: %v", err)
+		return err
 	}
 
 	botToken := config.TelegramBotToken
@@ -50,35 +88,80 @@ func (tc *TelegramController) SendRewardClaimedMessage(missionID string, userId
 
 	message := telegramMessages.RewardClaimed(missionID, userId, tier, level, reward)
 
-	return tc.sendHTMLMessage(botToken, chatID, message)
+	var keyboard *telegramInlineKeyboard
+	if logID != "" {
+		keyboard = rewardDecisionKeyboard(logID)
+	}
+
+	return tc.sendHTMLMessage(botToken, chatID, message, keyboard)
 }
 
-func (tc *TelegramController) sendHTMLMessage(botToken, chatID, message string) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", botToken)
-	body, err := json.Marshal(map[string]string{
+// SendAdminMessage ส่งข้อความ HTML ไปยังแชท admin ที่ตั้งค่าไว้
+func (tc *TelegramController) SendAdminMessage(ctx context.Context, message string) error {
+	config, err := tc.getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	return tc.sendHTMLMessage(config.TelegramBotToken, config.TelegramChatID, message, nil)
+}
+
+func (tc *TelegramController) sendHTMLMessage(botToken, chatID, message string, keyboard *telegramInlineKeyboard) error {
+	payload := map[string]interface{}{
 		"chat_id":    chatID,
 		"text":       message,
 		"parse_mode": "HTML",
-	})
+	}
+	if keyboard != nil {
+		payload["reply_markup"] = keyboard
+	}
+
+	return tc.callAPI(botToken, "sendMessage", payload, nil)
+}
+
+// telegramAPIURL คืน URL ของ Bot API ใช้ TELEGRAM_API_URL แทนได้ (เช่น fake server ตอนทดสอบ)
+func telegramAPIURL(botToken, method string) string {
+	baseURL := os.Getenv("TELEGRAM_API_URL")
+	if baseURL == "" {
+		baseURL = "https://api.telegram.org"
+	}
+	return fmt.Sprintf("%s/bot%s/%s", strings.TrimRight(baseURL, "/"), botToken, method)
+}
+
+// callAPI เรียก Telegram Bot API และ decode field "result" ลงใน result (ถ้าไม่เป็น nil)
+func (tc *TelegramController) callAPI(botToken, method string, payload interface{}, result interface{}) error {
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request body: %v", err)
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	resp, err := http.Post(telegramAPIURL(botToken, method), "application/json", bytes.NewBuffer(body))
 	if err != nil {
-		return fmt.Errorf("failed to send message: %v", err)
+		return fmt.Errorf("failed to call %s: %v", method, err)
 	}
 	defer resp.Body.Close()
 
+	var apiResponse struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&apiResponse)
+
 	if resp.StatusCode != http.StatusOK {
-		var errorResponse struct {
-			Description string `json:"description"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err == nil {
-			return fmt.Errorf("unexpected status code: %d, error: %s", resp.StatusCode, errorResponse.Description)
+		if decodeErr == nil && apiResponse.Description != "" {
+			return fmt.Errorf("unexpected status code: %d, error: %s", resp.StatusCode, apiResponse.Description)
 		}
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
+	if decodeErr != nil {
+		return fmt.Errorf("failed to decode %s response: %v", method, decodeErr)
+	}
+
+	if result != nil && len(apiResponse.Result) > 0 {
+		if err := json.Unmarshal(apiResponse.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %v", method, err)
+		}
+	}
 
 	return nil
 }
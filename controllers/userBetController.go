@@ -1,11 +1,12 @@
 package controllers
 
 import (
-	"context"
 	"log"
 	"time"
 
+	"go-server/config"
 	"go-server/models"
+	"go-server/tenant"
 	"go-server/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,14 +16,14 @@ import (
 )
 
 type UserBetController struct {
-	collection       *mongo.Collection
-	configCollection *mongo.Collection
+	collection    *mongo.Collection
+	configService *config.Service
 }
 
-func NewUserBetController(collection, configCollection *mongo.Collection) *UserBetController {
+func NewUserBetController(collection *mongo.Collection, configService *config.Service) *UserBetController {
 	return &UserBetController{
-		collection:       collection,
-		configCollection: configCollection,
+		collection:    collection,
+		configService: configService,
 	}
 }
 
@@ -60,7 +61,7 @@ func (c *UserBetController) GetCurrentBet(ctx *fiber.Ctx) error {
 
 	log.Println("GetCurrentBet: Fetching config")
 	var config models.Config
-	err = c.configCollection.FindOne(context.Background(), bson.M{}).Decode(&config)
+	err = c.configService.Load(ctx.UserContext(), &config)
 	if err != nil {
 		log.Printf("GetCurrentBet: Failed to fetch config - %v", err)
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
@@ -94,11 +95,14 @@ func (c *UserBetController) UpdateCurrentBet(ctx *fiber.Ctx) error {
 			"updated_at":  time.Now(),
 		},
 	}
+	if tenantID := tenant.Value(ctx.UserContext()); tenantID != "" {
+		update["$setOnInsert"] = bson.M{"tenant_id": tenantID}
+	}
 
 	opts := options.Update().SetUpsert(true)
 	_, err := c.collection.UpdateOne(
-		context.Background(),
-		bson.M{"user_id": input.UserID},
+		ctx.UserContext(),
+		tenant.Scope(ctx.UserContext(), bson.M{"user_id": input.UserID}),
 		update,
 		opts,
 	)
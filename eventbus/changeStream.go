@@ -0,0 +1,98 @@
+package eventbus
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-server/models"
+	"go-server/tenant"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WatchChanges ป้อน event จาก MongoDB change stream ของ tbl_mission และ tbl_logs เข้า bus
+// ทำให้ได้รับ event ที่เกิดจาก instance อื่นด้วย (ต้องใช้ replica set) ทำงานจนกว่า ctx จะถูกยกเลิก
+func (b *Bus) WatchChanges(ctx context.Context, db *mongo.Database) {
+	go b.watch(ctx, db.Collection("tbl_mission"), bson.A{"insert"}, b.onMissionChange)
+	go b.watch(ctx, db.Collection("tbl_logs"), bson.A{"insert", "update", "replace"}, b.onLogChange)
+}
+
+type changeEvent struct {
+	OperationType     string   `bson:"operationType"`
+	FullDocument      bson.Raw `bson:"fullDocument"`
+	UpdateDescription struct {
+		UpdatedFields bson.M `bson:"updatedFields"`
+	} `bson:"updateDescription"`
+}
+
+// watch เปิด change stream ใหม่เมื่อหลุด โดยต่อจาก resume token ล่าสุด
+func (b *Bus) watch(ctx context.Context, collection *mongo.Collection, operations bson.A, handle func(changeEvent)) {
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": operations}}}},
+	}
+	var resumeToken bson.Raw
+
+	for ctx.Err() == nil {
+		opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+		if resumeToken != nil {
+			opts.SetResumeAfter(resumeToken)
+		}
+		stream, err := collection.Watch(ctx, pipeline, opts)
+		if err != nil {
+			log.Printf("Event bus: failed to watch %s: %v", collection.Name(), err)
+			sleepContext(ctx, time.Minute)
+			continue
+		}
+
+		for stream.Next(ctx) {
+			var change changeEvent
+			if err := stream.Decode(&change); err != nil {
+				log.Printf("Event bus: failed to decode %s change: %v", collection.Name(), err)
+				continue
+			}
+			handle(change)
+			resumeToken = stream.ResumeToken()
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("Event bus: %s change stream stopped: %v", collection.Name(), err)
+		}
+		stream.Close(context.Background())
+		sleepContext(ctx, 5*time.Second)
+	}
+}
+
+func (b *Bus) onMissionChange(change changeEvent) {
+	var mission models.Mission
+	if err := bson.Unmarshal(change.FullDocument, &mission); err != nil {
+		return
+	}
+	b.MissionCreated(tenant.With(context.Background(), mission.TenantID), mission)
+}
+
+func (b *Bus) onLogChange(change changeEvent) {
+	var entry models.Log
+	if change.FullDocument == nil || bson.Unmarshal(change.FullDocument, &entry) != nil {
+		return
+	}
+	ctx := tenant.With(context.Background(), entry.TenantID)
+
+	if change.OperationType == "insert" {
+		b.ClaimRequested(ctx, entry)
+		return
+	}
+	if _, ok := change.UpdateDescription.UpdatedFields["callback_time"]; ok || change.OperationType == "replace" {
+		if entry.Status == "approve" || entry.Status == "reject" {
+			b.CallbackReceived(ctx, entry)
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,155 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-server/tenant"
+)
+
+// ประเภทของ event ที่ส่งให้หน้า dashboard
+const (
+	MissionCreated   = "mission_created"
+	LevelPassed      = "level_passed"
+	LevelFailed      = "level_failed"
+	ClaimRequested   = "claim_requested"
+	CallbackReceived = "callback_received"
+	MessageFailed    = "message_failed"
+)
+
+const (
+	historySize     = 500              // event ล่าสุดที่เก็บไว้ให้ client ที่ต่อใหม่ขอย้อนหลังด้วย Last-Event-ID
+	subscriberQueue = 64               // event ที่ค้างได้ต่อ subscriber ก่อนเริ่มทิ้ง
+	dedupeWindow    = 10 * time.Minute // event ที่ key ซ้ำในช่วงนี้ถูกทิ้ง (publish ในโปรเซสกับ change stream ซ้ำกัน)
+)
+
+// Event เหตุการณ์ของระบบ Data เป็นค่าที่แปลงเป็น JSON ได้
+type Event struct {
+	ID       uint64      `json:"id"`
+	Type     string      `json:"type"`
+	TenantID string      `json:"tenant_id"`
+	Time     time.Time   `json:"time"`
+	Data     interface{} `json:"data"`
+}
+
+// Bus กระจาย event ภายในโปรเซสไปยัง subscriber ของ tenant เดียวกัน
+type Bus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	subscribers map[*Subscription]struct{}
+	history     []Event
+	seen        map[string]time.Time
+}
+
+func New() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+		seen:        make(map[string]time.Time),
+	}
+}
+
+// Default bus ที่ใช้ร่วมกันทั้งโปรเซส
+var Default = New()
+
+// Publish ส่ง event เข้า Default bus
+func Publish(ctx context.Context, eventType, key string, data interface{}) {
+	Default.Publish(ctx, eventType, key, data)
+}
+
+// Subscription ผู้รับ event ของ tenant หนึ่ง (อ่านจาก C จนกว่าจะ Unsubscribe)
+type Subscription struct {
+	C        <-chan Event
+	ch       chan Event
+	tenantID string
+	types    map[string]bool
+	dropped  int
+}
+
+func (s *Subscription) wants(event Event) bool {
+	if event.TenantID != s.tenantID {
+		return false
+	}
+	return len(s.types) == 0 || s.types[event.Type]
+}
+
+// Publish ส่ง event ของ tenant ใน ctx ให้ทุก subscriber ที่สนใจ
+// key ใช้กัน event ซ้ำ (เช่น "claim_requested:<log id>") ส่งค่าว่างถ้าไม่ต้องการ
+// subscriber ที่อ่านไม่ทันจะไม่ได้รับ event นั้น เพื่อไม่ให้ผู้ publish ต้องรอ
+func (b *Bus) Publish(ctx context.Context, eventType, key string, data interface{}) bool {
+	now := time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if key != "" {
+		if at, ok := b.seen[key]; ok && now.Sub(at) < dedupeWindow {
+			return false
+		}
+		b.seen[key] = now
+		if len(b.seen) > 4*historySize {
+			for k, at := range b.seen {
+				if now.Sub(at) >= dedupeWindow {
+					delete(b.seen, k)
+				}
+			}
+		}
+	}
+
+	b.nextID++
+	event := Event{
+		ID:       b.nextID,
+		Type:     eventType,
+		TenantID: tenant.FromContext(ctx),
+		Time:     now,
+		Data:     data,
+	}
+	if len(b.history) == historySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, event)
+
+	for sub := range b.subscribers {
+		if !sub.wants(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			sub.dropped++
+		}
+	}
+	return true
+}
+
+// Subscribe รับ event ของ tenantID เฉพาะประเภทใน types (ว่างคือทุกประเภท)
+// คืน event ในประวัติที่ ID มากกว่า lastID มาด้วย เพื่อให้ client ที่ต่อใหม่ไม่พลาด event
+func (b *Bus) Subscribe(tenantID string, types []string, lastID uint64) (*Subscription, []Event) {
+	ch := make(chan Event, subscriberQueue)
+	sub := &Subscription{C: ch, ch: ch, tenantID: tenantID, types: make(map[string]bool)}
+	for _, eventType := range types {
+		sub.types[eventType] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	if lastID > 0 {
+		for _, event := range b.history {
+			if event.ID > lastID && sub.wants(event) {
+				missed = append(missed, event)
+			}
+		}
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub, missed
+}
+
+// Unsubscribe เลิกรับ event และคืนจำนวน event ที่ถูกทิ้งเพราะอ่านไม่ทัน
+func (b *Bus) Unsubscribe(sub *Subscription) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, sub)
+	return sub.dropped
+}
@@ -0,0 +1,65 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+
+	"go-server/models"
+)
+
+// payload ของ event ที่มีได้ทั้งจาก controller และจาก change stream ต้องสร้างจากที่เดียวกัน
+// เพื่อให้ key ตรงกันและถูกตัดตัวซ้ำ
+
+// MissionCreated แจ้งว่ามีมิชชันใหม่
+func (b *Bus) MissionCreated(ctx context.Context, mission models.Mission) {
+	b.Publish(ctx, MissionCreated, MissionCreated+":"+mission.ID.Hex(), map[string]interface{}{
+		"mission_id":   mission.ID.Hex(),
+		"user_id":      mission.UserID,
+		"phone_number": mission.PhoneNumber,
+		"current_tier": mission.CurrentTier,
+		"created_at":   mission.CreatedAt,
+	})
+}
+
+// ClaimRequested แจ้งว่ามีการขอรับรางวัล (log ใหม่ใน tbl_logs)
+func (b *Bus) ClaimRequested(ctx context.Context, entry models.Log) {
+	b.Publish(ctx, ClaimRequested, ClaimRequested+":"+entry.ID.Hex(), claimData(entry))
+}
+
+// CallbackReceived แจ้งผลอนุมัติ/ปฏิเสธการขอรับรางวัล
+func (b *Bus) CallbackReceived(ctx context.Context, entry models.Log) {
+	b.Publish(ctx, CallbackReceived, fmt.Sprintf("%s:%s:%s", CallbackReceived, entry.ID.Hex(), entry.Status), claimData(entry))
+}
+
+// LevelOutcome แจ้งผลของ level (level_passed หรือ level_failed)
+func (b *Bus) LevelOutcome(ctx context.Context, mission models.Mission, tierNumber, levelNumber int, level models.Level, target int) {
+	eventType := LevelFailed
+	if level.Status == "success" {
+		eventType = LevelPassed
+	}
+	b.Publish(ctx, eventType, fmt.Sprintf("%s:%s:%d:%d", eventType, mission.ID.Hex(), tierNumber, levelNumber), map[string]interface{}{
+		"mission_id":     mission.ID.Hex(),
+		"user_id":        mission.UserID,
+		"tier":           tierNumber,
+		"level":          levelNumber,
+		"current_bet":    level.CurrentBet,
+		"target":         target,
+		"mission_status": mission.Status,
+	})
+}
+
+func claimData(entry models.Log) map[string]interface{} {
+	data := map[string]interface{}{
+		"log_id":     entry.ID.Hex(),
+		"user_id":    entry.UserID,
+		"mission_id": entry.MissionID,
+		"tier":       entry.Tier,
+		"reward":     entry.Reward,
+		"status":     entry.Status,
+		"created_at": entry.CreatedAt,
+	}
+	if !entry.CallbackTime.IsZero() {
+		data["callback_time"] = entry.CallbackTime
+	}
+	return data
+}
@@ -2,12 +2,19 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"go-server/config"
 	"go-server/controllers"
+	"go-server/eventbus"
 	"go-server/routes"
+	"go-server/tenant"
+	"go-server/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -19,7 +26,10 @@ func main() {
 		log.Println("No .env file found")
 	}
 
-	app := fiber.New()
+	// BodyLimit เผื่อไฟล์รูปขนาดใหญ่สุดที่ UploadImage รับได้
+	app := fiber.New(fiber.Config{
+		BodyLimit: utils.MaxImageUploadBytes + 1<<20,
+	})
 	app.Use(cors.New())
 
 	// เชื่อมต่อกับ MongoDB โดยใช้ฟังก์ชัน ConnectDB จาก package config
@@ -41,13 +51,39 @@ func main() {
 
 	// เลือกฐานข้อมูลและ collection
 	db := client.Database(os.Getenv("DB_NAME"))
+
+	// go run . rotate-config-key : เข้ารหัส secret ใน config ใหม่ด้วย CONFIG_MASTER_KEY ปัจจุบัน แล้วจบการทำงาน
+	if len(os.Args) > 1 && os.Args[1] == "rotate-config-key" {
+		rotated, err := utils.RotateConfigSecrets(ctx, db.Collection("tbl_config"), db.Collection("tbl_config_revisions"))
+		if err != nil {
+			log.Fatalf("Failed to rotate config key after %d documents: %v", rotated, err)
+		}
+		log.Printf("Re-encrypted secrets in %d config documents", rotated)
+		return
+	}
 	eventCollection := db.Collection("tbl_events")
 	missionCollection := db.Collection("tbl_mission")
 	configCollection := db.Collection("tbl_config")
 	messageCollection := db.Collection("tbl_logs_message")
 
+	// config ที่ cache ไว้ใช้ร่วมกันทุก controller และติดตามการเปลี่ยนแปลงจาก tbl_config
+	configService := config.NewService(configCollection, db.Collection("tbl_config_overlays"))
+
+	// go run . export-config | import-config : ย้าย config ระหว่าง environment แล้วจบการทำงาน
+	if len(os.Args) > 1 && (os.Args[1] == "export-config" || os.Args[1] == "import-config") {
+		configController := controllers.NewConfigController(configService, db.Collection("tbl_config_revisions"))
+		if err := runConfigBundleCommand(ctx, configController, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("%s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+	go configService.Watch(ctx, time.Minute)
+
+	// ทุก request ต้องรู้ tenant (LINE OA) ก่อนเข้าถึง config และข้อมูล
+	app.Use(routes.TenantMiddleware(configService))
+
 	// สร้าง LineController
-	lineController, err := controllers.NewLineController(configCollection, messageCollection)
+	lineController, err := controllers.NewLineController(configService, messageCollection)
 	if err != nil {
 		log.Fatal("Failed to create LINE controller:", err)
 	}
@@ -55,23 +91,70 @@ func main() {
 	expirationEventController := controllers.NewExpirationEventController(
 		eventCollection,
 		missionCollection,
-		configCollection,
+		configService,
 		lineController,
 	)
 
 	// Start background process for processing expiration events
 	go expirationEventController.ProcessEvents()
 
+	alertController := controllers.NewAlertController(
+		db.Collection("tbl_alerts"),
+		configService,
+		db.Collection("tbl_logs"),
+		eventCollection,
+		lineController,
+	)
+
+	// Start background process for admin alerts
+	go alertController.ProcessAlerts()
+
+	broadcastController := controllers.NewBroadcastController(
+		db.Collection("tbl_broadcasts"),
+		db.Collection("tbl_broadcast_recipients"),
+		db.Collection("tbl_client"),
+		missionCollection,
+		messageCollection,
+		lineController,
+	)
+
+	// Start background process for scheduled broadcasts
+	go broadcastController.ProcessBroadcasts()
+
+	statsController := controllers.NewStatsController(
+		db.Collection("tbl_daily_stats"),
+		missionCollection,
+		db.Collection("tbl_logs"),
+		messageCollection,
+		db.Collection("tbl_client"),
+		configService,
+	)
+
+	// Start background process for daily statistics rollup
+	go statsController.ProcessRollup()
+
+	// EVENTBUS_CHANGE_STREAMS=true รับ event จาก change stream ด้วย เมื่อรันหลาย instance (ต้องใช้ replica set)
+	if os.Getenv("EVENTBUS_CHANGE_STREAMS") == "true" {
+		eventbus.Default.WatchChanges(ctx, db)
+	}
+
 	// ตั้งค่า routes
-	routes.SetupGenericRoutes(app, db.Collection("tbl_users"), []string{"email", "createDate", "role", "status", "_id"}, []string{"created_at"})
-	routes.SetupGenericRoutes(app, db.Collection("tbl_mission"), []string{"user_id", "status", "created_at", "updated_at", "current_tier"}, []string{"created_at"})
-	routes.SetupGenericRoutes(app, db.Collection("tbl_logs_message"), []string{"user_id", "status", "sent_at"}, []string{"status", "sent_at"})
+	routes.SetupGenericRoutes(app, controllers.UserResource(db.Collection("tbl_users")))
+	routes.SetupGenericRoutes(app, controllers.MissionResource(missionCollection))
+	routes.SetupGenericRoutes(app, controllers.MessageResource(messageCollection))
+	routes.SetupGenericRoutes(app, controllers.EventResource(eventCollection))
 	routes.SetupAdminRoutes(app, db)
-	routes.SetupConfigRoutes(app, db)
-	routes.SetupClientRoutes(app, db)
-	routes.SetupMissionRoutes(app, db)
-	routes.SetupUserBetRoutes(app, db)
-	routes.SetupDashboardRoutes(app, db)
+	routes.SetupStorageRoutes(app)
+	routes.SetupConfigRoutes(app, db, configService)
+	routes.SetupClientRoutes(app, db, configService)
+	routes.SetupMissionRoutes(app, db, configService)
+	routes.SetupUserBetRoutes(app, db, configService)
+	routes.SetupDashboardRoutes(app, db, statsController)
+	routes.SetupMessageRoutes(app, db)
+	routes.SetupExportRoutes(app, db)
+	routes.SetupTelegramRoutes(app, db, configService)
+	routes.SetupAlertRoutes(app, alertController)
+	routes.SetupBroadcastRoutes(app, broadcastController)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -80,3 +163,73 @@ func main() {
 
 	log.Fatal(app.Listen(":" + port))
 }
+
+// runConfigBundleCommand ส่งออกหรือนำเข้า config bundle จาก command line
+// passphrase ของ secret อ่านจาก CONFIG_BUNDLE_PASSPHRASE
+//
+//	export-config [-tenant id] [-format json|yaml] [-images] [-out file]
+//	import-config [-tenant id] [-dry-run] [-base-revision n] [-author name] <file>
+func runConfigBundleCommand(ctx context.Context, configController *controllers.ConfigController, command string, args []string) error {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	tenantID := flags.String("tenant", tenant.Default, "tenant ID")
+	format := flags.String("format", "json", "bundle format (json or yaml)")
+	includeImages := flags.Bool("images", false, "include uploaded images in the bundle")
+	out := flags.String("out", "", "output file (default stdout)")
+	dryRun := flags.Bool("dry-run", false, "validate and show the diff without applying")
+	baseRevision := flags.Int("base-revision", 0, "expected current config revision")
+	author := flags.String("author", "cli", "author recorded in the config revision")
+	flags.Parse(args)
+
+	ctx = tenant.With(ctx, *tenantID)
+	passphrase := os.Getenv("CONFIG_BUNDLE_PASSPHRASE")
+
+	if command == "export-config" {
+		bundle, err := configController.ExportBundle(ctx, controllers.ConfigExportOptions{
+			Passphrase:    passphrase,
+			IncludeImages: *includeImages,
+		})
+		if err != nil {
+			return err
+		}
+		data, err := utils.MarshalConfigBundle(bundle, *format)
+		if err != nil {
+			return err
+		}
+		if *out == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		return os.WriteFile(*out, data, 0600)
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: import-config [flags] <file>")
+	}
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	bundle, err := utils.UnmarshalConfigBundle(data)
+	if err != nil {
+		return err
+	}
+	result, err := configController.ImportBundle(ctx, bundle, controllers.ConfigImportOptions{
+		Passphrase:   passphrase,
+		DryRun:       *dryRun,
+		BaseRevision: *baseRevision,
+		Author:       *author,
+	})
+	if err != nil {
+		return err
+	}
+
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(output))
+	if !result.Valid {
+		return fmt.Errorf("bundle has %d validation errors", len(result.Fields))
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AdminAction struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID  string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
+	Source    string             `bson:"source" json:"source"` // "telegram", "api"
+	ActorID   string             `bson:"actor_id" json:"actor_id"`
+	ActorName string             `bson:"actor_name" json:"actor_name"`
+	Action    string             `bson:"action" json:"action"` // "approve_reward", "reject_reward", "query_mission" etc.
+	Target    string             `bson:"target" json:"target"`
+	Result    string             `bson:"result" json:"result"` // "success", "denied", "error"
+	Detail    string             `bson:"detail,omitempty" json:"detail,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Alert struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID    string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
+	Rule        string             `bson:"rule" json:"rule"`
+	Status      string             `bson:"status" json:"status"` // "active" or "resolved"
+	Value       float64            `bson:"value" json:"value"`
+	Message     string             `bson:"message" json:"message"`
+	TriggeredAt time.Time          `bson:"triggered_at" json:"triggered_at"`
+	LastSentAt  time.Time          `bson:"last_sent_at" json:"last_sent_at"`
+	ResolvedAt  time.Time          `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Broadcast struct {
+	ID              primitive.ObjectID     `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID        string                 `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
+	Title           string                 `bson:"title" json:"title"`
+	FlexMessage     BaseFlexMessageContent `bson:"flex_message" json:"flexMessage"`
+	Segment         BroadcastSegment       `bson:"segment" json:"segment"`
+	Status          string                 `bson:"status" json:"status"` // "scheduled", "sending", "completed", "cancelled", "failed"
+	ScheduledAt     time.Time              `bson:"scheduled_at" json:"scheduled_at"`
+	StartedAt       time.Time              `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	CompletedAt     time.Time              `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	LeaseOwner      string                 `bson:"lease_owner,omitempty" json:"-"`                     // worker ที่กำลังส่ง
+	LeaseUntil      time.Time              `bson:"lease_until,omitempty" json:"lease_until,omitempty"` // worker ต่ออายุทุกชุด หมดอายุแล้ว worker อื่นรับไปส่งต่อ
+	TotalRecipients int                    `bson:"total_recipients" json:"total_recipients"`
+	SentCount       int                    `bson:"sent_count" json:"sent_count"`
+	FailedCount     int                    `bson:"failed_count" json:"failed_count"`
+	Error           string                 `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt       time.Time              `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time              `bson:"updated_at" json:"updated_at"`
+}
+
+type BroadcastSegment struct {
+	Type string `bson:"type" json:"type"` // "all", "tier", "awaiting_reward", "no_mission"
+	Tier int    `bson:"tier,omitempty" json:"tier,omitempty"`
+}
+
+type BroadcastRecipient struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	BroadcastID primitive.ObjectID `bson:"broadcast_id" json:"broadcast_id"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	Status      string             `bson:"status" json:"status"` // "pending", "sent", "failed", "cancelled"
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	SentAt      time.Time          `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+}
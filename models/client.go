@@ -6,6 +6,7 @@ import (
 
 type Client struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID      string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
 	UserID        string             `bson:"user_id" json:"userId"`
 	DisplayName   string             `bson:"display_name" json:"displayName"`
 	PictureURL    string             `bson:"picture_url" json:"pictureUrl"`
@@ -6,19 +6,28 @@ import (
 
 type Config struct {
 	ID                 primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID           string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"` // ว่าง = tenant "default"
+	Hosts              []string           `bson:"hosts,omitempty" json:"hosts,omitempty"`         // domain ของหน้าเว็บ/LIFF ที่ใช้ระบุ tenant
+	Revision           int                `bson:"revision" json:"revision"`
 	LiffID             string             `bson:"liff_id" json:"liff_id"`
 	ChannelAccessToken string             `bson:"channel_access_token" json:"channel_access_token"`
 	ChannelSecret      string             `bson:"channel_secret" json:"channel_secret"`
 	Tiers              []TierDetail       `bson:"tiers" json:"tiers"`
 	TelegramBotToken   string             `bson:"telegram_bot_token" json:"telegram_bot_token"`
 	TelegramChatID     string             `bson:"telegram_chat_id" json:"telegram_chat_id"`
+	TelegramAdminIDs   []string           `bson:"telegram_admin_ids" json:"telegram_admin_ids"` // Telegram user ID ที่อนุมัติรางวัลได้
+	TelegramSecret     string             `bson:"telegram_secret" json:"telegram_secret"`       // secret token ของ webhook
 	FirebaseConfig     FirebaseConfig     `bson:"firebase_config" json:"firebase_config"`
+	Storage            StorageConfig      `bson:"storage" json:"storage"`
 	FlexMessages       FlexMessages       `bson:"flex_messages" json:"flexMessages"`
 	SiteTemplate       SiteTemplateConfig `bson:"site_template" json:"siteTemplate"`
 	ApiEndpoint        string             `bson:"api_endpoint" json:"api_endpoint"`
 	ApiKey             string             `bson:"api_key" json:"api_key"`
 	LineAt             string             `bson:"line_at" json:"line_at"`
 	LineSyncURL        string             `bson:"line_sync_url" json:"line_sync_url"`
+	Notification       NotificationConfig `bson:"notification" json:"notification"`
+	Alerts             AlertConfig        `bson:"alerts" json:"alerts"`
+	ActiveOverlay      *ConfigOverlay     `bson:"-" json:"active_overlay,omitempty"` // overlay ที่ใช้อยู่ (ดู config.Service.Effective)
 }
 
 type FirebaseConfig struct {
@@ -26,6 +35,29 @@ type FirebaseConfig struct {
 	BucketName string `bson:"bucket_name" json:"bucketName"`
 }
 
+// StorageConfig เลือกที่เก็บไฟล์ที่อัปโหลด: "gcs" (ค่าเริ่มต้น ใช้ FirebaseConfig), "s3" หรือ "local"
+type StorageConfig struct {
+	Backend string             `bson:"backend" json:"backend"`
+	S3      S3StorageConfig    `bson:"s3" json:"s3"`
+	Local   LocalStorageConfig `bson:"local" json:"local"`
+}
+
+// S3StorageConfig สำหรับ AWS S3 หรือบริการที่ใช้ API เดียวกัน (MinIO, Cloudflare R2, ...)
+type S3StorageConfig struct {
+	Endpoint        string `bson:"endpoint" json:"endpoint"` // เช่น https://s3.ap-southeast-1.amazonaws.com
+	Region          string `bson:"region" json:"region"`
+	Bucket          string `bson:"bucket" json:"bucket"`
+	AccessKeyID     string `bson:"access_key_id" json:"access_key_id"`
+	SecretAccessKey string `bson:"secret_access_key" json:"secret_access_key"`
+	ACL             string `bson:"acl" json:"acl"`               // เช่น public-read ว่าง = ไม่ส่ง ACL
+	PublicURL       string `bson:"public_url" json:"public_url"` // URL สาธารณะของ bucket (CDN) ว่าง = ใช้ endpoint/bucket
+}
+
+// LocalStorageConfig เก็บไฟล์ในเครื่อง (LOCAL_STORAGE_DIR) ให้บริการผ่าน /uploads ใช้ตอนพัฒนา
+type LocalStorageConfig struct {
+	PublicURL string `bson:"public_url" json:"public_url"` // base URL ของ server เช่น http://localhost:8000
+}
+
 type TierDetail struct {
 	Name                string `bson:"name" json:"name"`
 	Period              int    `bson:"period" json:"period"`                             // หน่วยเป็นชั่วโมง
@@ -41,6 +73,27 @@ type TierDetail struct {
 
 }
 
+type NotificationConfig struct {
+	Timezone          string `bson:"timezone" json:"timezone"` // เช่น "Asia/Bangkok"
+	QuietHoursEnabled bool   `bson:"quiet_hours_enabled" json:"quiet_hours_enabled"`
+	QuietHoursStart   int    `bson:"quiet_hours_start" json:"quiet_hours_start"` // ชั่วโมง 0-23
+	QuietHoursEnd     int    `bson:"quiet_hours_end" json:"quiet_hours_end"`     // ชั่วโมง 0-23
+	DailyMessageCap   int    `bson:"daily_message_cap" json:"daily_message_cap"` // 0 = ไม่จำกัด
+}
+
+// AlertConfig เกณฑ์การแจ้งเตือน admin ผ่าน Telegram ค่า 0 = ปิด rule นั้น
+type AlertConfig struct {
+	Enabled                bool `bson:"enabled" json:"enabled"`
+	CheckIntervalMinutes   int  `bson:"check_interval_minutes" json:"check_interval_minutes"`
+	RepeatAfterHours       int  `bson:"repeat_after_hours" json:"repeat_after_hours"` // ส่งซ้ำถ้าปัญหายังไม่หาย
+	PendingClaimHours      int  `bson:"pending_claim_hours" json:"pending_claim_hours"`
+	RewardExpiringHours    int  `bson:"reward_expiring_hours" json:"reward_expiring_hours"`
+	DeadLetterThreshold    int  `bson:"dead_letter_threshold" json:"dead_letter_threshold"`
+	BetApiErrorRatePercent int  `bson:"bet_api_error_rate_percent" json:"bet_api_error_rate_percent"`
+	BetApiMinRequests      int  `bson:"bet_api_min_requests" json:"bet_api_min_requests"`
+	LineQuotaPercent       int  `bson:"line_quota_percent" json:"line_quota_percent"`
+}
+
 type FlexMessages struct {
 	Followup           BaseFlexMessageContent `bson:"followup" json:"followup"`
 	MissionSuccess     BaseFlexMessageContent `bson:"mission_success" json:"missionSuccess"`
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConfigOverlay การปรับ config ชั่วคราวตามช่วงเวลา เช่น โปรโมชันรางวัลสองเท่าช่วงสุดสัปดาห์
+// มีผลเฉพาะระหว่าง StartsAt ถึง EndsAt และแต่ละ tenant มี overlay ที่ช่วงเวลาทับกันไม่ได้
+type ConfigOverlay struct {
+	ID           primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID     string               `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
+	Name         string               `bson:"name" json:"name"`
+	Description  string               `bson:"description,omitempty" json:"description,omitempty"`
+	StartsAt     time.Time            `bson:"starts_at" json:"starts_at"`
+	EndsAt       time.Time            `bson:"ends_at" json:"ends_at"`
+	Tiers        []TierOverride       `bson:"tiers,omitempty" json:"tiers,omitempty"`
+	FlexMessages FlexMessagesOverride `bson:"flex_messages" json:"flexMessages"`
+	Status       string               `bson:"status" json:"status"` // "active" or "cancelled"
+	CreatedBy    string               `bson:"created_by" json:"created_by"`
+	CreatedAt    time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+// TierOverride ค่าใน TierDetail ที่จะใช้แทนระหว่างช่วง overlay (nil = ใช้ค่าเดิม)
+type TierOverride struct {
+	Tier                int  `bson:"tier" json:"tier"` // เริ่มจาก 1
+	Target              *int `bson:"target,omitempty" json:"target,omitempty"`
+	Reward              *int `bson:"reward,omitempty" json:"reward,omitempty"`
+	Period              *int `bson:"period,omitempty" json:"period,omitempty"`                   // หน่วยเป็นชั่วโมง
+	FollowUpHours       *int `bson:"follow_up_hours,omitempty" json:"follow_up_hours,omitempty"` // หน่วยเป็นชั่วโมง
+	ExpireRewardHours   *int `bson:"expire_reward_hours,omitempty" json:"expire_reward_hours,omitempty"`
+	MaxConsecutiveFails *int `bson:"max_consecutive_fails,omitempty" json:"max_consecutive_fails,omitempty"`
+}
+
+// FlexMessagesOverride ข้อความที่จะใช้แทนระหว่างช่วง overlay (nil = ใช้ข้อความเดิม)
+type FlexMessagesOverride struct {
+	Followup           *BaseFlexMessageContent `bson:"followup,omitempty" json:"followup,omitempty"`
+	MissionSuccess     *BaseFlexMessageContent `bson:"mission_success,omitempty" json:"missionSuccess,omitempty"`
+	MissionFailed      *BaseFlexMessageContent `bson:"mission_failed,omitempty" json:"missionFailed,omitempty"`
+	MissionComplete    *BaseFlexMessageContent `bson:"mission_complete,omitempty" json:"missionComplete,omitempty"`
+	GetReward          *BaseFlexMessageContent `bson:"get_reward,omitempty" json:"getReward,omitempty"`
+	RewardNotification *BaseFlexMessageContent `bson:"reward_notification,omitempty" json:"rewardNotification,omitempty"`
+}
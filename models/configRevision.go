@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConfigRevision เก็บ snapshot ของ config ทุกครั้งที่บันทึก (ไม่มีการแก้ไขภายหลัง)
+type ConfigRevision struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID       string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
+	Revision       int                `bson:"revision" json:"revision"`
+	Action         string             `bson:"action" json:"action"` // "save", "tiers", "flex_messages", "site_template", "rollback" etc.
+	Author         string             `bson:"author" json:"author"`
+	Changes        []ConfigChange     `bson:"changes" json:"changes"`
+	RolledBackFrom int                `bson:"rolled_back_from,omitempty" json:"rolled_back_from,omitempty"`
+	Config         *Config            `bson:"config,omitempty" json:"config,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type ConfigChange struct {
+	Path string      `bson:"path" json:"path"`
+	Old  interface{} `bson:"old" json:"old"`
+	New  interface{} `bson:"new" json:"new"`
+}
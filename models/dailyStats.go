@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DailyStats สถิติรายวันของ tenant (tbl_daily_stats) ดูแลโดย StatsController
+// Missions นับจากมิชชันที่เริ่มในวันนั้นตามสถานะปัจจุบัน จึงรวมทุกวันได้เป็นยอดทั้งหมด
+// Claims, Messages และ NewClients นับตามเหตุการณ์ที่เกิดขึ้นในวันนั้น
+type DailyStats struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID   string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
+	Date       string             `bson:"date" json:"date"` // YYYY-MM-DD ตามเวลาไทย
+	Day        time.Time          `bson:"day" json:"day"`   // เวลาเริ่มวัน (00:00 Asia/Bangkok)
+	Missions   MissionDailyStats  `bson:"missions" json:"missions"`
+	Claims     ClaimDailyStats    `bson:"claims" json:"claims"`
+	Messages   MessageDailyStats  `bson:"messages" json:"messages"`
+	NewClients int                `bson:"new_clients" json:"new_clients"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type MissionDailyStats struct {
+	Started  int              `bson:"started" json:"started"`
+	ByStatus map[string]int   `bson:"by_status" json:"by_status"`
+	Tiers    []TierDailyStats `bson:"tiers" json:"tiers"`
+}
+
+// TierDailyStats สถานะของ tier ในมิชชันที่ไปถึง tier นั้น
+type TierDailyStats struct {
+	Tier          int               `bson:"tier" json:"tier"`
+	Name          string            `bson:"name" json:"name"`
+	Reached       int               `bson:"reached" json:"reached"`
+	ByStatus      map[string]int    `bson:"by_status" json:"by_status"`
+	CurrentLevels map[string]int    `bson:"current_levels" json:"current_levels"` // current_level -> จำนวน
+	Levels        []LevelDailyStats `bson:"levels" json:"levels"`
+}
+
+type LevelDailyStats struct {
+	Level    int            `bson:"level" json:"level"`
+	ByStatus map[string]int `bson:"by_status" json:"by_status"`
+}
+
+type ClaimDailyStats struct {
+	Requested       int     `bson:"requested" json:"requested"`
+	Approved        int     `bson:"approved" json:"approved"`
+	Rejected        int     `bson:"rejected" json:"rejected"`
+	RequestedAmount float64 `bson:"requested_amount" json:"requested_amount"`
+	ApprovedAmount  float64 `bson:"approved_amount" json:"approved_amount"`
+}
+
+type MessageDailyStats struct {
+	Sent int `bson:"sent" json:"sent"`
+	Read int `bson:"read" json:"read"`
+}
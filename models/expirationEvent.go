@@ -7,11 +7,16 @@ import (
 )
 
 type ExpirationEvent struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	MissionID  primitive.ObjectID `bson:"mission_id" json:"mission_id"`
-	TierIndex  int                `bson:"tier_index" json:"tier_index"`
-	LevelIndex int                `bson:"level_index" json:"level_index"`
-	ExpireTime time.Time          `bson:"expire_time" json:"expire_time"`
-	Status     string             `bson:"status" json:"status"` // "pending" or "processed"
-	Type       string             `bson:"type" json:"type"`     // "level_expiration", "follow_up", or "reward_expiration"
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID    string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
+	MissionID   primitive.ObjectID `bson:"mission_id" json:"mission_id"`
+	TierIndex   int                `bson:"tier_index" json:"tier_index"`
+	LevelIndex  int                `bson:"level_index" json:"level_index"`
+	ExpireTime  time.Time          `bson:"expire_time" json:"expire_time"`
+	Status      string             `bson:"status" json:"status"` // "pending", "processed" or "failed"
+	Type        string             `bson:"type" json:"type"`     // "level_expiration", "follow_up", or "reward_expiration"
+	DeferCount  int                `bson:"defer_count,omitempty" json:"defer_count,omitempty"`
+	Attempts    int                `bson:"attempts,omitempty" json:"attempts,omitempty"` // จำนวนครั้งที่ประมวลผลไม่สำเร็จ
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	ProcessedAt time.Time          `bson:"processed_at,omitempty" json:"processed_at,omitempty"` // เวลาที่ ProcessEvents หยิบไปทำงาน
 }
@@ -8,9 +8,11 @@ import (
 
 type Log struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID      string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
 	UserID        string             `bson:"user_id" json:"user_id"`
 	MissionID     string             `bson:"mission_id" json:"mission_id"`
 	MissionDetail string             `bson:"mission_detail" json:"mission_detail"`
+	Tier          int                `bson:"tier,omitempty" json:"tier,omitempty"` // tier ที่ขอรับรางวัล (log เก่าดูจาก mission_detail)
 	Reward        float64            `bson:"reward" json:"reward"`
 	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
 	CallbackTime  time.Time          `bson:"callback_time,omitempty" json:"callback_time,omitempty"`
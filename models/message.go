@@ -8,13 +8,16 @@ import (
 
 type MessageLog struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID    string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
 	UserID      string             `bson:"user_id" json:"user_id"`
 	Status      string             `bson:"status" json:"status"` // "sent", "read", "unread" etc.
 	Tier        string             `bson:"tier" json:"tier"`
 	Level       string             `bson:"level" json:"level"`
-	MissionID   primitive.ObjectID `bson:"mission_id" json:"mission_id"`
+	MissionID   primitive.ObjectID `bson:"mission_id,omitempty" json:"mission_id"` // ไม่มีในข้อความจาก broadcast
+	BroadcastID primitive.ObjectID `bson:"broadcast_id,omitempty" json:"broadcast_id,omitempty"`
 	SentAt      time.Time          `bson:"sent_at" json:"sent_at"`
 	ReadAt      time.Time          `bson:"read_at,omitempty" json:"read_at,omitempty"`
+	DeletedAt   time.Time          `bson:"deleted_at,omitempty" json:"-"`
 	FlexContent FlexContent        `bson:"flex_content" json:"flex_content"`
 }
 
@@ -8,12 +8,15 @@ import (
 
 type Mission struct {
 	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TenantID         string             `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
 	UserID           string             `bson:"user_id" json:"user_id"`
 	PhoneNumber      string             `bson:"phone_number" json:"phone_number"`
 	Status           string             `bson:"status" json:"status"` // "processing", "completed", "failed", "pending"
 	CurrentTier      int                `bson:"current_tier" json:"current_tier"`
 	Tiers            []Tier             `bson:"tiers" json:"tiers"`
 	ConsecutiveFails int                `bson:"consecutive_fails" json:"consecutive_fails"`
+	ConfigRevision   int                `bson:"config_revision" json:"config_revision"` // revision ของ config ตอนเริ่มมิชชัน
+	TierRules        []TierDetail       `bson:"tier_rules" json:"tier_rules"`           // กติกาของทุก tier ณ ตอนเริ่มมิชชัน
 	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt        time.Time          `bson:"updated_at" json:"updated_at"`
 }
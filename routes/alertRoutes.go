@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"go-server/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupAlertRoutes ใช้ controller ตัวเดียวกับที่รัน ProcessAlerts อยู่ใน main
+func SetupAlertRoutes(app *fiber.App, alertController *controllers.AlertController) {
+	alertGroup := app.Group("/api/alerts")
+	alertGroup.Get("/", alertController.GetAlerts)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"go-server/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupBroadcastRoutes ใช้ controller ตัวเดียวกับที่รัน ProcessBroadcasts อยู่ใน main
+func SetupBroadcastRoutes(app *fiber.App, broadcastController *controllers.BroadcastController) {
+	broadcastGroup := app.Group("/api/broadcasts")
+	broadcastGroup.Post("/", broadcastController.CreateBroadcast)
+	broadcastGroup.Get("/", broadcastController.GetBroadcasts)
+	broadcastGroup.Get("/segment-count", broadcastController.GetSegmentCount)
+	broadcastGroup.Get("/:id", broadcastController.GetBroadcast)
+	broadcastGroup.Get("/:id/recipients", broadcastController.GetBroadcastRecipients)
+	broadcastGroup.Post("/:id/cancel", broadcastController.CancelBroadcast)
+}
@@ -1,16 +1,23 @@
 package routes
 
 import (
+	"go-server/config"
 	"go-server/controllers"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SetupClientRoutes(app *fiber.App, db *mongo.Database) {
+func SetupClientRoutes(app *fiber.App, db *mongo.Database, configService *config.Service) {
 	clientCollection := db.Collection("tbl_client")
-	configCollection := db.Collection("tbl_config")
-	clientController := controllers.NewClientController(clientCollection, configCollection)
+	clientController := controllers.NewClientController(clientCollection, configService)
+	timelineController := controllers.NewClientTimelineController(
+		db.Collection("tbl_mission"),
+		db.Collection("tbl_events"),
+		db.Collection("tbl_logs_message"),
+		db.Collection("tbl_logs"),
+		configService,
+	)
 
 	clientGroup := app.Group("/api/clients")
 	clientGroup.Get("/", clientController.GetAllClients)
@@ -19,4 +26,5 @@ func SetupClientRoutes(app *fiber.App, db *mongo.Database) {
 	clientGroup.Delete("/:userId", clientController.DeleteClient)
 	clientGroup.Get("/:userId/check-phone", clientController.CheckPhoneNumber)
 	clientGroup.Put("/:userId/update-phone", clientController.UpdatePhoneNumber)
+	clientGroup.Get("/:userId/timeline", timelineController.GetTimeline)
 }
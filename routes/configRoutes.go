@@ -1,21 +1,38 @@
 package routes
 
 import (
+	"go-server/config"
 	"go-server/controllers"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SetupConfigRoutes(app *fiber.App, db *mongo.Database) {
-	configCollection := db.Collection("tbl_config")
-	configController := controllers.NewConfigController(configCollection)
+func SetupConfigRoutes(app *fiber.App, db *mongo.Database, configService *config.Service) {
+	configController := controllers.NewConfigController(configService, db.Collection("tbl_config_revisions"))
 
 	configRoutes := app.Group("/api/config")
 	configRoutes.Get("/", configController.GetConfig)
 	configRoutes.Post("/", configController.SaveConfig)
+	configRoutes.Post("/validate", configController.ValidateConfig)
+	configRoutes.Post("/export", configController.ExportConfig)
+	configRoutes.Post("/import", configController.ImportConfig)
 	configRoutes.Put("/tiers", configController.UpdateTierSettings)
 	configRoutes.Put("/flex-messages", configController.UpdateFlexMessageSettings)
 	configRoutes.Put("/site-template", configController.UpdateSiteTemplateConfig)
+	configRoutes.Put("/notification", configController.UpdateNotificationSettings)
+	configRoutes.Put("/alerts", configController.UpdateAlertSettings)
 	configRoutes.Post("/upload-image", configController.UploadImage)
+	configRoutes.Get("/revisions", configController.GetRevisions)
+	configRoutes.Get("/revisions/diff", configController.DiffRevisions)
+	configRoutes.Get("/revisions/:revision", configController.GetRevision)
+	configRoutes.Post("/revisions/:revision/rollback", configController.RollbackRevision)
+
+	overlayController := controllers.NewConfigOverlayController(db.Collection("tbl_config_overlays"), configService)
+	configRoutes.Get("/effective", overlayController.GetEffectiveConfig)
+	configRoutes.Get("/overlays", overlayController.GetOverlays)
+	configRoutes.Post("/overlays", overlayController.CreateOverlay)
+	configRoutes.Get("/overlays/:id", overlayController.GetOverlay)
+	configRoutes.Put("/overlays/:id", overlayController.UpdateOverlay)
+	configRoutes.Delete("/overlays/:id", overlayController.CancelOverlay)
 }
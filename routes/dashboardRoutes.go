@@ -3,18 +3,21 @@ package routes
 
 import (
 	"go-server/controllers"
+	"go-server/eventbus"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SetupDashboardRoutes(app *fiber.App, db *mongo.Database) {
+func SetupDashboardRoutes(app *fiber.App, db *mongo.Database, statsController *controllers.StatsController) {
 	dashboardController := controllers.NewDashboardController(
 		db.Collection("tbl_mission"),
 		db.Collection("tbl_client"),
 		db.Collection("tbl_user_bet"),
 		db.Collection("tbl_logs"),
+		statsController,
 	)
+	eventStreamController := controllers.NewEventStreamController(eventbus.Default)
 
 	dashboardGroup := app.Group("/api/dashboard")
 	dashboardGroup.Get("/", dashboardController.GetDashboardData)
@@ -23,4 +26,10 @@ func SetupDashboardRoutes(app *fiber.App, db *mongo.Database) {
 	dashboardGroup.Get("/urgent-alerts", dashboardController.GetUrgentAlertsData)
 	dashboardGroup.Get("/recent-activities", dashboardController.GetRecentActivitiesData)
 	dashboardGroup.Get("/pending-rewards", dashboardController.GetPendingRewards)
+	dashboardGroup.Get("/timeseries", dashboardController.GetTimeSeries)
+	dashboardGroup.Get("/funnel", dashboardController.GetMissionFunnel)
+	dashboardGroup.Get("/cohorts", dashboardController.GetCohortReport)
+	dashboardGroup.Get("/daily-stats", statsController.GetDailyStats)
+	dashboardGroup.Post("/daily-stats/rebuild", statsController.RebuildStats)
+	dashboardGroup.Get("/events", eventStreamController.StreamEvents)
 }
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"go-server/controllers"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func SetupExportRoutes(app *fiber.App, db *mongo.Database) {
+	exportController := controllers.NewExportController(
+		db.Collection("tbl_mission"),
+		db.Collection("tbl_client"),
+		db.Collection("tbl_logs"),
+		db.Collection("tbl_logs_message"),
+	)
+
+	exportGroup := app.Group("/api/exports")
+	exportGroup.Get("/missions", exportController.ExportMissions)
+	exportGroup.Get("/clients", exportController.ExportClients)
+	exportGroup.Get("/claims", exportController.ExportClaims)
+	exportGroup.Get("/messages", exportController.ExportMessages)
+}
@@ -4,13 +4,12 @@ import (
 	"go-server/controllers"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SetupGenericRoutes(app *fiber.App, collection *mongo.Collection, searchFields, sortFields []string) {
-	controller := controllers.NewGenericController(collection, searchFields, sortFields)
+func SetupGenericRoutes[T any](app *fiber.App, resource controllers.Resource[T]) {
+	controller := controllers.NewGenericController(resource)
 
-	group := app.Group("/api/" + collection.Name())
+	group := app.Group("/api/" + resource.Collection.Name())
 	group.Post("/", controller.Create)
 	group.Get("/", controller.GetAll)
 	group.Get("/search", controller.Search)
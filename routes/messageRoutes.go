@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"go-server/controllers"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func SetupMessageRoutes(app *fiber.App, db *mongo.Database) {
+	messageController := controllers.NewMessageController(db.Collection("tbl_logs_message"))
+
+	messageGroup := app.Group("/api/messages/:userId")
+	messageGroup.Get("/", messageController.GetMessages)
+	messageGroup.Get("/unread-count", messageController.GetUnreadCount)
+	messageGroup.Put("/read-all", messageController.MarkAllAsRead)
+	messageGroup.Put("/:id/read", messageController.MarkAsRead)
+	messageGroup.Delete("/:id", messageController.DeleteMessage)
+}
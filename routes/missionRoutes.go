@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"go-server/config"
 	"go-server/controllers"
 	"log"
 
@@ -8,21 +9,20 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SetupMissionRoutes(app *fiber.App, db *mongo.Database) {
+func SetupMissionRoutes(app *fiber.App, db *mongo.Database, configService *config.Service) {
 	missionCollection := db.Collection("tbl_mission")
-	configCollection := db.Collection("tbl_config")
 	eventCollection := db.Collection("tbl_events")
 	logCollection := db.Collection("tbl_logs")
 	messageCollection := db.Collection("tbl_logs_message")
 
 	// สร้าง LineController
-	lineController, err := controllers.NewLineController(configCollection, messageCollection)
+	lineController, err := controllers.NewLineController(configService, messageCollection)
 	if err != nil {
 		log.Fatal("Failed to create LINE controller:", err)
 	}
 
-	missionController := controllers.NewMissionController(missionCollection, configCollection, eventCollection, logCollection, lineController)
-	rewardCallbackController := controllers.NewRewardCallbackController(missionCollection, logCollection, configCollection, eventCollection, lineController)
+	missionController := controllers.NewMissionController(missionCollection, configService, eventCollection, logCollection, lineController)
+	rewardCallbackController := controllers.NewRewardCallbackController(missionCollection, logCollection, configService, eventCollection, lineController)
 
 	missionRoutes := app.Group("/api/missions")
 	missionRoutes.Post("/", missionController.CreateMission)
@@ -30,6 +30,9 @@ func SetupMissionRoutes(app *fiber.App, db *mongo.Database) {
 	missionRoutes.Get("/processing", missionController.GetProcessingMission)
 	missionRoutes.Post("/:id/claim-reward", missionController.ClaimReward)
 
+	// ย้ายมิชชันที่กำลังทำอยู่ไปใช้กติกา tier ปัจจุบัน (admin)
+	missionRoutes.Post("/migrate-tier-rules", missionController.MigrateTierRules)
+
 	// เช็คว่าเคยกดรับหรือยัง
 	missionRoutes.Get("/check", missionController.CheckExistingMission)
 
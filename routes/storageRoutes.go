@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"go-server/blobstore"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupStorageRoutes ให้บริการไฟล์ที่อัปโหลดไว้กับ local blob store (storage.backend = "local")
+func SetupStorageRoutes(app *fiber.App) {
+	app.Static(blobstore.LocalRoute, blobstore.LocalDir(), fiber.Static{
+		MaxAge: 86400,
+	})
+}
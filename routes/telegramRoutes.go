@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"context"
+	"go-server/config"
+	"go-server/controllers"
+	"go-server/tenant"
+	"log"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func SetupTelegramRoutes(app *fiber.App, db *mongo.Database, configService *config.Service) {
+	missionCollection := db.Collection("tbl_mission")
+	eventCollection := db.Collection("tbl_events")
+	logCollection := db.Collection("tbl_logs")
+	messageCollection := db.Collection("tbl_logs_message")
+
+	lineController, err := controllers.NewLineController(configService, messageCollection)
+	if err != nil {
+		log.Fatal("Failed to create LINE controller:", err)
+	}
+
+	rewardCallbackController := controllers.NewRewardCallbackController(missionCollection, logCollection, configService, eventCollection, lineController)
+	telegramBotController := controllers.NewTelegramBotController(
+		controllers.NewTelegramController(configService),
+		rewardCallbackController,
+		missionCollection,
+		logCollection,
+		db.Collection("tbl_logs_admin_action"),
+	)
+
+	telegramGroup := app.Group("/api/telegram")
+	telegramGroup.Post("/webhook", telegramBotController.HandleWebhook)
+	telegramGroup.Post("/set-webhook", telegramBotController.SetWebhook)
+
+	// ใช้ long-poll แทน webhook เมื่อ TELEGRAM_UPDATE_MODE=polling (เช่น ตอนพัฒนาบนเครื่อง)
+	// แต่ละ tenant มีบอทของตัวเอง จึงต้อง poll แยกกัน
+	if os.Getenv("TELEGRAM_UPDATE_MODE") == "polling" {
+		tenants, err := configService.Tenants(context.Background())
+		if err != nil {
+			log.Fatal("Failed to list tenants:", err)
+		}
+		for _, tenantID := range tenants {
+			go telegramBotController.StartPolling(tenant.With(context.Background(), tenantID))
+		}
+	}
+}
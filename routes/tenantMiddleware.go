@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"go-server/config"
+	"go-server/tenant"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TenantMiddleware กำหนด tenant ของ request ลงใน UserContext ให้ controller ใช้ต่อ
+// ลำดับการหา tenant: X-Tenant-ID หรือ ?tenant_id= → X-Liff-ID หรือ ?liff_id= → host ของ request → tenant.Default
+// tenant ที่ระบุมาตรง ๆ ต้องมี config อยู่แล้ว ยกเว้น request ที่สร้าง config ของ tenant ใหม่ (ดู createsTenant)
+func TenantMiddleware(configService *config.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tenantID := c.Get("X-Tenant-ID", c.Query("tenant_id"))
+		if tenantID == "" {
+			liffID := c.Get("X-Liff-ID", c.Query("liff_id"))
+			resolved, err := configService.ResolveTenant(c.UserContext(), liffID, c.Hostname())
+			if err != nil {
+				log.Printf("TenantMiddleware: Failed to resolve tenant: %v", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to resolve tenant"})
+			}
+			tenantID = resolved
+		} else if tenantID != tenant.Default && !createsTenant(c) {
+			exists, err := configService.HasTenant(c.UserContext(), tenantID)
+			if err != nil {
+				log.Printf("TenantMiddleware: Failed to check tenant %s: %v", tenantID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to resolve tenant"})
+			}
+			if !exists {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown tenant"})
+			}
+		}
+
+		c.SetUserContext(tenant.With(c.UserContext(), tenantID))
+		c.Locals("tenant_id", tenantID)
+		return c.Next()
+	}
+}
+
+// createsTenant คือ request ที่บันทึก config ทั้งก้อน ซึ่งเป็นวิธีสร้าง tenant ใหม่ (upsert ใน tbl_config)
+func createsTenant(c *fiber.Ctx) bool {
+	if c.Method() != fiber.MethodPost {
+		return false
+	}
+	switch c.Path() {
+	case "/api/config", "/api/config/", "/api/config/import":
+		return true
+	}
+	return false
+}
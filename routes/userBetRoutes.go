@@ -1,16 +1,16 @@
 package routes
 
 import (
+	"go-server/config"
 	"go-server/controllers"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SetupUserBetRoutes(app *fiber.App, db *mongo.Database) {
+func SetupUserBetRoutes(app *fiber.App, db *mongo.Database, configService *config.Service) {
 	collection := db.Collection("user_bets")
-	configCollection := db.Collection("tbl_config")
-	controller := controllers.NewUserBetController(collection, configCollection)
+	controller := controllers.NewUserBetController(collection, configService)
 
 	userBetRoutes := app.Group("/api/user-bet")
 	userBetRoutes.Get("/", controller.GetCurrentBet)
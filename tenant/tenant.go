@@ -0,0 +1,59 @@
+package tenant
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Default คือ tenant ของเอกสารเดิมที่สร้างก่อนมีระบบ multi-tenant (ไม่มี field tenant_id)
+const Default = "default"
+
+type contextKey struct{}
+
+// With ผูก tenant เข้ากับ context เพื่อให้ config, LINE bot และ query ทำงานกับ tenant นั้น
+func With(ctx context.Context, tenantID string) context.Context {
+	if tenantID == "" {
+		tenantID = Default
+	}
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext คืน tenant ของ context (ถ้าไม่ได้ระบุจะเป็น Default)
+func FromContext(ctx context.Context) string {
+	if ctx != nil {
+		if tenantID, ok := ctx.Value(contextKey{}).(string); ok && tenantID != "" {
+			return tenantID
+		}
+	}
+	return Default
+}
+
+// Filter เงื่อนไขเลือกเอกสารของ tenant โดย Default รวมเอกสารเก่าที่ไม่มี tenant_id ด้วย
+func Filter(tenantID string) bson.M {
+	if tenantID == "" || tenantID == Default {
+		return bson.M{"tenant_id": bson.M{"$in": bson.A{Default, "", nil}}}
+	}
+	return bson.M{"tenant_id": tenantID}
+}
+
+// Scope คืน filter ใหม่ที่จำกัดเฉพาะ tenant ของ ctx
+func Scope(ctx context.Context, filter bson.M) bson.M {
+	scoped := bson.M{}
+	for key, value := range filter {
+		scoped[key] = value
+	}
+	for key, value := range Filter(FromContext(ctx)) {
+		scoped[key] = value
+	}
+	return scoped
+}
+
+// Value ค่า tenant_id ที่ใช้ตอนบันทึกเอกสารใหม่ (Default เก็บเป็นค่าว่างเหมือนเอกสารเดิม)
+func Value(ctx context.Context) string {
+	tenantID := FromContext(ctx)
+	if tenantID == Default {
+		return ""
+	}
+	return tenantID
+}
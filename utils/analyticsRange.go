@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AnalyticsTimezone timezone ที่ใช้แบ่งช่วงเวลาของกราฟใน dashboard
+const AnalyticsTimezone = "Asia/Bangkok"
+
+const maxAnalyticsBuckets = 2000
+
+// AnalyticsLocation คืน Asia/Bangkok (ถ้าเครื่องไม่มี tzdata ใช้ UTC+7 ซึ่งเหมือนกันเพราะไทยไม่มี DST)
+func AnalyticsLocation() *time.Location {
+	loc, err := time.LoadLocation(AnalyticsTimezone)
+	if err != nil {
+		return time.FixedZone("ICT", 7*60*60)
+	}
+	return loc
+}
+
+// AnalyticsRange ช่วงเวลา [From, To) และความละเอียด (hour, day, week) ของ query สถิติ
+type AnalyticsRange struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+	Location    *time.Location
+}
+
+// ParseAnalyticsRange อ่าน from/to (YYYY-MM-DD ตามเวลาไทย หรือ RFC3339) และ granularity
+// to แบบวันที่นับรวมทั้งวัน ค่าเริ่มต้นคือ defaultDays วันล่าสุด รายวัน
+func ParseAnalyticsRange(from, to, granularity string, defaultDays int) (AnalyticsRange, error) {
+	loc := AnalyticsLocation()
+	r := AnalyticsRange{Granularity: granularity, Location: loc}
+	if r.Granularity == "" {
+		r.Granularity = "day"
+	}
+	if r.Granularity != "hour" && r.Granularity != "day" && r.Granularity != "week" {
+		return r, fmt.Errorf("granularity must be hour, day or week")
+	}
+
+	now := time.Now().In(loc)
+	r.To = now
+	if to != "" {
+		t, dateOnly, err := parseAnalyticsTime(to, loc)
+		if err != nil {
+			return r, fmt.Errorf("invalid to: %v", err)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		r.To = t
+	}
+
+	r.From = r.To.AddDate(0, 0, -defaultDays)
+	if from != "" {
+		t, _, err := parseAnalyticsTime(from, loc)
+		if err != nil {
+			return r, fmt.Errorf("invalid from: %v", err)
+		}
+		r.From = t
+	}
+	r.From = r.Truncate(r.From)
+
+	if !r.From.Before(r.To) {
+		return r, fmt.Errorf("from must be before to")
+	}
+	if len(r.Buckets()) > maxAnalyticsBuckets {
+		return r, fmt.Errorf("range exceeds %d %s buckets", maxAnalyticsBuckets, r.Granularity)
+	}
+	return r, nil
+}
+
+func parseAnalyticsTime(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, false, fmt.Errorf("expected YYYY-MM-DD or RFC3339")
+	}
+	return t.In(loc), false, nil
+}
+
+// Truncate ปัดเวลาลงเป็นจุดเริ่มของช่วง (สัปดาห์เริ่มวันจันทร์)
+func (r AnalyticsRange) Truncate(t time.Time) time.Time {
+	t = t.In(r.Location)
+	switch r.Granularity {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, r.Location)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, r.Location)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, r.Location)
+}
+
+// Next จุดเริ่มของช่วงถัดไป
+func (r AnalyticsRange) Next(t time.Time) time.Time {
+	switch r.Granularity {
+	case "hour":
+		return t.Add(time.Hour)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// Buckets จุดเริ่มของทุกช่วงใน [From, To)
+func (r AnalyticsRange) Buckets() []time.Time {
+	buckets := []time.Time{}
+	for t := r.Truncate(r.From); t.Before(r.To); t = r.Next(t) {
+		buckets = append(buckets, t)
+		if len(buckets) > maxAnalyticsBuckets {
+			break
+		}
+	}
+	return buckets
+}
+
+// Match เงื่อนไขของ field เวลาให้อยู่ในช่วง
+func (r AnalyticsRange) Match() bson.M {
+	return bson.M{"$gte": r.From, "$lt": r.To}
+}
+
+// BucketExpr expression ของ aggregation ที่ปัด field เวลาเป็นช่วงตามเวลาไทย (ต้องใช้ MongoDB 5.0 ขึ้นไป)
+func (r AnalyticsRange) BucketExpr(field string) bson.M {
+	return bson.M{"$dateTrunc": bson.M{
+		"date":        "$" + field,
+		"unit":        r.Granularity,
+		"timezone":    AnalyticsTimezone,
+		"startOfWeek": "monday",
+	}}
+}
+
+// ParseDateFilter เงื่อนไขช่วงเวลาแบบไม่บังคับ (from/to เป็น YYYY-MM-DD หรือ RFC3339, วันที่ของ to นับรวมทั้งวัน)
+// คืน nil เมื่อไม่ได้ระบุทั้งสองค่า
+func ParseDateFilter(from, to string) (bson.M, error) {
+	loc := AnalyticsLocation()
+	filter := bson.M{}
+	if from != "" {
+		t, _, err := parseAnalyticsTime(from, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %v", err)
+		}
+		filter["$gte"] = t
+	}
+	if to != "" {
+		t, dateOnly, err := parseAnalyticsTime(to, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to: %v", err)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter["$lt"] = t
+	}
+	if len(filter) == 0 {
+		return nil, nil
+	}
+	return filter, nil
+}
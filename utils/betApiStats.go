@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+const betAPIStatsWindow = time.Hour
+
+type betAPICall struct {
+	at     time.Time
+	failed bool
+}
+
+// betAPIStats แยกตาม tenant_id ของ config เพราะแต่ละ tenant ใช้ bet API คนละ endpoint
+var betAPIStats = struct {
+	sync.Mutex
+	calls map[string][]betAPICall
+}{calls: make(map[string][]betAPICall)}
+
+// recordBetAPICall เก็บผลการเรียก bet API ย้อนหลัง 1 ชั่วโมง สำหรับคำนวณอัตรา error
+func recordBetAPICall(tenantID string, failed bool) {
+	betAPIStats.Lock()
+	defer betAPIStats.Unlock()
+
+	now := time.Now()
+	betAPIStats.calls[tenantID] = append(pruneBetAPICalls(betAPIStats.calls[tenantID], now), betAPICall{at: now, failed: failed})
+}
+
+func pruneBetAPICalls(calls []betAPICall, now time.Time) []betAPICall {
+	cutoff := now.Add(-betAPIStatsWindow)
+	i := 0
+	for i < len(calls) && calls[i].at.Before(cutoff) {
+		i++
+	}
+	return calls[i:]
+}
+
+// BetAPIStats คืนจำนวนการเรียก bet API ของ tenant ทั้งหมดและจำนวนที่ล้มเหลวในช่วง 1 ชั่วโมงล่าสุด
+func BetAPIStats(tenantID string) (total int, failed int) {
+	betAPIStats.Lock()
+	defer betAPIStats.Unlock()
+
+	calls := pruneBetAPICalls(betAPIStats.calls[tenantID], time.Now())
+	betAPIStats.calls[tenantID] = calls
+	for _, call := range calls {
+		if call.failed {
+			failed++
+		}
+	}
+	return len(calls), failed
+}
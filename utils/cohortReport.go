@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-server/models"
+)
+
+// CohortRow ลูกค้าที่เริ่มมิชชันแรกในสัปดาห์เดียวกัน
+// ROI = (ยอดเดิมพัน - รางวัลที่จ่าย) / รางวัลที่จ่าย (null ถ้ายังไม่มีการจ่าย)
+type CohortRow struct {
+	Week        time.Time    `json:"week"`
+	Clients     int          `json:"clients"`
+	BetVolume   float64      `json:"bet_volume"`
+	RewardsPaid float64      `json:"rewards_paid"`
+	ROI         *float64     `json:"roi"`
+	Tiers       []CohortTier `json:"tiers"`
+	Retention   []float64    `json:"retention"` // สัดส่วนลูกค้าที่มียอดเดิมพันในสัปดาห์ที่ 0, 1, 2, ... หลังเริ่ม
+}
+
+type CohortTier struct {
+	Tier        int      `json:"tier"`
+	BetVolume   float64  `json:"bet_volume"`
+	RewardsPaid float64  `json:"rewards_paid"`
+	ROI         *float64 `json:"roi"`
+}
+
+type cohortClient struct {
+	firstStart  time.Time
+	bets        map[int]float64
+	rewards     map[int]float64
+	activeWeeks map[int64]bool
+}
+
+// CohortReport สะสมมิชชันและรางวัลที่อนุมัติแล้วของลูกค้าเพื่อสร้างรายงาน cohort รายสัปดาห์
+type CohortReport struct {
+	weeks   AnalyticsRange
+	clients map[string]*cohortClient
+}
+
+func NewCohortReport(r AnalyticsRange) *CohortReport {
+	r.Granularity = "week"
+	return &CohortReport{weeks: r, clients: map[string]*cohortClient{}}
+}
+
+func (c *CohortReport) client(userID string) *cohortClient {
+	cc, ok := c.clients[userID]
+	if !ok {
+		cc = &cohortClient{
+			bets:        map[int]float64{},
+			rewards:     map[int]float64{},
+			activeWeeks: map[int64]bool{},
+		}
+		c.clients[userID] = cc
+	}
+	return cc
+}
+
+// AddMission นับยอดเดิมพันของทุก level (CurrentBet) และสัปดาห์ที่มีการเดิมพัน
+func (c *CohortReport) AddMission(mission models.Mission) {
+	cc := c.client(mission.UserID)
+	if cc.firstStart.IsZero() || mission.CreatedAt.Before(cc.firstStart) {
+		cc.firstStart = mission.CreatedAt
+	}
+	for i, tier := range mission.Tiers {
+		for _, level := range tier.Levels {
+			cc.bets[i+1] += level.CurrentBet
+			if level.CurrentBet > 0 && !level.StartDate.IsZero() {
+				cc.activeWeeks[c.weeks.Truncate(level.StartDate).Unix()] = true
+			}
+		}
+	}
+}
+
+var logTierPattern = regexp.MustCompile(`^Tier (\d+)`)
+
+// ClaimTier tier ของการขอรับรางวัล log เก่าที่ไม่มี tier ใช้เลข tier จาก mission_detail
+func ClaimTier(entry models.Log) int {
+	if entry.Tier != 0 {
+		return entry.Tier
+	}
+	if match := logTierPattern.FindStringSubmatch(entry.MissionDetail); match != nil {
+		tier, _ := strconv.Atoi(match[1])
+		return tier
+	}
+	return 0
+}
+
+// AddReward นับรางวัลที่อนุมัติแล้ว
+func (c *CohortReport) AddReward(entry models.Log) {
+	c.client(entry.UserID).rewards[ClaimTier(entry)] += entry.Reward
+}
+
+// Rows รายงานของ cohort ที่เริ่มในช่วง weeks.From - weeks.To ติดตาม retention ไม่เกิน retentionWeeks สัปดาห์
+func (c *CohortReport) Rows(retentionWeeks int, now time.Time) []CohortRow {
+	byWeek := map[int64]*CohortRow{}
+	members := map[int64][]*cohortClient{}
+	for _, cc := range c.clients {
+		if cc.firstStart.IsZero() || cc.firstStart.Before(c.weeks.From) || !cc.firstStart.Before(c.weeks.To) {
+			continue
+		}
+		week := c.weeks.Truncate(cc.firstStart)
+		row, ok := byWeek[week.Unix()]
+		if !ok {
+			row = &CohortRow{Week: week}
+			byWeek[week.Unix()] = row
+		}
+		row.Clients++
+		members[week.Unix()] = append(members[week.Unix()], cc)
+	}
+
+	rows := make([]CohortRow, 0, len(byWeek))
+	for key, row := range byWeek {
+		tiers := map[int]*CohortTier{}
+		tierFor := func(n int) *CohortTier {
+			if tiers[n] == nil {
+				tiers[n] = &CohortTier{Tier: n}
+			}
+			return tiers[n]
+		}
+		for _, cc := range members[key] {
+			for n, bet := range cc.bets {
+				tierFor(n).BetVolume += bet
+				row.BetVolume += bet
+			}
+			for n, paid := range cc.rewards {
+				tierFor(n).RewardsPaid += paid
+				row.RewardsPaid += paid
+			}
+		}
+		row.ROI = cohortROI(row.BetVolume, row.RewardsPaid)
+
+		for _, tier := range tiers {
+			tier.ROI = cohortROI(tier.BetVolume, tier.RewardsPaid)
+			row.Tiers = append(row.Tiers, *tier)
+		}
+		sort.Slice(row.Tiers, func(i, j int) bool { return row.Tiers[i].Tier < row.Tiers[j].Tier })
+
+		// นับเฉพาะสัปดาห์ที่เริ่มแล้ว
+		for offset := 0; offset < retentionWeeks; offset++ {
+			week := row.Week.AddDate(0, 0, 7*offset)
+			if week.After(now) {
+				break
+			}
+			active := 0
+			for _, cc := range members[key] {
+				if cc.activeWeeks[week.Unix()] {
+					active++
+				}
+			}
+			row.Retention = append(row.Retention, float64(active)/float64(row.Clients))
+		}
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Week.Before(rows[j].Week) })
+	return rows
+}
+
+func cohortROI(bet, paid float64) *float64 {
+	if paid == 0 {
+		return nil
+	}
+	roi := (bet - paid) / paid
+	return &roi
+}
+
+// WriteCohortCSV เขียนรายงาน cohort เป็น CSV (หนึ่งแถวต่อ cohort)
+func WriteCohortCSV(w io.Writer, rows []CohortRow, retentionWeeks int) error {
+	maxTier := 0
+	for _, row := range rows {
+		for _, tier := range row.Tiers {
+			if tier.Tier > maxTier {
+				maxTier = tier.Tier
+			}
+		}
+	}
+
+	header := []string{"week", "clients", "bet_volume", "rewards_paid", "roi"}
+	for n := 1; n <= maxTier; n++ {
+		header = append(header, fmt.Sprintf("tier%d_bet_volume", n), fmt.Sprintf("tier%d_rewards_paid", n), fmt.Sprintf("tier%d_roi", n))
+	}
+	for offset := 0; offset < retentionWeeks; offset++ {
+		header = append(header, fmt.Sprintf("retention_week_%d", offset))
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{
+			row.Week.Format("2006-01-02"),
+			strconv.Itoa(row.Clients),
+			formatCSVFloat(row.BetVolume),
+			formatCSVFloat(row.RewardsPaid),
+			formatCSVRatio(row.ROI),
+		}
+		tiers := map[int]CohortTier{}
+		for _, tier := range row.Tiers {
+			tiers[tier.Tier] = tier
+		}
+		for n := 1; n <= maxTier; n++ {
+			tier := tiers[n]
+			record = append(record, formatCSVFloat(tier.BetVolume), formatCSVFloat(tier.RewardsPaid), formatCSVRatio(tier.ROI))
+		}
+		for offset := 0; offset < retentionWeeks; offset++ {
+			if offset < len(row.Retention) {
+				record = append(record, strconv.FormatFloat(row.Retention[offset], 'f', 4, 64))
+			} else {
+				record = append(record, "")
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatCSVFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+func formatCSVRatio(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', 4, 64)
+}
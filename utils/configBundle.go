@@ -0,0 +1,342 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/scrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigBundle คือ config ที่ส่งออกไปใช้กับ environment อื่น (เช่น production -> staging)
+// ชื่อ field ใน Config เป็นชื่อ bson เดียวกับ tbl_config ไม่รวม _id, tenant_id, hosts, liff_id และ revision
+// secret จะไม่อยู่ใน Config: ถ้าส่งออกพร้อม passphrase จะถูกเข้ารหัสไว้ใน Secrets
+const ConfigBundleVersion = 1
+
+type ConfigBundle struct {
+	Version    int                    `json:"version" yaml:"version"`
+	ExportedAt time.Time              `json:"exported_at" yaml:"exported_at"`
+	Tenant     string                 `json:"tenant" yaml:"tenant"`
+	Revision   int                    `json:"revision" yaml:"revision"`
+	Config     map[string]interface{} `json:"config" yaml:"config"`
+	Secrets    *BundleSecrets         `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	Images     []BundleImage          `json:"images,omitempty" yaml:"images,omitempty"`
+}
+
+// BundleSecrets secret ที่เข้ารหัสด้วย key จาก passphrase (scrypt + AES-256-GCM)
+type BundleSecrets struct {
+	KDF    string            `json:"kdf" yaml:"kdf"`
+	Salt   string            `json:"salt" yaml:"salt"`
+	Values map[string]string `json:"values" yaml:"values"` // path -> base64(nonce + ciphertext)
+}
+
+// BundleImage รูปที่อัปโหลดไว้และถูกอ้างถึงใน config
+type BundleImage struct {
+	URL         string `json:"url" yaml:"url"`
+	ContentType string `json:"content_type" yaml:"content_type"`
+	Data        string `json:"data" yaml:"data"` // base64
+}
+
+var (
+	ErrBundlePassphrase = errors.New("invalid passphrase for bundle secrets")
+	errBundleVersion    = errors.New("unsupported bundle version")
+)
+
+// field ที่ผูกกับ environment หรือ tenant ปลายทาง จึงไม่ส่งออก
+// liff_id ใช้ระบุ tenant (config.Service.ResolveTenant) ถ้าคัดลอกไป tenant อื่นในฐานข้อมูลเดียวกัน request จะไปผิด tenant
+var bundleExcludedFields = []string{"_id", "tenant_id", "hosts", "liff_id", "revision"}
+
+// NewConfigBundle สร้าง bundle จาก config ที่ถอดรหัสแล้ว
+// ถ้า passphrase ว่าง secret จะไม่ถูกส่งออก
+func NewConfigBundle(config models.Config, passphrase string) (*ConfigBundle, error) {
+	bundle := &ConfigBundle{
+		Version:    ConfigBundleVersion,
+		ExportedAt: time.Now(),
+		Revision:   config.Revision,
+	}
+
+	secrets := map[string]string{}
+	for path, field := range configSecretFields(&config) {
+		if *field != "" {
+			secrets[path] = *field
+		}
+		*field = ""
+	}
+
+	doc, err := configDocument(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range bundleExcludedFields {
+		delete(doc, field)
+	}
+	for _, path := range ConfigSecretPaths {
+		deletePath(doc, path)
+	}
+	bundle.Config = doc
+
+	if passphrase != "" && len(secrets) > 0 {
+		bundle.Secrets, err = sealBundleSecrets(secrets, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bundle, nil
+}
+
+// DecodeConfig แปลง bundle กลับเป็น models.Config
+// secret ที่ไม่ได้อยู่ใน bundle (หรือไม่ได้ให้ passphrase) จะเป็น SecretMask เพื่อให้ SealConfigSecrets คงค่าเดิมไว้
+func (b *ConfigBundle) DecodeConfig(passphrase string) (models.Config, error) {
+	var config models.Config
+	if b.Version != ConfigBundleVersion {
+		return config, errBundleVersion
+	}
+
+	data, err := bson.Marshal(b.Config)
+	if err != nil {
+		return config, fmt.Errorf("invalid bundle config: %v", err)
+	}
+	if err := bson.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid bundle config: %v", err)
+	}
+	config.TenantID = ""
+	config.Hosts = nil
+	config.LiffID = ""
+	config.Revision = 0
+
+	secrets := map[string]string{}
+	if b.Secrets != nil && passphrase != "" {
+		secrets, err = openBundleSecrets(b.Secrets, passphrase)
+		if err != nil {
+			return config, err
+		}
+	}
+	for path, field := range configSecretFields(&config) {
+		if value, ok := secrets[path]; ok {
+			*field = value
+		} else {
+			*field = SecretMask
+		}
+	}
+	return config, nil
+}
+
+// ReplaceImageURL เปลี่ยน URL รูปทุกตำแหน่งใน config ของ bundle
+func (b *ConfigBundle) ReplaceImageURL(oldURL, newURL string) {
+	b.Config = replaceString(b.Config, oldURL, newURL).(map[string]interface{})
+}
+
+// ImageURLs คืน URL ที่อยู่ใน config และเป็นไฟล์ที่อัปโหลดไว้ (owns มาจาก BlobStore.Owns ของ config)
+func (b *ConfigBundle) ImageURLs(owns func(string) bool) []string {
+	seen := map[string]bool{}
+	urls := []string{}
+	collectStrings(b.Config, func(value string) {
+		if !seen[value] && owns(value) {
+			seen[value] = true
+			urls = append(urls, value)
+		}
+	})
+	return urls
+}
+
+// FetchBundleImage ดาวน์โหลดรูปเพื่อแนบใน bundle
+func FetchBundleImage(imageURL string) (BundleImage, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(imageURL)
+	if err != nil {
+		return BundleImage{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return BundleImage{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return BundleImage{}, err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return BundleImage{
+		URL:         imageURL,
+		ContentType: contentType,
+		Data:        base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// Bytes คืนข้อมูลรูปที่ถอด base64 แล้ว
+func (i BundleImage) Bytes() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(i.Data)
+}
+
+// ObjectName ชื่อไฟล์ปลายทางตอน import ใช้ hash ของเนื้อไฟล์ เพื่อให้ import ซ้ำได้ไฟล์เดิม
+func (i BundleImage) ObjectName(data []byte) string {
+	sum := sha256.Sum256(data)
+	name := "imported/" + hex.EncodeToString(sum[:8])
+	if extensions, err := mime.ExtensionsByType(i.ContentType); err == nil && len(extensions) > 0 {
+		name += extensions[0]
+	}
+	return name
+}
+
+// MarshalConfigBundle เขียน bundle เป็น "json" หรือ "yaml"
+func MarshalConfigBundle(bundle *ConfigBundle, format string) ([]byte, error) {
+	switch format {
+	case "", "json":
+		return json.MarshalIndent(bundle, "", "  ")
+	case "yaml", "yml":
+		return yaml.Marshal(bundle)
+	}
+	return nil, fmt.Errorf("unsupported bundle format %q", format)
+}
+
+// UnmarshalConfigBundle อ่าน bundle ที่เป็น JSON หรือ YAML (ดูจากตัวอักษรแรก)
+func UnmarshalConfigBundle(data []byte) (*ConfigBundle, error) {
+	var bundle ConfigBundle
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &bundle); err != nil {
+			return nil, fmt.Errorf("invalid JSON bundle: %v", err)
+		}
+	} else if err := yaml.Unmarshal(trimmed, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid YAML bundle: %v", err)
+	}
+	if bundle.Config == nil {
+		return nil, errors.New("bundle has no config")
+	}
+	return &bundle, nil
+}
+
+func configDocument(config models.Config) (map[string]interface{}, error) {
+	data, err := bson.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func deletePath(doc map[string]interface{}, path string) {
+	head, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		delete(doc, head)
+		return
+	}
+	if child, ok := doc[head].(map[string]interface{}); ok {
+		deletePath(child, rest)
+	}
+}
+
+func collectStrings(value interface{}, fn func(string)) {
+	switch v := value.(type) {
+	case string:
+		fn(v)
+	case map[string]interface{}:
+		for _, item := range v {
+			collectStrings(item, fn)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectStrings(item, fn)
+		}
+	case primitive.A:
+		collectStrings([]interface{}(v), fn)
+	}
+}
+
+func replaceString(value interface{}, oldValue, newValue string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == oldValue {
+			return newValue
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = replaceString(item, oldValue, newValue)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = replaceString(item, oldValue, newValue)
+		}
+	case primitive.A:
+		replaceString([]interface{}(v), oldValue, newValue)
+	}
+	return value
+}
+
+func bundleKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func sealBundleSecrets(secrets map[string]string, passphrase string) (*BundleSecrets, error) {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	key, err := bundleKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := &BundleSecrets{
+		KDF:    "scrypt",
+		Salt:   base64.StdEncoding.EncodeToString(salt),
+		Values: make(map[string]string, len(secrets)),
+	}
+	for path, value := range secrets {
+		ciphertext, err := gcmSeal(key, []byte(value))
+		if err != nil {
+			return nil, err
+		}
+		sealed.Values[path] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+	return sealed, nil
+}
+
+func openBundleSecrets(sealed *BundleSecrets, passphrase string) (map[string]string, error) {
+	if sealed.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported bundle key derivation %q", sealed.KDF)
+	}
+	salt, err := base64.StdEncoding.DecodeString(sealed.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle salt: %v", err)
+	}
+	key, err := bundleKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make(map[string]string, len(sealed.Values))
+	for path, value := range sealed.Values {
+		ciphertext, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle secret %s: %v", path, err)
+		}
+		plaintext, err := gcmOpen(key, ciphertext)
+		if err != nil {
+			return nil, ErrBundlePassphrase
+		}
+		secrets[path] = string(plaintext)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"go-server/models"
+)
+
+// ApplyTierOverlay คืนสำเนาของกติกา tier ที่ปรับตาม overlay แล้ว (overlay nil = คืนค่าเดิม)
+func ApplyTierOverlay(rules []models.TierDetail, overlay *models.ConfigOverlay) []models.TierDetail {
+	if overlay == nil || len(overlay.Tiers) == 0 {
+		return rules
+	}
+
+	effective := append([]models.TierDetail{}, rules...)
+	for _, override := range overlay.Tiers {
+		idx := override.Tier - 1
+		if idx < 0 || idx >= len(effective) {
+			continue
+		}
+		rule := &effective[idx]
+		setInt(&rule.Target, override.Target)
+		setInt(&rule.Reward, override.Reward)
+		setInt(&rule.Period, override.Period)
+		setInt(&rule.FollowUpHours, override.FollowUpHours)
+		setInt(&rule.ExpireRewardHours, override.ExpireRewardHours)
+		setInt(&rule.MaxConsecutiveFails, override.MaxConsecutiveFails)
+	}
+	return effective
+}
+
+func setInt(field *int, value *int) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+// ApplyConfigOverlay ปรับ tier และ Flex message ของ config ตาม overlay และเก็บ overlay ไว้ใน ActiveOverlay
+func ApplyConfigOverlay(config *models.Config, overlay *models.ConfigOverlay) {
+	config.ActiveOverlay = overlay
+	if overlay == nil {
+		return
+	}
+
+	config.Tiers = ApplyTierOverlay(config.Tiers, overlay)
+
+	messages := []struct {
+		target   *models.BaseFlexMessageContent
+		override *models.BaseFlexMessageContent
+	}{
+		{&config.FlexMessages.Followup, overlay.FlexMessages.Followup},
+		{&config.FlexMessages.MissionSuccess, overlay.FlexMessages.MissionSuccess},
+		{&config.FlexMessages.MissionFailed, overlay.FlexMessages.MissionFailed},
+		{&config.FlexMessages.MissionComplete, overlay.FlexMessages.MissionComplete},
+		{&config.FlexMessages.GetReward, overlay.FlexMessages.GetReward},
+		{&config.FlexMessages.RewardNotification, overlay.FlexMessages.RewardNotification},
+	}
+	for _, message := range messages {
+		if message.override != nil {
+			*message.target = *message.override
+		}
+	}
+}
+
+// ValidateConfigOverlay ตรวจ overlay กับกติกา tier ปัจจุบัน (ไม่รวมการตรวจช่วงเวลาทับกัน)
+// ค่าที่ override จะถูกตรวจด้วยกฎเดียวกับ ValidateTiers หลังนำไปใช้กับ tiers แล้ว
+func ValidateConfigOverlay(overlay models.ConfigOverlay, tiers []models.TierDetail) ValidationErrors {
+	errs := ValidationErrors{}
+
+	if strings.TrimSpace(overlay.Name) == "" {
+		errs.add("name", "name is required")
+	}
+	if overlay.StartsAt.IsZero() {
+		errs.add("starts_at", "start time is required")
+	}
+	if overlay.EndsAt.IsZero() {
+		errs.add("ends_at", "end time is required")
+	} else if !overlay.EndsAt.After(overlay.StartsAt) {
+		errs.add("ends_at", "end time must be after start time")
+	}
+
+	seen := map[int]bool{}
+	for i, override := range overlay.Tiers {
+		field := fmt.Sprintf("tiers.%d.tier", i)
+		if override.Tier < 1 || override.Tier > len(tiers) {
+			errs.add(field, "tier must be between 1 and %d", len(tiers))
+		} else if seen[override.Tier] {
+			errs.add(field, "tier %d is overridden more than once", override.Tier)
+		}
+		seen[override.Tier] = true
+	}
+	if len(overlay.Tiers) > 0 {
+		errs = append(errs, ValidateTiers(ApplyTierOverlay(tiers, &overlay))...)
+	}
+
+	messages := []struct {
+		field   string
+		content *models.BaseFlexMessageContent
+	}{
+		{"flexMessages.followup", overlay.FlexMessages.Followup},
+		{"flexMessages.missionSuccess", overlay.FlexMessages.MissionSuccess},
+		{"flexMessages.missionFailed", overlay.FlexMessages.MissionFailed},
+		{"flexMessages.missionComplete", overlay.FlexMessages.MissionComplete},
+		{"flexMessages.getReward", overlay.FlexMessages.GetReward},
+		{"flexMessages.rewardNotification", overlay.FlexMessages.RewardNotification},
+	}
+	hasMessage := false
+	for _, message := range messages {
+		if message.content != nil {
+			hasMessage = true
+			validateFlexMessage(message.field, *message.content, &errs)
+		}
+	}
+
+	if len(overlay.Tiers) == 0 && !hasMessage {
+		errs.add("tiers", "overlay must override at least one tier or flex message")
+	}
+	return errs
+}
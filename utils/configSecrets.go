@@ -0,0 +1,477 @@
+package utils
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"go-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ค่า secret ใน tbl_config ถูกเข้ารหัสแบบ envelope: แต่ละค่ามี data key ของตัวเอง (AES-256-GCM)
+// และ data key ถูกเข้ารหัสด้วย master key จาก CONFIG_MASTER_KEY อีกชั้น
+// รูปแบบที่เก็บ: enc:v1:<key id>:<wrapped data key>:<ciphertext>
+//
+// CONFIG_MASTER_KEY         master key ปัจจุบัน (base64 ขนาด 32 byte)
+// CONFIG_MASTER_KEY_PREVIOUS master key เก่าคั่นด้วย comma ใช้ถอดรหัสระหว่าง rotate
+const (
+	secretPrefix = "enc:v1:"
+	SecretMask   = "********"
+)
+
+// ConfigSecretPaths ชื่อ bson ของ field ที่เป็น secret ใน models.Config
+var ConfigSecretPaths = []string{
+	"channel_access_token",
+	"channel_secret",
+	"telegram_bot_token",
+	"telegram_secret",
+	"api_key",
+	"firebase_config.credential",
+	"storage.s3.secret_access_key",
+}
+
+var errNoMasterKey = errors.New("CONFIG_MASTER_KEY is not set")
+
+type masterKeyRing struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+var (
+	keyRingOnce sync.Once
+	keyRing     masterKeyRing
+	keyRingErr  error
+)
+
+func loadMasterKeys() (masterKeyRing, error) {
+	keyRingOnce.Do(func() {
+		keyRing = masterKeyRing{keys: make(map[string][]byte)}
+
+		current := strings.TrimSpace(os.Getenv("CONFIG_MASTER_KEY"))
+		if current == "" {
+			log.Println("CONFIG_MASTER_KEY is not set, config secrets will be stored in plaintext")
+		} else {
+			id, key, err := decodeMasterKey(current)
+			if err != nil {
+				keyRingErr = fmt.Errorf("invalid CONFIG_MASTER_KEY: %v", err)
+				return
+			}
+			keyRing.currentID = id
+			keyRing.keys[id] = key
+		}
+
+		for _, previous := range strings.Split(os.Getenv("CONFIG_MASTER_KEY_PREVIOUS"), ",") {
+			if strings.TrimSpace(previous) == "" {
+				continue
+			}
+			id, key, err := decodeMasterKey(strings.TrimSpace(previous))
+			if err != nil {
+				keyRingErr = fmt.Errorf("invalid CONFIG_MASTER_KEY_PREVIOUS: %v", err)
+				return
+			}
+			keyRing.keys[id] = key
+		}
+	})
+	return keyRing, keyRingErr
+}
+
+func decodeMasterKey(encoded string) (string, []byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(key) != 32 {
+		return "", nil, fmt.Errorf("master key must be 32 bytes, got %d", len(key))
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4]), key, nil
+}
+
+func gcmSeal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func gcmOpen(key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+// IsEncryptedSecret ตรวจว่าค่าถูกเข้ารหัสแล้วหรือยัง
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, secretPrefix)
+}
+
+type sealedSecret struct {
+	keyID      string
+	wrappedKey []byte
+	ciphertext []byte
+}
+
+func parseSecret(value string) (sealedSecret, error) {
+	parts := strings.Split(strings.TrimPrefix(value, secretPrefix), ":")
+	if len(parts) != 3 {
+		return sealedSecret{}, errors.New("malformed encrypted secret")
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return sealedSecret{}, fmt.Errorf("malformed encrypted secret: %v", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return sealedSecret{}, fmt.Errorf("malformed encrypted secret: %v", err)
+	}
+	return sealedSecret{keyID: parts[0], wrappedKey: wrappedKey, ciphertext: ciphertext}, nil
+}
+
+func (s sealedSecret) String() string {
+	return secretPrefix + s.keyID + ":" + base64.StdEncoding.EncodeToString(s.wrappedKey) + ":" + base64.StdEncoding.EncodeToString(s.ciphertext)
+}
+
+// EncryptSecret เข้ารหัสค่า secret ถ้าไม่ได้ตั้ง CONFIG_MASTER_KEY จะคืนค่าเดิม
+func EncryptSecret(plaintext string) (string, error) {
+	if plaintext == "" || IsEncryptedSecret(plaintext) {
+		return plaintext, nil
+	}
+	ring, err := loadMasterKeys()
+	if err != nil {
+		return "", err
+	}
+	if ring.currentID == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	ciphertext, err := gcmSeal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := gcmSeal(ring.keys[ring.currentID], dataKey)
+	if err != nil {
+		return "", err
+	}
+	return sealedSecret{keyID: ring.currentID, wrappedKey: wrappedKey, ciphertext: ciphertext}.String(), nil
+}
+
+func unwrapDataKey(ring masterKeyRing, secret sealedSecret) ([]byte, error) {
+	masterKey, ok := ring.keys[secret.keyID]
+	if !ok {
+		return nil, fmt.Errorf("no master key for key id %s", secret.keyID)
+	}
+	return gcmOpen(masterKey, secret.wrappedKey)
+}
+
+// DecryptSecret ถอดรหัสค่า secret ค่าที่ยังไม่ถูกเข้ารหัสจะคืนค่าเดิม
+func DecryptSecret(value string) (string, error) {
+	if !IsEncryptedSecret(value) {
+		return value, nil
+	}
+	ring, err := loadMasterKeys()
+	if err != nil {
+		return "", err
+	}
+	secret, err := parseSecret(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := unwrapDataKey(ring, secret)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := gcmOpen(dataKey, secret.ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// RewrapSecret เข้ารหัส data key ใหม่ด้วย master key ปัจจุบัน (ค่าที่ยังเป็น plaintext จะถูกเข้ารหัส)
+func RewrapSecret(value string) (string, error) {
+	ring, err := loadMasterKeys()
+	if err != nil {
+		return "", err
+	}
+	if ring.currentID == "" {
+		return "", errNoMasterKey
+	}
+	if !IsEncryptedSecret(value) {
+		return EncryptSecret(value)
+	}
+
+	secret, err := parseSecret(value)
+	if err != nil {
+		return "", err
+	}
+	if secret.keyID == ring.currentID {
+		return value, nil
+	}
+	dataKey, err := unwrapDataKey(ring, secret)
+	if err != nil {
+		return "", err
+	}
+	secret.wrappedKey, err = gcmSeal(ring.keys[ring.currentID], dataKey)
+	if err != nil {
+		return "", err
+	}
+	secret.keyID = ring.currentID
+	return secret.String(), nil
+}
+
+func configSecretFields(config *models.Config) map[string]*string {
+	return map[string]*string{
+		"channel_access_token":         &config.ChannelAccessToken,
+		"channel_secret":               &config.ChannelSecret,
+		"telegram_bot_token":           &config.TelegramBotToken,
+		"telegram_secret":              &config.TelegramSecret,
+		"api_key":                      &config.ApiKey,
+		"firebase_config.credential":   &config.FirebaseConfig.Credential,
+		"storage.s3.secret_access_key": &config.Storage.S3.SecretAccessKey,
+	}
+}
+
+// DecryptConfigSecrets ถอดรหัส secret ทุก field ของ config
+func DecryptConfigSecrets(config *models.Config) error {
+	for path, field := range configSecretFields(config) {
+		plaintext, err := DecryptSecret(*field)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %v", path, err)
+		}
+		*field = plaintext
+	}
+	return nil
+}
+
+// MaskConfigSecrets แทนค่า secret ด้วย SecretMask ก่อนส่งออกทาง API
+func MaskConfigSecrets(config *models.Config) {
+	if config == nil {
+		return
+	}
+	for _, field := range configSecretFields(config) {
+		if *field != "" {
+			*field = SecretMask
+		}
+	}
+}
+
+// UnmaskConfigSecrets แทน secret ที่เป็น SecretMask ด้วยค่าจาก current (ใช้ดูผลลัพธ์ก่อนบันทึก)
+func UnmaskConfigSecrets(config *models.Config, current models.Config) {
+	currentFields := configSecretFields(&current)
+	for path, field := range configSecretFields(config) {
+		if *field == SecretMask {
+			*field = *currentFields[path]
+		}
+	}
+}
+
+// MaskConfigChanges ซ่อนค่า secret ใน diff ของ revision
+func MaskConfigChanges(changes []models.ConfigChange) {
+	for i := range changes {
+		for _, path := range ConfigSecretPaths {
+			if changes[i].Path != path {
+				continue
+			}
+			if s, ok := changes[i].Old.(string); ok && s != "" {
+				changes[i].Old = SecretMask
+			}
+			if s, ok := changes[i].New.(string); ok && s != "" {
+				changes[i].New = SecretMask
+			}
+		}
+	}
+}
+
+// SealConfigSecrets เตรียม $set ของ config ก่อนบันทึก:
+// ถ้าส่ง SecretMask มาจะคงค่าเดิมไว้ (current คือเอกสารที่ยังเข้ารหัสอยู่) นอกนั้นจะถูกเข้ารหัส
+func SealConfigSecrets(set bson.M, current models.Config) error {
+	currentFields := configSecretFields(&current)
+	for _, path := range ConfigSecretPaths {
+		value, ok := lookupPath(set, path)
+		if !ok {
+			continue
+		}
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var sealed string
+		if str == SecretMask {
+			sealed = *currentFields[path]
+		} else {
+			var err error
+			sealed, err = EncryptSecret(str)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt %s: %v", path, err)
+			}
+		}
+		replacePath(set, path, sealed)
+	}
+	return nil
+}
+
+// lookupPath หาค่าจาก $set ที่อาจเขียนเป็น "a.b" หรือเป็นเอกสารซ้อน {a: {b: ...}}
+func lookupPath(doc interface{}, path string) (interface{}, bool) {
+	if m, ok := doc.(bson.M); ok {
+		if value, ok := m[path]; ok {
+			return value, true
+		}
+	}
+	head, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		return nil, false
+	}
+	switch d := doc.(type) {
+	case bson.M:
+		if child, ok := d[head]; ok {
+			return lookupPath(child, rest)
+		}
+	case primitive.D:
+		for _, e := range d {
+			if e.Key == head {
+				return lookupPath(e.Value, rest)
+			}
+		}
+	}
+	return nil, false
+}
+
+func replacePath(doc interface{}, path string, value interface{}) {
+	if m, ok := doc.(bson.M); ok {
+		if _, ok := m[path]; ok {
+			m[path] = value
+			return
+		}
+	}
+	head, rest, nested := strings.Cut(path, ".")
+	switch d := doc.(type) {
+	case bson.M:
+		if !nested {
+			d[head] = value
+		} else if child, ok := d[head]; ok {
+			replacePath(child, rest, value)
+		}
+	case primitive.D:
+		for i := range d {
+			if d[i].Key != head {
+				continue
+			}
+			if !nested {
+				d[i].Value = value
+			} else {
+				replacePath(d[i].Value, rest, value)
+			}
+		}
+	}
+}
+
+// LoadConfig อ่าน config ที่ตรงกับ filter และถอดรหัส secret ให้พร้อมใช้งาน
+func LoadConfig(ctx context.Context, collection *mongo.Collection, filter bson.M, config *models.Config) error {
+	if err := collection.FindOne(ctx, filter).Decode(config); err != nil {
+		return err
+	}
+	return DecryptConfigSecrets(config)
+}
+
+// RotateConfigSecrets เข้ารหัส secret ใน tbl_config และ snapshot ใน tbl_config_revisions ใหม่ด้วย master key ปัจจุบัน
+func RotateConfigSecrets(ctx context.Context, configCollection, revisionCollection *mongo.Collection) (int, error) {
+	if _, err := loadMasterKeys(); err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	rewrap := func(collection *mongo.Collection, prefix string, id primitive.ObjectID, config *models.Config) error {
+		set := bson.M{}
+		for path, field := range configSecretFields(config) {
+			if *field == "" {
+				continue
+			}
+			value, err := RewrapSecret(*field)
+			if err != nil {
+				return fmt.Errorf("%s %s: %v", id.Hex(), path, err)
+			}
+			if value != *field {
+				set[prefix+path] = value
+			}
+		}
+		if len(set) == 0 {
+			return nil
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set}); err != nil {
+			return fmt.Errorf("%s: %v", id.Hex(), err)
+		}
+		rotated++
+		return nil
+	}
+
+	cursor, err := configCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return rotated, err
+	}
+	var configs []models.Config
+	if err := cursor.All(ctx, &configs); err != nil {
+		return rotated, err
+	}
+	for i := range configs {
+		if err := rewrap(configCollection, "", configs[i].ID, &configs[i]); err != nil {
+			return rotated, err
+		}
+	}
+
+	cursor, err = revisionCollection.Find(ctx, bson.M{"config": bson.M{"$exists": true}})
+	if err != nil {
+		return rotated, err
+	}
+	var revisions []models.ConfigRevision
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return rotated, err
+	}
+	for i := range revisions {
+		if revisions[i].Config == nil {
+			continue
+		}
+		if err := rewrap(revisionCollection, "config.", revisions[i].ID, revisions[i].Config); err != nil {
+			return rotated, err
+		}
+	}
+
+	return rotated, nil
+}
@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go-server/models"
+)
+
+// FieldError ข้อผิดพลาดของ field เดียว Field ใช้ชื่อ json แบบ dot path เช่น "tiers.0.period"
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []FieldError
+
+func (v *ValidationErrors) add(field, format string, args ...interface{}) {
+	*v = append(*v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateTiers ตรวจกติกา tier ก่อนบันทึก ค่าที่ผิดจะทำให้ event loop พังภายหลัง
+func ValidateTiers(tiers []models.TierDetail) ValidationErrors {
+	errs := ValidationErrors{}
+	if len(tiers) == 0 {
+		errs.add("tiers", "at least one tier is required")
+		return errs
+	}
+
+	for i, tier := range tiers {
+		field := func(name string) string { return fmt.Sprintf("tiers.%d.%s", i, name) }
+
+		if strings.TrimSpace(tier.Name) == "" {
+			errs.add(field("name"), "name is required")
+		}
+		if tier.Period <= 0 {
+			errs.add(field("period"), "period must be greater than 0")
+		}
+		if tier.Target <= 0 {
+			errs.add(field("target"), "target must be greater than 0")
+		}
+		if tier.Reward < 0 {
+			errs.add(field("reward"), "reward must not be negative")
+		}
+		if tier.MaxLevel < 1 {
+			errs.add(field("max_level"), "max_level must be at least 1")
+		}
+		if tier.FollowUpHours < 0 {
+			errs.add(field("follow_up_hours"), "follow_up_hours must not be negative")
+		} else if tier.Period > 0 && tier.FollowUpHours > tier.Period {
+			errs.add(field("follow_up_hours"), "follow_up_hours must not be greater than period (%d)", tier.Period)
+		}
+		if tier.ExpireRewardHours < 0 {
+			errs.add(field("expire_reward_hours"), "expire_reward_hours must not be negative")
+		}
+		if tier.NotifyBeforeExpire < 0 {
+			errs.add(field("notify_before_expire"), "notify_before_expire must not be negative")
+		} else if tier.NotifyBeforeExpire > tier.ExpireRewardHours {
+			errs.add(field("notify_before_expire"), "notify_before_expire must not be greater than expire_reward_hours (%d)", tier.ExpireRewardHours)
+		}
+		if tier.NotifyInterval < 0 {
+			errs.add(field("notify_interval"), "notify_interval must not be negative")
+		}
+		if tier.MaxConsecutiveFails < 0 {
+			errs.add(field("max_consecutive_fails"), "max_consecutive_fails must not be negative")
+		}
+		if tier.ProcessingDelay < 0 {
+			errs.add(field("processing_delay"), "processing_delay must not be negative")
+		}
+	}
+
+	return errs
+}
+
+// ValidateFlexMessages ทุกข้อความต้องมีรูป และลิงก์ปุ่มต้องเป็น https (ข้อกำหนดของ LINE)
+func ValidateFlexMessages(flexMessages models.FlexMessages) ValidationErrors {
+	errs := ValidationErrors{}
+	messages := []struct {
+		Field   string
+		Content models.BaseFlexMessageContent
+	}{
+		{"flexMessages.followup", flexMessages.Followup},
+		{"flexMessages.missionSuccess", flexMessages.MissionSuccess},
+		{"flexMessages.missionFailed", flexMessages.MissionFailed},
+		{"flexMessages.missionComplete", flexMessages.MissionComplete},
+		{"flexMessages.getReward", flexMessages.GetReward},
+		{"flexMessages.rewardNotification", flexMessages.RewardNotification},
+	}
+
+	for _, message := range messages {
+		validateFlexMessage(message.Field, message.Content, &errs)
+	}
+
+	return errs
+}
+
+func validateFlexMessage(field string, content models.BaseFlexMessageContent, errs *ValidationErrors) {
+	if strings.TrimSpace(content.ImageUrl) == "" {
+		errs.add(field+".imageUrl", "image URL is required")
+	} else if !isURL(content.ImageUrl, "http", "https") {
+		errs.add(field+".imageUrl", "image URL must be a valid http(s) URL")
+	}
+
+	if content.ButtonUrl != "" && !isURL(content.ButtonUrl, "https") {
+		errs.add(field+".buttonUrl", "button URL must be a valid https URL")
+	}
+	if content.ButtonTitle != "" && content.ButtonUrl == "" {
+		errs.add(field+".buttonUrl", "button URL is required when button title is set")
+	}
+}
+
+var cssColorPattern = regexp.MustCompile(`^(#([0-9a-fA-F]{3}|[0-9a-fA-F]{4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})|rgba?\(\s*\d{1,3}%?\s*,\s*\d{1,3}%?\s*,\s*\d{1,3}%?\s*(,\s*(0|1|0?\.\d+|\d{1,3}%)\s*)?\)|transparent)$`)
+
+// ValidateSiteTemplate ตรวจ field สีทั้งหมด (ชื่อ json ที่มี color หรือ gradient) ว่าเป็นสี CSS ที่ถูกต้อง
+// field ที่เว้นว่างไว้จะใช้ค่า default ของหน้าเว็บ
+func ValidateSiteTemplate(siteTemplate models.SiteTemplateConfig) ValidationErrors {
+	errs := ValidationErrors{}
+	validateColors("siteTemplate", reflect.ValueOf(siteTemplate), &errs)
+	return errs
+}
+
+func validateColors(path string, value reflect.Value, errs *ValidationErrors) {
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			name := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				name = value.Type().Field(i).Name
+			}
+			fieldPath := path + "." + name
+			field := value.Field(i)
+
+			if field.Kind() == reflect.String {
+				lower := strings.ToLower(name)
+				isColorField := strings.Contains(lower, "color") || strings.Contains(lower, "gradient")
+				if isColorField && field.String() != "" && !cssColorPattern.MatchString(strings.TrimSpace(field.String())) {
+					errs.add(fieldPath, "%q is not a valid color (use #rrggbb or rgb()/rgba())", field.String())
+				}
+				continue
+			}
+			validateColors(fieldPath, field, errs)
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			validateColors(fmt.Sprintf("%s.%d", path, i), value.Index(i), errs)
+		}
+	}
+}
+
+func isURL(raw string, schemes ...string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	for _, scheme := range schemes {
+		if parsed.Scheme == scheme {
+			return true
+		}
+	}
+	return false
+}
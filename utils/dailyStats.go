@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"strconv"
+
+	"go-server/models"
+)
+
+// AddMissionToDailyStats นับมิชชันเข้าสถิติของวันที่มิชชันเริ่ม
+func AddMissionToDailyStats(stats *models.MissionDailyStats, mission models.Mission) {
+	stats.Started++
+	stats.ByStatus = incrementStatus(stats.ByStatus, mission.Status)
+
+	for i, tier := range mission.Tiers {
+		for len(stats.Tiers) <= i {
+			stats.Tiers = append(stats.Tiers, models.TierDailyStats{Tier: len(stats.Tiers) + 1})
+		}
+		t := &stats.Tiers[i]
+		if t.Name == "" {
+			t.Name = tier.Name
+		}
+		t.Reached++
+		t.ByStatus = incrementStatus(t.ByStatus, tier.Status)
+		t.CurrentLevels = incrementStatus(t.CurrentLevels, strconv.Itoa(tier.CurrentLevel))
+
+		for j, level := range tier.Levels {
+			for len(t.Levels) <= j {
+				t.Levels = append(t.Levels, models.LevelDailyStats{Level: len(t.Levels) + 1})
+			}
+			t.Levels[j].ByStatus = incrementStatus(t.Levels[j].ByStatus, level.Status)
+		}
+	}
+}
+
+// SumDailyStats รวมสถิติหลายวันเป็นก้อนเดียว (ใช้ตอบ dashboard)
+func SumDailyStats(days []models.DailyStats) models.DailyStats {
+	var total models.DailyStats
+	for _, day := range days {
+		total.Missions.Started += day.Missions.Started
+		total.Missions.ByStatus = addStatuses(total.Missions.ByStatus, day.Missions.ByStatus)
+		for i, tier := range day.Missions.Tiers {
+			for len(total.Missions.Tiers) <= i {
+				total.Missions.Tiers = append(total.Missions.Tiers, models.TierDailyStats{Tier: len(total.Missions.Tiers) + 1})
+			}
+			t := &total.Missions.Tiers[i]
+			if t.Name == "" {
+				t.Name = tier.Name
+			}
+			t.Reached += tier.Reached
+			t.ByStatus = addStatuses(t.ByStatus, tier.ByStatus)
+			t.CurrentLevels = addStatuses(t.CurrentLevels, tier.CurrentLevels)
+			for j, level := range tier.Levels {
+				for len(t.Levels) <= j {
+					t.Levels = append(t.Levels, models.LevelDailyStats{Level: len(t.Levels) + 1})
+				}
+				t.Levels[j].ByStatus = addStatuses(t.Levels[j].ByStatus, level.ByStatus)
+			}
+		}
+
+		total.Claims.Requested += day.Claims.Requested
+		total.Claims.Approved += day.Claims.Approved
+		total.Claims.Rejected += day.Claims.Rejected
+		total.Claims.RequestedAmount += day.Claims.RequestedAmount
+		total.Claims.ApprovedAmount += day.Claims.ApprovedAmount
+		total.Messages.Sent += day.Messages.Sent
+		total.Messages.Read += day.Messages.Read
+		total.NewClients += day.NewClients
+	}
+	return total
+}
+
+func incrementStatus(counts map[string]int, status string) map[string]int {
+	if counts == nil {
+		counts = map[string]int{}
+	}
+	counts[status]++
+	return counts
+}
+
+func addStatuses(total, counts map[string]int) map[string]int {
+	if total == nil {
+		total = map[string]int{}
+	}
+	for status, n := range counts {
+		total[status] += n
+	}
+	return total
+}
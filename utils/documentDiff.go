@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"go-server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DiffDocuments เปรียบเทียบเอกสาร 2 ชุดแบบ field ต่อ field โดยใช้ชื่อ bson แบบ dot path
+// เช่น "tiers.0.target" ข้าม field ที่อยู่ใน ignore
+func DiffDocuments(before, after interface{}, ignore ...string) ([]models.ConfigChange, error) {
+	beforeFields, err := FlattenDocument(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := FlattenDocument(after)
+	if err != nil {
+		return nil, err
+	}
+
+	ignored := make(map[string]bool, len(ignore))
+	for _, path := range ignore {
+		ignored[path] = true
+	}
+
+	paths := make(map[string]bool)
+	for path := range beforeFields {
+		paths[path] = true
+	}
+	for path := range afterFields {
+		paths[path] = true
+	}
+
+	changes := make([]models.ConfigChange, 0)
+	for path := range paths {
+		if ignored[rootField(path)] {
+			continue
+		}
+		oldValue, newValue := beforeFields[path], afterFields[path]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, models.ConfigChange{Path: path, Old: oldValue, New: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+func rootField(path string) string {
+	for i := 0; i < len(path); i++ {
+		if path[i] == '.' {
+			return path[:i]
+		}
+	}
+	return path
+}
+
+// FlattenDocument แปลงเอกสารเป็น map ของ dot path -> ค่า
+func FlattenDocument(doc interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if doc == nil {
+		return fields, nil
+	}
+
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document: %v", err)
+	}
+	var m bson.M
+	if err := bson.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal document: %v", err)
+	}
+
+	flattenValue("", m, fields)
+	return fields, nil
+}
+
+func flattenValue(prefix string, value interface{}, fields map[string]interface{}) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch v := value.(type) {
+	case bson.M:
+		if len(v) == 0 && prefix != "" {
+			fields[prefix] = v
+		}
+		for key, item := range v {
+			flattenValue(join(key), item, fields)
+		}
+	case bson.D:
+		if len(v) == 0 && prefix != "" {
+			fields[prefix] = v
+		}
+		for _, item := range v {
+			flattenValue(join(item.Key), item.Value, fields)
+		}
+	case primitive.A:
+		if len(v) == 0 && prefix != "" {
+			fields[prefix] = v
+		}
+		for i, item := range v {
+			flattenValue(join(fmt.Sprintf("%d", i)), item, fields)
+		}
+	default:
+		fields[prefix] = v
+	}
+}
@@ -12,6 +12,12 @@ import (
 )
 
 func GetCurrentBet(config models.Config, userID string, startDate, endDate time.Time) (float64, error) {
+	bet, err := getCurrentBet(config, userID, startDate, endDate)
+	recordBetAPICall(config.TenantID, err != nil)
+	return bet, err
+}
+
+func getCurrentBet(config models.Config, userID string, startDate, endDate time.Time) (float64, error) {
 	log.Println("utils.GetCurrentBet: Starting")
 
 	// Return a fixed value of 500 for currentBet
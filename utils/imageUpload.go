@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ImageUploadPolicy ข้อกำหนดของรูปตามประเภทการอัปโหลด
+type ImageUploadPolicy struct {
+	MaxBytes     int64
+	MinWidth     int
+	MinHeight    int
+	MaxWidth     int
+	MaxHeight    int
+	MinAspect    float64 // width / height ขั้นต่ำ (0 = ไม่ตรวจ)
+	ContentTypes []string
+	Variants     []ImageVariantSpec
+}
+
+// ImageVariantSpec ขนาดย่อที่สร้างเพิ่มจากรูปต้นฉบับ (ไม่ขยายรูปที่เล็กกว่า)
+type ImageVariantSpec struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+	MaxBytes  int64 // 0 = ไม่จำกัด
+}
+
+// ข้อกำหนดของ LINE: Flex image และ rich menu รองรับเฉพาะ JPEG/PNG
+// rich menu กว้าง 800-2500px สูงอย่างน้อย 250px สัดส่วนอย่างน้อย 1.45 และไม่เกิน 1MB
+var (
+	flexImagePolicy = ImageUploadPolicy{
+		MaxBytes:     10 << 20,
+		MinWidth:     240,
+		MinHeight:    1,
+		MaxWidth:     4096,
+		MaxHeight:    4096,
+		ContentTypes: []string{"image/jpeg", "image/png"},
+		Variants: []ImageVariantSpec{
+			{Name: "flex", MaxWidth: 1024, MaxHeight: 1024, MaxBytes: 1 << 20},
+			{Name: "preview", MaxWidth: 240, MaxHeight: 240},
+		},
+	}
+	richMenuImagePolicy = ImageUploadPolicy{
+		MaxBytes:     10 << 20,
+		MinWidth:     800,
+		MinHeight:    250,
+		MaxWidth:     4096,
+		MaxHeight:    4096,
+		MinAspect:    1.45,
+		ContentTypes: []string{"image/jpeg", "image/png"},
+		Variants: []ImageVariantSpec{
+			{Name: "richmenu", MaxWidth: 2500, MaxHeight: 1686, MaxBytes: 1 << 20},
+		},
+	}
+	siteTemplateImagePolicy = ImageUploadPolicy{
+		MaxBytes:     5 << 20,
+		MaxWidth:     4096,
+		MaxHeight:    4096,
+		ContentTypes: []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/svg+xml"},
+	}
+)
+
+// MaxImageUploadBytes ขนาดไฟล์ใหญ่สุดของทุก policy ใช้ตั้ง BodyLimit ของ server
+const MaxImageUploadBytes = 10 << 20
+
+var uploadTypePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// ImageUploadError ไฟล์ไม่ผ่านข้อกำหนด Status คือ HTTP status ที่ควรตอบกลับ
+type ImageUploadError struct {
+	Status  int
+	Message string
+}
+
+func (e *ImageUploadError) Error() string {
+	return e.Message
+}
+
+func imageUploadError(status int, format string, args ...interface{}) error {
+	return &ImageUploadError{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+// ImageUploadTarget คืน policy และโฟลเดอร์ของประเภทการอัปโหลด
+// siteTemplate.<field> = รูปของหน้าเว็บ, richMenu = rich menu, นอกนั้นเป็นชื่อ Flex message
+func ImageUploadTarget(uploadType string) (ImageUploadPolicy, string, error) {
+	if !uploadTypePattern.MatchString(uploadType) {
+		return ImageUploadPolicy{}, "", imageUploadError(http.StatusBadRequest, "invalid upload type %q", uploadType)
+	}
+	if field, ok := strings.CutPrefix(uploadType, "siteTemplate."); ok {
+		return siteTemplateImagePolicy, "site_template/" + field, nil
+	}
+	if uploadType == "richMenu" {
+		return richMenuImagePolicy, "rich_menu", nil
+	}
+	if strings.Contains(uploadType, ".") {
+		return ImageUploadPolicy{}, "", imageUploadError(http.StatusBadRequest, "invalid upload type %q", uploadType)
+	}
+	return flexImagePolicy, "flex_messages/" + uploadType, nil
+}
+
+// ProcessedImage รูปที่ผ่านการตรวจสอบพร้อมรูปย่อ
+type ProcessedImage struct {
+	Name        string // hash ของเนื้อไฟล์ ใช้เป็นชื่อไฟล์เพื่อไม่ให้อัปโหลดซ้ำทับกัน
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Data        []byte
+	Variants    []ImageVariant
+}
+
+type ImageVariant struct {
+	Name        string
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// ObjectName ชื่อไฟล์ต้นฉบับใน dir
+func (p *ProcessedImage) ObjectName(dir string) string {
+	return dir + "/" + p.Name + p.Ext
+}
+
+// VariantObjectName ชื่อไฟล์ของรูปย่อใน dir
+func (p *ProcessedImage) VariantObjectName(dir string, variant ImageVariant) string {
+	return dir + "/" + p.Name + "_" + variant.Name + variant.Ext
+}
+
+// ProcessImageUpload ตรวจขนาด ชนิดไฟล์ และขนาดรูปตาม policy แล้วสร้างรูปย่อ
+// ชนิดไฟล์ดูจากเนื้อไฟล์ทั้งหมด ไม่เชื่อนามสกุลหรือ Content-Type ที่ส่งมา (ยกเว้นแยก SVG ออกจาก XML)
+func ProcessImageUpload(data []byte, filename string, policy ImageUploadPolicy) (*ProcessedImage, error) {
+	if int64(len(data)) > policy.MaxBytes {
+		return nil, imageUploadError(http.StatusRequestEntityTooLarge, "file is %d bytes, limit is %d bytes", len(data), policy.MaxBytes)
+	}
+	if len(data) == 0 {
+		return nil, imageUploadError(http.StatusBadRequest, "file is empty")
+	}
+
+	contentType := detectImageType(data, filename)
+	if !containsString(policy.ContentTypes, contentType) {
+		return nil, imageUploadError(http.StatusUnsupportedMediaType, "%s is not allowed, expected one of %s", contentType, strings.Join(policy.ContentTypes, ", "))
+	}
+
+	sum := sha256.Sum256(data)
+	processed := &ProcessedImage{
+		Name:        hex.EncodeToString(sum[:12]),
+		ContentType: contentType,
+		Ext:         imageExtension(contentType),
+		Data:        data,
+	}
+	if contentType == "image/svg+xml" {
+		return processed, nil
+	}
+
+	// อ่านขนาดจาก header ก่อน decode ทั้งรูป เพื่อกันไฟล์เล็กที่ประกาศขนาดใหญ่มาก
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, imageUploadError(http.StatusUnprocessableEntity, "file is not a valid image: %v", err)
+	}
+	processed.Width, processed.Height = cfg.Width, cfg.Height
+	if err := checkImageDimensions(cfg.Width, cfg.Height, policy); err != nil {
+		return nil, err
+	}
+	if len(policy.Variants) == 0 {
+		return processed, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, imageUploadError(http.StatusUnprocessableEntity, "file is not a valid image: %v", err)
+	}
+	for _, spec := range policy.Variants {
+		variant, err := resizeImage(src, contentType, spec)
+		if err != nil {
+			return nil, err
+		}
+		processed.Variants = append(processed.Variants, variant)
+	}
+	return processed, nil
+}
+
+func checkImageDimensions(width, height int, policy ImageUploadPolicy) error {
+	if width < policy.MinWidth || height < policy.MinHeight {
+		return imageUploadError(http.StatusUnprocessableEntity, "image is %dx%d, minimum is %dx%d", width, height, policy.MinWidth, policy.MinHeight)
+	}
+	if width > policy.MaxWidth || height > policy.MaxHeight {
+		return imageUploadError(http.StatusUnprocessableEntity, "image is %dx%d, maximum is %dx%d", width, height, policy.MaxWidth, policy.MaxHeight)
+	}
+	if policy.MinAspect > 0 && float64(width)/float64(height) < policy.MinAspect {
+		return imageUploadError(http.StatusUnprocessableEntity, "image aspect ratio %.2f is below %.2f", float64(width)/float64(height), policy.MinAspect)
+	}
+	return nil
+}
+
+func detectImageType(data []byte, filename string) string {
+	contentType := http.DetectContentType(data)
+	if strings.HasPrefix(contentType, "text/xml") || strings.HasPrefix(contentType, "text/plain") {
+		if strings.EqualFold(filepath.Ext(filename), ".svg") && bytes.Contains(data, []byte("<svg")) {
+			return "image/svg+xml"
+		}
+	}
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
+
+func imageExtension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/svg+xml":
+		return ".svg"
+	}
+	return ""
+}
+
+// resizeImage ย่อรูปให้อยู่ใน MaxWidth x MaxHeight โดยคงสัดส่วน
+// PNG คงเป็น PNG (รองรับพื้นหลังโปร่งใส) ถ้าเกิน MaxBytes จะแปลงเป็น JPEG และลด quality จนกว่าจะผ่าน
+func resizeImage(src image.Image, contentType string, spec ImageVariantSpec) (ImageVariant, error) {
+	bounds := src.Bounds()
+	width, height := fitWithin(bounds.Dx(), bounds.Dy(), spec.MaxWidth, spec.MaxHeight)
+
+	var resized image.Image = src
+	if width != bounds.Dx() || height != bounds.Dy() {
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+		resized = dst
+	}
+
+	variant := ImageVariant{Name: spec.Name, Width: width, Height: height}
+	if contentType == "image/png" {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, resized); err != nil {
+			return variant, fmt.Errorf("failed to encode %s variant: %v", spec.Name, err)
+		}
+		if spec.MaxBytes == 0 || int64(buf.Len()) <= spec.MaxBytes {
+			variant.ContentType, variant.Ext, variant.Data = "image/png", ".png", buf.Bytes()
+			return variant, nil
+		}
+	}
+
+	// JPEG ไม่มี alpha จึงวางบนพื้นขาวก่อน encode
+	opaque := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(opaque, opaque.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(opaque, opaque.Bounds(), resized, resized.Bounds().Min, draw.Over)
+
+	for _, quality := range []int{85, 75, 65, 55} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, opaque, &jpeg.Options{Quality: quality}); err != nil {
+			return variant, fmt.Errorf("failed to encode %s variant: %v", spec.Name, err)
+		}
+		if spec.MaxBytes == 0 || int64(buf.Len()) <= spec.MaxBytes {
+			variant.ContentType, variant.Ext, variant.Data = "image/jpeg", ".jpg", buf.Bytes()
+			return variant, nil
+		}
+	}
+	return variant, imageUploadError(http.StatusUnprocessableEntity, "%s variant exceeds %d bytes", spec.Name, spec.MaxBytes)
+}
+
+func fitWithin(width, height, maxWidth, maxHeight int) (int, int) {
+	if width <= maxWidth && height <= maxHeight {
+		return width, height
+	}
+	scale := float64(maxWidth) / float64(width)
+	if s := float64(maxHeight) / float64(height); s < scale {
+		scale = s
+	}
+	w, h := int(float64(width)*scale+0.5), int(float64(height)*scale+0.5)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// ListPage ซองคำตอบเดียวกันของ endpoint รายการทุกตัว
+type ListPage struct {
+	Items      interface{} `json:"items"`
+	Pagination PageInfo    `json:"pagination"`
+}
+
+// PageInfo ข้อมูลการแบ่งหน้า
+// โหมด cursor (ค่าเริ่มต้น): ส่ง next_cursor กลับมาเป็น ?cursor= เพื่ออ่านหน้าถัดไป total มีเมื่อขอผ่าน ?total=
+// โหมด ?page= เดิม: มี page, total_pages และ total เสมอ
+type PageInfo struct {
+	Limit          int    `json:"limit"`
+	NextCursor     string `json:"next_cursor,omitempty"`
+	HasNext        bool   `json:"has_next"`
+	HasPrev        bool   `json:"has_prev"`
+	Page           int    `json:"page,omitempty"`
+	TotalPages     int64  `json:"total_pages,omitempty"`
+	Total          *int64 `json:"total,omitempty"`
+	TotalEstimated bool   `json:"total_estimated,omitempty"` // total เป็นค่าประมาณหรือถูกตัดที่เพดาน
+}
+
+// listCursor เนื้อหาของ cursor token: ค่าของ sort key ทุกตัว (รวม _id) ของเอกสารสุดท้ายในหน้า
+type listCursor struct {
+	Sort   string          `bson:"s"`
+	Values []bson.RawValue `bson:"v"`
+}
+
+// ชนิดค่าของ sort key ที่เก็บใน cursor ได้
+var cursorValueTypes = map[bsontype.Type]bool{
+	bsontype.Null: true, bsontype.String: true, bsontype.Double: true, bsontype.Int32: true, bsontype.Int64: true,
+	bsontype.Decimal128: true, bsontype.Boolean: true, bsontype.DateTime: true, bsontype.Timestamp: true, bsontype.ObjectID: true,
+}
+
+// CursorSort เติม _id ต่อท้าย sort เพื่อให้ลำดับไม่ซ้ำกัน (ทิศทางเดียวกับ key สุดท้าย)
+func CursorSort(sortSpec bson.D) bson.D {
+	direction := 1
+	for _, e := range sortSpec {
+		if e.Key == "_id" {
+			return sortSpec
+		}
+		direction = sortDirection(e.Value)
+	}
+	return append(append(bson.D{}, sortSpec...), bson.E{Key: "_id", Value: direction})
+}
+
+// EncodeCursor สร้าง token ทึบจากค่า sort key ของ doc (key ที่ไม่มีในเอกสารเก็บเป็น null)
+func EncodeCursor(sortSpec bson.D, doc bson.Raw) (string, error) {
+	cursor := listCursor{Sort: sortSignature(sortSpec), Values: make([]bson.RawValue, 0, len(sortSpec))}
+	for _, e := range sortSpec {
+		value, err := doc.LookupErr(strings.Split(e.Key, ".")...)
+		if err != nil {
+			value = bson.RawValue{Type: bsontype.Null}
+		}
+		if !cursorValueTypes[value.Type] {
+			return "", &QueryError{Param: "sort", Message: "cannot page by non-scalar field " + e.Key}
+		}
+		cursor.Values = append(cursor.Values, value)
+	}
+	data, err := bson.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// CursorFilter แปลง token เป็นเงื่อนไข "อยู่หลังเอกสารสุดท้ายของหน้าก่อน" ตามลำดับ sortSpec
+//
+//	(k1 > v1) or (k1 = v1 and k2 > v2) or ... (ทิศทาง -1 ใช้ "ไม่ >= v" เพื่อให้รวมเอกสารที่ไม่มี key ซึ่งเรียงอยู่ท้ายสุด)
+//
+// sort ด้วย field ที่เป็น array ไม่รองรับ เพราะ MongoDB เรียงด้วยค่าใน array ที่ cursor ไม่ได้เก็บ
+func CursorFilter(sortSpec bson.D, token string) (bson.M, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, &QueryError{Param: "cursor", Message: "invalid cursor"}
+	}
+	var cursor listCursor
+	if err := bson.Unmarshal(data, &cursor); err != nil {
+		return nil, &QueryError{Param: "cursor", Message: "invalid cursor"}
+	}
+	if cursor.Sort != sortSignature(sortSpec) || len(cursor.Values) != len(sortSpec) {
+		return nil, &QueryError{Param: "cursor", Message: "cursor was issued for a different sort"}
+	}
+	for _, value := range cursor.Values {
+		// token มาจาก client ค่าที่เป็นเอกสารจะกลายเป็น operator ในเงื่อนไขเท่ากับได้
+		if !cursorValueTypes[value.Type] {
+			return nil, &QueryError{Param: "cursor", Message: "invalid cursor"}
+		}
+	}
+
+	or := bson.A{}
+	for i, e := range sortSpec {
+		branch := bson.M{}
+		for j := 0; j < i; j++ {
+			branch[sortSpec[j].Key] = cursor.Values[j]
+		}
+		value := cursor.Values[i]
+		switch {
+		case value.Type == bsontype.Null && sortDirection(e.Value) < 0:
+			// ไม่มีค่าใดเรียงหลัง null เมื่อเรียงจากมากไปน้อย
+			continue
+		case value.Type == bsontype.Null:
+			branch[e.Key] = bson.M{"$ne": nil}
+		case sortDirection(e.Value) < 0:
+			branch[e.Key] = bson.M{"$not": bson.M{"$gte": value}}
+		default:
+			branch[e.Key] = bson.M{"$gt": value}
+		}
+		or = append(or, branch)
+	}
+	if len(or) == 0 {
+		// เอกสารสุดท้ายอยู่ท้ายสุดแล้ว
+		return bson.M{"_id": bson.M{"$exists": false}}, nil
+	}
+	return bson.M{"$or": or}, nil
+}
+
+// AndFilter รวมเงื่อนไขสองชุด (filter เดิมอาจมี $or ของการค้นหาข้อความอยู่แล้ว)
+func AndFilter(filter, condition bson.M) bson.M {
+	if len(filter) == 0 {
+		return condition
+	}
+	return bson.M{"$and": bson.A{filter, condition}}
+}
+
+func sortDirection(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 1
+}
+
+// sortSignature เช่น "-updated_at,_id" ใช้ตรวจว่า cursor มาจาก sort เดียวกัน
+func sortSignature(sortSpec bson.D) string {
+	parts := make([]string, 0, len(sortSpec))
+	for _, e := range sortSpec {
+		if sortDirection(e.Value) < 0 {
+			parts = append(parts, "-"+e.Key)
+		} else {
+			parts = append(parts, e.Key)
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QueryFieldType ชนิดของ field ที่ใช้แปลงค่าจาก query string
+type QueryFieldType int
+
+const (
+	QueryString QueryFieldType = iota
+	QueryNumber
+	QueryDate
+	QueryObjectID
+	QueryBool
+)
+
+// QueryUnsortable รวมกับชนิดของ field (เช่น QueryString | QueryUnsortable) สำหรับ field ที่เป็น array หรือเอกสารย่อย
+// ซึ่งกรองได้แต่ ?sort= ไม่ได้ เพราะ MongoDB เรียงด้วยค่าใน array และ cursor เก็บค่าเหล่านั้นไม่ได้
+const QueryUnsortable QueryFieldType = 1 << 8
+
+// Kind ชนิดของ field โดยไม่รวม flag
+func (t QueryFieldType) Kind() QueryFieldType {
+	return t &^ QueryUnsortable
+}
+
+// Sortable บอกว่าใช้ field นี้ใน ?sort= ได้หรือไม่
+func (t QueryFieldType) Sortable() bool {
+	return t&QueryUnsortable == 0
+}
+
+// QuerySchema field ที่ endpoint รายการยอมให้กรอง เรียง และเลือกคืน (ชื่อ field เป็นชื่อใน MongoDB)
+type QuerySchema struct {
+	Fields      map[string]QueryFieldType
+	TextParam   string   // ชื่อ parameter ค้นหาข้อความ (ค่าเริ่มต้น "query")
+	TextFields  []string // field ที่ค้นหาข้อความ (field ชนิด string เท่านั้น)
+	DefaultSort bson.D
+}
+
+// ListQuery ผลการแปลง query string ของ endpoint รายการ
+type ListQuery struct {
+	Filter     bson.M
+	Sort       bson.D
+	Fields     []string // field ที่ขอผ่าน ?fields= (ว่างคือทั้งหมด)
+	Projection bson.M
+}
+
+// QueryError query string ไม่ถูกต้อง (ตอบกลับเป็น 400)
+type QueryError struct {
+	Param   string
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Param, e.Message)
+}
+
+// parameter ที่ไม่ใช่เงื่อนไขกรองของ field
+var listQueryReserved = map[string]bool{
+	"page": true, "limit": true, "cursor": true, "total": true, "sort": true, "fields": true, "field": true, "tenant_id": true,
+}
+
+var listQueryOperators = map[string]string{
+	"eq": "$eq", "ne": "$ne", "gt": "$gt", "gte": "$gte", "lt": "$lt", "lte": "$lte", "in": "$in", "nin": "$nin",
+}
+
+// ParseListQuery แปลง query string เป็น filter, sort และ projection ที่ตรวจกับ schema แล้ว
+//
+//	status=in:processing,pending   created_at=gte:2026-01-01   user_id=U123 (เท่ากับ)
+//	display_name=contains:สมชาย    user_id=prefix:U1           read_at=exists:false
+//	sort=-updated_at,user_id       fields=user_id,status        query=ข้อความ (&field=ชื่อ field)
+//
+// ค่าข้อความที่ใช้เป็น regex ถูก escape เสมอ วันที่รับ YYYY-MM-DD (ตามเวลาไทย) หรือ RFC3339
+// params ที่อยู่ใน extra เป็น parameter เฉพาะของ endpoint และถูกข้าม
+func ParseListQuery(args func(visit func(key, value string)), schema QuerySchema, extra ...string) (ListQuery, error) {
+	textParam := schema.TextParam
+	if textParam == "" {
+		textParam = "query"
+	}
+	skip := map[string]bool{textParam: true}
+	for _, key := range extra {
+		skip[key] = true
+	}
+
+	q := ListQuery{Filter: bson.M{}, Sort: schema.DefaultSort}
+	var text, textField string
+	var firstErr error
+	fail := func(param, format string, a ...interface{}) {
+		if firstErr == nil {
+			firstErr = &QueryError{Param: param, Message: fmt.Sprintf(format, a...)}
+		}
+	}
+
+	args(func(key, value string) {
+		switch {
+		case key == textParam:
+			text = value
+		case key == "field":
+			textField = value
+		case key == "sort":
+			sortSpec, err := parseListSort(value, schema)
+			if err != nil {
+				fail("sort", "%v", err)
+			}
+			if len(sortSpec) > 0 {
+				q.Sort = sortSpec
+			}
+		case key == "fields":
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name == "" {
+					continue
+				}
+				if _, ok := schema.Fields[name]; !ok {
+					fail("fields", "unknown field %q", name)
+					continue
+				}
+				q.Fields = append(q.Fields, name)
+			}
+		case listQueryReserved[key] || skip[key]:
+		default:
+			fieldType, ok := schema.Fields[key]
+			if !ok {
+				fail(key, "unknown filter field (allowed: %s)", strings.Join(schemaFieldNames(schema), ", "))
+				return
+			}
+			if err := addListCondition(q.Filter, key, fieldType.Kind(), value); err != nil {
+				fail(key, "%v", err)
+			}
+		}
+	})
+	if firstErr != nil {
+		return q, firstErr
+	}
+
+	if text != "" {
+		fields := schema.TextFields
+		if textField != "" {
+			if !containsString(schema.TextFields, textField) {
+				return q, &QueryError{Param: "field", Message: "field is not searchable"}
+			}
+			fields = []string{textField}
+		}
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(text), Options: "i"}
+		or := make([]bson.M, 0, len(fields))
+		for _, field := range fields {
+			or = append(or, bson.M{field: pattern})
+		}
+		if len(or) > 0 {
+			q.Filter["$or"] = or
+		}
+	}
+
+	if len(q.Fields) > 0 {
+		q.Projection = bson.M{"_id": 1}
+		for _, field := range q.Fields {
+			q.Projection[field] = 1
+		}
+	}
+	return q, nil
+}
+
+func parseListSort(value string, schema QuerySchema) (bson.D, error) {
+	var sortSpec bson.D
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		direction := 1
+		if strings.HasPrefix(name, "-") {
+			direction = -1
+			name = name[1:]
+		} else {
+			name = strings.TrimPrefix(name, "+")
+		}
+		if name == "" {
+			continue
+		}
+		fieldType, ok := schema.Fields[name]
+		if !ok && name != "_id" {
+			return nil, fmt.Errorf("unknown sort field %q", name)
+		}
+		if !fieldType.Sortable() {
+			return nil, fmt.Errorf("cannot sort by %q (array or embedded document)", name)
+		}
+		sortSpec = append(sortSpec, bson.E{Key: name, Value: direction})
+	}
+	return sortSpec, nil
+}
+
+// addListCondition เพิ่มเงื่อนไขของ field หนึ่งค่า (field เดียวกันหลายครั้งจะรวม operator กัน เช่น gte กับ lt)
+func addListCondition(filter bson.M, field string, fieldType QueryFieldType, value string) error {
+	op, operand := "eq", value
+	if i := strings.Index(value, ":"); i > 0 {
+		candidate := value[:i]
+		if _, ok := listQueryOperators[candidate]; ok || candidate == "contains" || candidate == "prefix" || candidate == "exists" {
+			op, operand = candidate, value[i+1:]
+		}
+	}
+
+	conditions := bson.M{}
+	switch op {
+	case "contains", "prefix":
+		if fieldType != QueryString {
+			return fmt.Errorf("%s only applies to text fields", op)
+		}
+		pattern := regexp.QuoteMeta(operand)
+		if op == "prefix" {
+			pattern = "^" + pattern
+		}
+		conditions["$regex"] = primitive.Regex{Pattern: pattern, Options: "i"}
+	case "exists":
+		exists, err := strconv.ParseBool(operand)
+		if err != nil {
+			return fmt.Errorf("exists expects true or false")
+		}
+		conditions["$exists"] = exists
+	case "in", "nin":
+		values := bson.A{}
+		for _, part := range strings.Split(operand, ",") {
+			v, err := parseListValue(fieldType, part)
+			if err != nil {
+				return err
+			}
+			values = append(values, v.at)
+		}
+		conditions[listQueryOperators[op]] = values
+	default:
+		v, err := parseListValue(fieldType, operand)
+		if err != nil {
+			return err
+		}
+		if v.dateOnly {
+			// วันที่ไม่มีเวลาหมายถึงทั้งวัน
+			next := v.at.(time.Time).AddDate(0, 0, 1)
+			switch op {
+			case "eq":
+				conditions["$gte"], conditions["$lt"] = v.at, next
+			case "gt", "lte":
+				conditions[map[string]string{"gt": "$gte", "lte": "$lt"}[op]] = next
+			default:
+				conditions[listQueryOperators[op]] = v.at
+			}
+		} else {
+			conditions[listQueryOperators[op]] = v.at
+		}
+	}
+
+	existing, ok := filter[field].(bson.M)
+	if !ok {
+		existing = bson.M{}
+		filter[field] = existing
+	}
+	for key, v := range conditions {
+		existing[key] = v
+	}
+	return nil
+}
+
+type listValue struct {
+	at       interface{}
+	dateOnly bool
+}
+
+func parseListValue(fieldType QueryFieldType, raw string) (listValue, error) {
+	raw = strings.TrimSpace(raw)
+	switch fieldType {
+	case QueryNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return listValue{}, fmt.Errorf("%q is not a number", raw)
+		}
+		return listValue{at: n}, nil
+	case QueryDate:
+		t, dateOnly, err := parseAnalyticsTime(raw, AnalyticsLocation())
+		if err != nil {
+			return listValue{}, fmt.Errorf("%q: %v", raw, err)
+		}
+		return listValue{at: t, dateOnly: dateOnly}, nil
+	case QueryObjectID:
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			return listValue{}, fmt.Errorf("%q is not a valid ID", raw)
+		}
+		return listValue{at: id}, nil
+	case QueryBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return listValue{}, fmt.Errorf("%q is not true or false", raw)
+		}
+		return listValue{at: b}, nil
+	}
+	return listValue{at: raw}, nil
+}
+
+// schemaFieldNames ชื่อ field ทั้งหมดของ schema เรียงตามตัวอักษร (ใช้แสดงใน error)
+func schemaFieldNames(schema QuerySchema) []string {
+	names := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"sort"
+	"time"
+
+	"go-server/models"
+)
+
+// FunnelTier จำนวนมิชชันในกลุ่มที่ไปถึง tier และผลลัพธ์ของ tier นั้น
+type FunnelTier struct {
+	Tier           int           `json:"tier"`
+	Name           string        `json:"name"`
+	Reached        int           `json:"reached"`
+	InProgress     int           `json:"in_progress"`
+	AwaitingReward int           `json:"awaiting_reward"`
+	Claimed        int           `json:"claimed"`
+	RewardExpired  int           `json:"reward_expired"`
+	Failed         int           `json:"failed"`
+	DroppedOut     int           `json:"dropped_out"` // จบที่ tier นี้เพราะไม่ผ่านหรือไม่รับรางวัล
+	Levels         []FunnelLevel `json:"levels"`
+}
+
+// FunnelLevel จำนวนมิชชันที่ไปถึง level และเวลากลางที่ใช้ทำสำเร็จ
+type FunnelLevel struct {
+	Level                     int      `json:"level"`
+	Reached                   int      `json:"reached"`
+	Passed                    int      `json:"passed"`
+	Failed                    int      `json:"failed"`
+	InProgress                int      `json:"in_progress"`
+	MedianTimeToCompleteHours *float64 `json:"median_time_to_complete_hours"`
+	durations                 []float64
+}
+
+// MissionFunnel สะสมข้อมูล funnel จากมิชชันทีละรายการ (ใช้กับ cursor ได้โดยไม่ต้องโหลดทั้งหมด)
+type MissionFunnel struct {
+	Missions int
+	tiers    []*FunnelTier
+}
+
+func NewMissionFunnel() *MissionFunnel {
+	return &MissionFunnel{}
+}
+
+func (f *MissionFunnel) tier(index int, name string) *FunnelTier {
+	for len(f.tiers) <= index {
+		f.tiers = append(f.tiers, &FunnelTier{Tier: len(f.tiers) + 1})
+	}
+	t := f.tiers[index]
+	if t.Name == "" {
+		t.Name = name
+	}
+	return t
+}
+
+func (t *FunnelTier) level(index int) *FunnelLevel {
+	for len(t.Levels) <= index {
+		t.Levels = append(t.Levels, FunnelLevel{Level: len(t.Levels) + 1})
+	}
+	return &t.Levels[index]
+}
+
+// Add นับมิชชันเข้า funnel
+// tier และ level ถูกสร้างเมื่อไปถึงเท่านั้น การมีอยู่ของ element จึงหมายถึง "ไปถึง"
+func (f *MissionFunnel) Add(mission models.Mission) {
+	f.Missions++
+	for i, tier := range mission.Tiers {
+		t := f.tier(i, tier.Name)
+		t.Reached++
+		switch tier.Status {
+		case "processing":
+			t.InProgress++
+		case "awaiting_reward":
+			t.AwaitingReward++
+		case "completed":
+			t.Claimed++
+		case "expire_reward":
+			t.RewardExpired++
+		case "failed":
+			t.Failed++
+		}
+		if i == len(mission.Tiers)-1 && (tier.Status == "failed" || tier.Status == "expire_reward") {
+			t.DroppedOut++
+		}
+
+		for j, level := range tier.Levels {
+			l := t.level(j)
+			l.Reached++
+			switch level.Status {
+			case "success":
+				l.Passed++
+				if d, ok := levelCompletionTime(mission, i, j); ok {
+					l.durations = append(l.durations, d.Hours())
+				}
+			case "failed":
+				l.Failed++
+			default:
+				l.InProgress++
+			}
+		}
+	}
+}
+
+// levelCompletionTime เวลาที่ใช้ทำ level สำเร็จ
+// ไม่มีการบันทึกเวลาที่สำเร็จโดยตรง จึงใช้เวลาที่ level/tier ถัดไปเริ่ม (สร้างทันทีที่ทำสำเร็จ)
+// แต่ไม่เกิน expire_date ซึ่งเป็นเวลาที่ประเมินผลเมื่อสำเร็จตอนหมดเวลา
+func levelCompletionTime(mission models.Mission, tierIndex, levelIndex int) (time.Duration, bool) {
+	level := mission.Tiers[tierIndex].Levels[levelIndex]
+	if level.StartDate.IsZero() || level.ExpireDate.IsZero() {
+		return 0, false
+	}
+	completedAt := level.ExpireDate
+
+	var next time.Time
+	if levelIndex+1 < len(mission.Tiers[tierIndex].Levels) {
+		next = mission.Tiers[tierIndex].Levels[levelIndex+1].StartDate
+	} else if tierIndex+1 < len(mission.Tiers) && len(mission.Tiers[tierIndex+1].Levels) > 0 {
+		next = mission.Tiers[tierIndex+1].Levels[0].StartDate
+	}
+	if !next.IsZero() && next.Before(completedAt) {
+		completedAt = next
+	}
+	if completedAt.Before(level.StartDate) {
+		return 0, false
+	}
+	return completedAt.Sub(level.StartDate), true
+}
+
+// Result สรุป funnel ทุก tier พร้อมค่ากลางของเวลาที่ใช้ในแต่ละ level
+func (f *MissionFunnel) Result() []FunnelTier {
+	result := make([]FunnelTier, 0, len(f.tiers))
+	for _, t := range f.tiers {
+		tier := *t
+		tier.Levels = make([]FunnelLevel, len(t.Levels))
+		for i, l := range t.Levels {
+			if median, ok := medianOf(l.durations); ok {
+				l.MedianTimeToCompleteHours = &median
+			}
+			l.durations = nil
+			tier.Levels[i] = l
+		}
+		result = append(result, tier)
+	}
+	return result
+}
+
+func medianOf(values []float64) (float64, bool) {
+	if len(values) == 0 {
+		return 0, false
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2, true
+	}
+	return sorted[mid], true
+}
@@ -0,0 +1,263 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-server/models"
+)
+
+// TimelineEntry เหตุการณ์หนึ่งรายการใน timeline ของลูกค้า
+type TimelineEntry struct {
+	Time      time.Time              `json:"time"`
+	Type      string                 `json:"type"` // mission_started, level_started, level_result, scheduled_event, message, claim_requested, claim_callback
+	Status    string                 `json:"status"`
+	MissionID string                 `json:"mission_id,omitempty"`
+	Tier      int                    `json:"tier,omitempty"`
+	Level     int                    `json:"level,omitempty"`
+	Summary   string                 `json:"summary"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Rule      *models.TierDetail     `json:"rule,omitempty"`       // กติกาของ tier ที่ใช้ตัดสินรายการนี้ ณ เวลาของรายการ
+	OverlayID string                 `json:"overlay_id,omitempty"` // overlay ที่มีผลกับ Rule ณ เวลานั้น (ถ้ามี)
+}
+
+// TimelineRules กติกาของแต่ละ tier ที่มิชชันใช้อยู่ Source บอกว่ามาจาก snapshot ของมิชชันหรือ config ปัจจุบัน
+// Overlays คือ overlay ของ tenant ที่ใช้ปรับ Tiers ตามเวลาของแต่ละรายการ เหมือนตอนประเมินผลจริง
+type TimelineRules struct {
+	Tiers    []models.TierDetail
+	Source   string // "mission_snapshot" หรือ "current_config"
+	Overlays []models.ConfigOverlay
+}
+
+// at คืนกติกาทุก tier ที่มีผล ณ เวลา at และ overlay ที่ใช้ (nil ถ้าไม่มี)
+func (r TimelineRules) at(at time.Time) ([]models.TierDetail, *models.ConfigOverlay) {
+	overlay := OverlayAt(r.Overlays, at)
+	return ApplyTierOverlay(r.Tiers, overlay), overlay
+}
+
+// apply ใส่กติกาของ tier ของ entry ณ เวลาของ entry
+func (r TimelineRules) apply(entry *TimelineEntry) {
+	tiers, overlay := r.at(entry.Time)
+	if entry.Tier < 1 || entry.Tier > len(tiers) {
+		return
+	}
+	rule := tiers[entry.Tier-1]
+	entry.Rule = &rule
+	if overlay != nil {
+		entry.OverlayID = overlay.ID.Hex()
+	}
+}
+
+// OverlayAt คืน overlay ที่มีผล ณ เวลา at (starts_at <= at < ends_at) เงื่อนไขเดียวกับ config.Service.ActiveOverlay
+func OverlayAt(overlays []models.ConfigOverlay, at time.Time) *models.ConfigOverlay {
+	for i := range overlays {
+		if !overlays[i].StartsAt.After(at) && overlays[i].EndsAt.After(at) {
+			return &overlays[i]
+		}
+	}
+	return nil
+}
+
+var timelineEventNames = map[string]string{
+	"level_expiration":              "ครบกำหนดตรวจยอด level",
+	"follow_up":                     "แจ้งเตือนติดตามยอด",
+	"reward_expiration":             "รางวัลหมดอายุ",
+	"reward_notification":           "แจ้งเตือนให้รับรางวัล",
+	"recurring_reward_notification": "แจ้งเตือนรับรางวัลซ้ำ",
+}
+
+// MissionTimeline รายการของมิชชัน: เริ่มมิชชัน, เริ่มแต่ละ level และผลของ level ที่ตัดสินแล้วพร้อมยอดเดิมพัน
+func MissionTimeline(mission models.Mission, rules TimelineRules) []TimelineEntry {
+	missionID := mission.ID.Hex()
+	startRules, _ := rules.at(mission.CreatedAt)
+	entries := []TimelineEntry{{
+		Time:      mission.CreatedAt,
+		Type:      "mission_started",
+		Status:    mission.Status,
+		MissionID: missionID,
+		Summary:   fmt.Sprintf("เริ่มมิชชัน (สถานะปัจจุบัน %s)", mission.Status),
+		Details: map[string]interface{}{
+			"phone_number":      mission.PhoneNumber,
+			"current_tier":      mission.CurrentTier,
+			"consecutive_fails": mission.ConsecutiveFails,
+			"config_revision":   mission.ConfigRevision,
+			"rule_source":       rules.Source,
+			"tier_rules":        startRules,
+		},
+	}}
+
+	for tierIndex, tier := range mission.Tiers {
+		tierNumber := tierIndex + 1
+		for levelIndex, level := range tier.Levels {
+			levelNumber := levelIndex + 1
+			details := map[string]interface{}{
+				"tier_name":      tier.Name,
+				"tier_status":    tier.Status,
+				"target":         tier.Target,
+				"reward":         tier.Reward,
+				"start_date":     level.StartDate,
+				"expire_date":    level.ExpireDate,
+				"follow_up_date": level.FollowUpDate,
+				"current_bet":    level.CurrentBet,
+			}
+			started := TimelineEntry{
+				Time:      level.StartDate,
+				Type:      "level_started",
+				Status:    "processing",
+				MissionID: missionID,
+				Tier:      tierNumber,
+				Level:     levelNumber,
+				Summary:   fmt.Sprintf("เริ่ม Tier %d Level %d เป้ายอดเดิมพัน %s", tierNumber, levelNumber, formatTimelineAmount(float64(tier.Target))),
+				Details:   details,
+			}
+			rules.apply(&started)
+			entries = append(entries, started)
+
+			if level.Status != "success" && level.Status != "failed" {
+				continue
+			}
+			resultDetails := make(map[string]interface{}, len(details)+1)
+			for key, value := range details {
+				resultDetails[key] = value
+			}
+			result := "ผ่าน"
+			if level.Status == "failed" {
+				result = "ไม่ผ่าน"
+				resultDetails["shortfall"] = float64(tier.Target) - level.CurrentBet
+			}
+			outcome := TimelineEntry{
+				Time:      level.ExpireDate,
+				Type:      "level_result",
+				Status:    level.Status,
+				MissionID: missionID,
+				Tier:      tierNumber,
+				Level:     levelNumber,
+				Summary: fmt.Sprintf("Tier %d Level %d %s: ยอดเดิมพัน %s จากเป้า %s",
+					tierNumber, levelNumber, result, formatTimelineAmount(level.CurrentBet), formatTimelineAmount(float64(tier.Target))),
+				Details: resultDetails,
+			}
+			rules.apply(&outcome)
+			entries = append(entries, outcome)
+		}
+	}
+	return entries
+}
+
+// EventTimeline event ที่ตั้งเวลาไว้ใน tbl_events (เวลาคือเวลาที่ตั้งให้ทำงาน)
+func EventTimeline(event models.ExpirationEvent, rules TimelineRules) TimelineEntry {
+	name := timelineEventNames[event.Type]
+	if name == "" {
+		name = event.Type
+	}
+	details := map[string]interface{}{
+		"event_id":   event.ID.Hex(),
+		"event_type": event.Type,
+	}
+	if event.DeferCount > 0 {
+		details["defer_count"] = event.DeferCount
+	}
+	if event.Error != "" {
+		details["error"] = event.Error
+	}
+	if !event.ProcessedAt.IsZero() && event.Status != "pending" {
+		details["processed_at"] = event.ProcessedAt
+	}
+	entry := TimelineEntry{
+		Time:      event.ExpireTime,
+		Type:      "scheduled_event",
+		Status:    event.Status,
+		MissionID: event.MissionID.Hex(),
+		Tier:      event.TierIndex + 1,
+		Level:     event.LevelIndex + 1,
+		Summary:   fmt.Sprintf("%s (%s)", name, event.Status),
+		Details:   details,
+	}
+	rules.apply(&entry)
+	return entry
+}
+
+// MessageTimeline Flex message ที่ส่งถึงลูกค้า
+func MessageTimeline(message models.MessageLog) TimelineEntry {
+	details := map[string]interface{}{
+		"message_id":  message.ID.Hex(),
+		"title":       message.FlexContent.Title,
+		"description": message.FlexContent.Description,
+	}
+	if !message.ReadAt.IsZero() {
+		details["read_at"] = message.ReadAt
+	}
+	if !message.BroadcastID.IsZero() {
+		details["broadcast_id"] = message.BroadcastID.Hex()
+	}
+	if !message.DeletedAt.IsZero() {
+		details["deleted_at"] = message.DeletedAt
+	}
+
+	entry := TimelineEntry{
+		Time:    message.SentAt,
+		Type:    "message",
+		Status:  message.Status,
+		Summary: "ส่งข้อความ: " + message.FlexContent.Title,
+		Details: details,
+	}
+	if !message.MissionID.IsZero() {
+		entry.MissionID = message.MissionID.Hex()
+	}
+	entry.Tier, _ = strconv.Atoi(message.Tier)
+	entry.Level, _ = strconv.Atoi(message.Level)
+	return entry
+}
+
+// ClaimTimeline การขอรับรางวัลและผลการอนุมัติ (ถ้ามี callback แล้ว)
+func ClaimTimeline(entry models.Log, rules TimelineRules) []TimelineEntry {
+	tierNumber := ClaimTier(entry)
+	details := map[string]interface{}{
+		"log_id":         entry.ID.Hex(),
+		"reward":         entry.Reward,
+		"mission_detail": entry.MissionDetail,
+	}
+	entries := []TimelineEntry{{
+		Time:      entry.CreatedAt,
+		Type:      "claim_requested",
+		Status:    entry.Status,
+		MissionID: entry.MissionID,
+		Tier:      tierNumber,
+		Summary:   fmt.Sprintf("ขอรับรางวัล %s", formatTimelineAmount(entry.Reward)),
+		Details:   details,
+	}}
+	rules.apply(&entries[0])
+	if !entry.CallbackTime.IsZero() {
+		result := "อนุมัติ"
+		if entry.Status == "reject" {
+			result = "ปฏิเสธ"
+		}
+		callback := TimelineEntry{
+			Time:      entry.CallbackTime,
+			Type:      "claim_callback",
+			Status:    entry.Status,
+			MissionID: entry.MissionID,
+			Tier:      tierNumber,
+			Summary:   fmt.Sprintf("%sการรับรางวัล %s", result, formatTimelineAmount(entry.Reward)),
+			Details:   details,
+		}
+		rules.apply(&callback)
+		entries = append(entries, callback)
+	}
+	return entries
+}
+
+// SortTimeline เรียงตามเวลา (รายการเวลาเดียวกันคงลำดับเดิม)
+func SortTimeline(entries []TimelineEntry, descending bool) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if descending {
+			return entries[i].Time.After(entries[j].Time)
+		}
+		return entries[i].Time.Before(entries[j].Time)
+	})
+}
+
+func formatTimelineAmount(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
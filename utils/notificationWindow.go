@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"log"
+	"time"
+
+	"go-server/models"
+)
+
+const defaultCampaignTimezone = "Asia/Bangkok"
+
+// CampaignLocation คืน timezone ของแคมเปญ (ค่าเริ่มต้น Asia/Bangkok)
+func CampaignLocation(settings models.NotificationConfig) *time.Location {
+	tz := settings.Timezone
+	if tz == "" {
+		tz = defaultCampaignTimezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		log.Printf("utils.CampaignLocation: Invalid timezone %q, falling back to %s: %v", tz, defaultCampaignTimezone, err)
+		loc, err = time.LoadLocation(defaultCampaignTimezone)
+		if err != nil {
+			return time.UTC
+		}
+	}
+	return loc
+}
+
+// StartOfCampaignDay คืนเวลาเริ่มต้นของวัน (00:00) ตาม timezone ของแคมเปญ
+func StartOfCampaignDay(settings models.NotificationConfig, t time.Time) time.Time {
+	local := t.In(CampaignLocation(settings))
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+}
+
+// InQuietHours ตรวจสอบว่าเวลา t อยู่ในช่วงงดส่งข้อความหรือไม่
+// รองรับช่วงที่ข้ามเที่ยงคืน เช่น 22 - 8
+func InQuietHours(settings models.NotificationConfig, t time.Time) bool {
+	if !settings.QuietHoursEnabled || settings.QuietHoursStart == settings.QuietHoursEnd {
+		return false
+	}
+	hour := t.In(CampaignLocation(settings)).Hour()
+	if settings.QuietHoursStart < settings.QuietHoursEnd {
+		return hour >= settings.QuietHoursStart && hour < settings.QuietHoursEnd
+	}
+	return hour >= settings.QuietHoursStart || hour < settings.QuietHoursEnd
+}
+
+// NextAllowedSendTime คืนเวลาแรกที่ส่งข้อความได้นับจาก t
+// ถ้า t ไม่อยู่ในช่วงงดส่งจะคืนค่า t เดิม
+func NextAllowedSendTime(settings models.NotificationConfig, t time.Time) time.Time {
+	if !InQuietHours(settings, t) {
+		return t
+	}
+	local := t.In(CampaignLocation(settings))
+	next := time.Date(local.Year(), local.Month(), local.Day(), settings.QuietHoursEnd, 0, 0, 0, local.Location())
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
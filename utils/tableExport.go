@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TableWriter เขียนข้อมูลแบบตารางทีละแถว (CSV หรือ XLSX) เพื่อ stream ออกโดยไม่เก็บทั้งหมดไว้ในหน่วยความจำ
+type TableWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// NewTableWriter สร้าง writer ตาม format ("csv" หรือ "xlsx")
+func NewTableWriter(w io.Writer, format, sheetName string) (TableWriter, error) {
+	switch format {
+	case "", "csv":
+		return newCSVTableWriter(w)
+	case "xlsx":
+		return newXLSXTableWriter(w, sheetName)
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// ExportContentType Content-Type และนามสกุลไฟล์ของ format
+func ExportContentType(format string) (string, string) {
+	if format == "xlsx" {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".xlsx"
+	}
+	return "text/csv; charset=utf-8", ".csv"
+}
+
+// formatExportValue แปลงค่าเป็นข้อความ เวลาแสดงตามเวลาไทย ค่าว่างของเวลาเป็นช่องว่าง
+// ข้อความจากผู้ใช้ผ่าน neutralizeFormula เพื่อไม่ให้ Excel/Sheets ตีความเป็นสูตร
+func formatExportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return neutralizeFormula(v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.In(AnalyticsLocation()).Format("2006-01-02 15:04:05")
+	case primitive.DateTime:
+		return formatExportValue(v.Time())
+	case primitive.ObjectID:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Hex()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return neutralizeFormula(fmt.Sprint(value))
+}
+
+// neutralizeFormula เติม ' หน้าข้อความที่ขึ้นต้นด้วยอักขระที่เริ่มสูตรได้ (CSV/formula injection)
+func neutralizeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+type csvTableWriter struct {
+	writer *csv.Writer
+	record []string
+	rows   int
+}
+
+// CSV ขึ้นต้นด้วย UTF-8 BOM เพื่อให้ Excel เปิดภาษาไทยได้ถูกต้อง
+func newCSVTableWriter(w io.Writer) (*csvTableWriter, error) {
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return nil, err
+	}
+	return &csvTableWriter{writer: csv.NewWriter(w)}, nil
+}
+
+func (t *csvTableWriter) WriteRow(values []interface{}) error {
+	t.record = t.record[:0]
+	for _, value := range values {
+		t.record = append(t.record, formatExportValue(value))
+	}
+	if err := t.writer.Write(t.record); err != nil {
+		return err
+	}
+	t.rows++
+	if t.rows%500 == 0 {
+		t.writer.Flush()
+		return t.writer.Error()
+	}
+	return nil
+}
+
+func (t *csvTableWriter) Close() error {
+	t.writer.Flush()
+	return t.writer.Error()
+}
+
+// xlsxTableWriter เขียน XLSX แบบ sheet เดียว ใช้ inline string จึงไม่ต้องเก็บ shared strings ไว้ทั้งไฟล์
+type xlsxTableWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+}
+
+var xlsxStaticParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func newXLSXTableWriter(w io.Writer, sheetName string) (*xlsxTableWriter, error) {
+	z := zip.NewWriter(w)
+	for _, part := range xlsxStaticParts {
+		f, err := z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := z.Create("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(f, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`); err != nil {
+		return nil, err
+	}
+	if err := xml.EscapeText(f, []byte(sheetName)); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(f, `" sheetId="1" r:id="rId1"/></sheets></workbook>`); err != nil {
+		return nil, err
+	}
+
+	sheet, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+	return &xlsxTableWriter{zip: z, sheet: sheet}, nil
+}
+
+func (t *xlsxTableWriter) WriteRow(values []interface{}) error {
+	if _, err := io.WriteString(t.sheet, "<row>"); err != nil {
+		return err
+	}
+	for _, value := range values {
+		var err error
+		switch v := value.(type) {
+		case int, float64:
+			_, err = fmt.Fprintf(t.sheet, `<c><v>%s</v></c>`, formatExportValue(v))
+		default:
+			text := formatExportValue(v)
+			if text == "" {
+				_, err = io.WriteString(t.sheet, "<c/>")
+				break
+			}
+			if _, err = io.WriteString(t.sheet, `<c t="inlineStr"><is><t xml:space="preserve">`); err == nil {
+				if err = xml.EscapeText(t.sheet, []byte(text)); err == nil {
+					_, err = io.WriteString(t.sheet, "</t></is></c>")
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(t.sheet, "</row>")
+	return err
+}
+
+func (t *xlsxTableWriter) Close() error {
+	if _, err := io.WriteString(t.sheet, "</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	return t.zip.Close()
+}